@@ -0,0 +1,212 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionDriftDetected reports whether the bucket was changed outside of the operator
+const ConditionDriftDetected = "DriftDetected"
+
+// BucketDeletionPolicy is what happens to the bucket when the Bucket is deleted
+// +kubebuilder:validation:Enum=Retain;Delete
+type BucketDeletionPolicy string
+
+const (
+	// BucketDeletionPolicyRetain keeps the bucket and its objects
+	BucketDeletionPolicyRetain BucketDeletionPolicy = "Retain"
+	// BucketDeletionPolicyDelete deletes the bucket, it must be empty
+	BucketDeletionPolicyDelete BucketDeletionPolicy = "Delete"
+)
+
+// BucketSpec defines the desired state of Bucket
+type BucketSpec struct {
+	// ObjectStoreName is the name of the ObjectStore, in the same namespace, holding the bucket
+	ObjectStoreName string `json:"objectStoreName"`
+
+	// UserName is the name of the ObjectStoreUser, in the same namespace, owning the bucket
+	UserName string `json:"userName"`
+
+	// BucketName is the name of the bucket, it defaults to the name of the Bucket
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Versioning is the versioning status of the bucket, it is left untouched when unset
+	// +optional
+	// +kubebuilder:validation:Enum=Enabled;Suspended
+	Versioning string `json:"versioning,omitempty"`
+
+	// ObjectLock configures the object lock of the bucket, it can only be enabled at creation
+	// +optional
+	ObjectLock *BucketObjectLock `json:"objectLock,omitempty"`
+
+	// Policy is the bucket policy as a JSON document
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// CORSRules are the CORS rules of the bucket
+	// +optional
+	CORSRules []BucketCORSRule `json:"corsRules,omitempty"`
+
+	// LifecycleRules are the lifecycle rules of the bucket
+	// +optional
+	LifecycleRules []BucketLifecycleRule `json:"lifecycleRules,omitempty"`
+
+	// DeletionPolicy is what happens to the bucket when the Bucket is deleted
+	// +optional
+	// +kubebuilder:default=Retain
+	DeletionPolicy BucketDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// BucketObjectLock is the object lock configuration of a bucket
+type BucketObjectLock struct {
+	// Enabled enables the object lock, which also enables the versioning
+	Enabled bool `json:"enabled"`
+
+	// DefaultRetention is the retention applied to the new objects
+	// +optional
+	DefaultRetention *BucketRetention `json:"defaultRetention,omitempty"`
+}
+
+// BucketRetention is a retention period, exactly one of days and years must be set
+type BucketRetention struct {
+	// Mode is the retention mode
+	// +kubebuilder:validation:Enum=GOVERNANCE;COMPLIANCE
+	Mode string `json:"mode"`
+
+	// Days is the retention period in days
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Days int `json:"days,omitempty"`
+
+	// Years is the retention period in years
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Years int `json:"years,omitempty"`
+}
+
+// BucketCORSRule is a CORS rule of a bucket
+type BucketCORSRule struct {
+	// ID identifies the rule
+	// +optional
+	ID string `json:"id,omitempty"`
+
+	// AllowedOrigins are the origins allowed to access the bucket
+	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// AllowedMethods are the HTTP methods allowed on the bucket
+	AllowedMethods []string `json:"allowedMethods"`
+
+	// AllowedHeaders are the headers allowed in the preflight requests
+	// +optional
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+
+	// ExposeHeaders are the response headers the clients can access
+	// +optional
+	ExposeHeaders []string `json:"exposeHeaders,omitempty"`
+
+	// MaxAgeSeconds is how long the clients can cache the preflight response
+	// +optional
+	MaxAgeSeconds int `json:"maxAgeSeconds,omitempty"`
+}
+
+// BucketLifecycleRule is a lifecycle rule of a bucket
+type BucketLifecycleRule struct {
+	// ID identifies the rule
+	ID string `json:"id"`
+
+	// Prefix restricts the rule to the objects with this prefix
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Enabled enables the rule
+	// +optional
+	// +kubebuilder:default=true
+	Enabled bool `json:"enabled"`
+
+	// ExpirationDays is the number of days after which the objects expire
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	ExpirationDays int `json:"expirationDays,omitempty"`
+
+	// NoncurrentVersionExpirationDays is the number of days after which the noncurrent versions expire
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	NoncurrentVersionExpirationDays int `json:"noncurrentVersionExpirationDays,omitempty"`
+
+	// AbortIncompleteMultipartUploadDays is the number of days after which the incomplete
+	// multipart uploads are aborted
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	AbortIncompleteMultipartUploadDays int `json:"abortIncompleteMultipartUploadDays,omitempty"`
+}
+
+// BucketStatus defines the observed state of Bucket
+type BucketStatus struct {
+	// Phase is a short summary of the Bucket state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the Bucket
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// BucketName is the name of the bucket in the ObjectStore
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// Drift lists the settings that were changed outside of the operator and reverted to the spec
+	// during the last check
+	// +optional
+	Drift []string `json:"drift,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="ObjectStore",type=string,JSONPath=`.spec.objectStoreName`
+//+kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.status.bucketName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Bucket is the Schema for the buckets API
+type Bucket struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BucketSpec   `json:"spec,omitempty"`
+	Status BucketStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BucketList contains a list of Bucket
+type BucketList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Bucket `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Bucket{}, &BucketList{})
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ObjectRealmSpec defines the desired state of ObjectRealm. The realm is named after the resource,
+// it is bootstrapped by the ObjectStore serving the master zone of its master zonegroup.
+type ObjectRealmSpec struct {
+}
+
+// ObjectRealmStatus defines the observed state of ObjectRealm
+type ObjectRealmStatus struct {
+	// Phase is a short summary of the ObjectRealm state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectRealm
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// RealmTokenSecretName is the Secret holding the realm token, it is created by the master zone
+	// or copied from the cluster of the master zone
+	// +optional
+	RealmTokenSecretName string `json:"realmTokenSecretName,omitempty"`
+
+	// MasterEndpoint is the endpoint of the master zone, as found in the realm token
+	// +optional
+	MasterEndpoint string `json:"masterEndpoint,omitempty"`
+
+	// MasterZoneGroup is the ObjectZoneGroup marked as master of the realm
+	// +optional
+	MasterZoneGroup string `json:"masterZoneGroup,omitempty"`
+
+	// ZoneGroups are the ObjectZoneGroups of the realm
+	// +optional
+	ZoneGroups []string `json:"zoneGroups,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Master ZoneGroup",type=string,JSONPath=`.status.masterZoneGroup`
+//+kubebuilder:printcolumn:name="Master Endpoint",type=string,JSONPath=`.status.masterEndpoint`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectRealm is the Schema for the objectrealms API, it is the multisite realm the zonegroups of
+// the same namespace belong to
+type ObjectRealm struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectRealmSpec   `json:"spec,omitempty"`
+	Status ObjectRealmStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectRealmList contains a list of ObjectRealm
+type ObjectRealmList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectRealm `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectRealm{}, &ObjectRealmList{})
+}
@@ -38,33 +38,568 @@ type ObjectStoreSpec struct {
 
 	// VolumeClaimTemplate is the PVC definition
 	VolumeClaimTemplate *v1.PersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
+
+	// PVCRetentionPolicy tells whether the PVC holding the data is kept or deleted along with the ObjectStore
+	// +optional
+	// +kubebuilder:validation:Enum=Retain;Delete
+	// +kubebuilder:default=Retain
+	PVCRetentionPolicy PVCRetentionPolicy `json:"pvcRetentionPolicy,omitempty"`
+
+	// ImagePullSecrets are the secrets used to pull the image, e.g. from a private registry
+	// +optional
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Config are Ceph options passed to the gateway as flags, e.g. "debug rgw": "5" or
+	// "rgw_thread_pool_size": "256". They take precedence over the ConfigMap.
+	// +optional
+	Config map[string]string `json:"config,omitempty"`
+
+	// ConfigMapRef references a ConfigMap in the namespace of the ObjectStore whose entries are Ceph
+	// options, they are rendered into the ceph.conf of the gateway. The gateway is restarted when it
+	// changes.
+	// +optional
+	ConfigMapRef *v1.LocalObjectReference `json:"configMapRef,omitempty"`
+
+	// Upgrade configures how the gateway is upgraded when the image changes
+	// +optional
+	Upgrade *UpgradeSpec `json:"upgrade,omitempty"`
+
+	// RestoreFrom restores the data from a backup before the gateway starts for the first time, it
+	// is ignored once the gateway has run
+	// +optional
+	RestoreFrom *RestoreSource `json:"restoreFrom,omitempty"`
+}
+
+// RestoreSource is a backup made by an ObjectStoreBackup, either named by its ObjectStoreBackup or
+// by its location when the ObjectStoreBackup is gone, e.g. on replacement hardware
+type RestoreSource struct {
+	// BackupName is the ObjectStoreBackup, in the same namespace, whose target holds the backup
+	// +optional
+	BackupName string `json:"backupName,omitempty"`
+
+	// Target is the S3-compatible storage holding the backup, when there is no ObjectStoreBackup
+	// +optional
+	Target *BackupTarget `json:"target,omitempty"`
+
+	// Key is the key of the backup archive, it defaults to the last backup of the ObjectStoreBackup
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
+// UpgradeSpec configures the upgrade of the gateway. The data is copied before the new image is
+// deployed and restored if the new image does not become ready, the copy is removed once the
+// upgrade is done.
+type UpgradeSpec struct {
+	// MigrationCommand is run with the new image on the stopped data before the new gateway is
+	// started, for the versions requiring an offline schema migration. The gateway migrates its
+	// database when it starts otherwise.
+	// +optional
+	MigrationCommand []string `json:"migrationCommand,omitempty"`
+
+	// TimeoutSeconds is how long the new gateway has to become ready before the upgrade is rolled back
+	// +optional
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:default=900
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// PVCRetentionPolicy is the policy applied to the PVC when the ObjectStore is deleted
+type PVCRetentionPolicy string
+
+const (
+	// PVCRetentionPolicyRetain keeps the PVC and the data after the ObjectStore is deleted
+	PVCRetentionPolicyRetain PVCRetentionPolicy = "Retain"
+	// PVCRetentionPolicyDelete deletes the PVC and the data with the ObjectStore
+	PVCRetentionPolicyDelete PVCRetentionPolicy = "Delete"
+)
+
 // GatewaySpec represents the specification of Ceph Object Store Gateway
 type GatewaySpec struct {
 	// The port the rgw service will be listening on (http)
 	// +optional
 	Port int32 `json:"port,omitempty"`
+
+	// The port the rgw service will be listening on (https), it requires TLS to be configured
+	// +optional
+	SecurePort int32 `json:"securePort,omitempty"`
+
+	// TLS configures the certificate served on the secure port
+	// +optional
+	TLS *GatewayTLSSpec `json:"tls,omitempty"`
+
+	// Service configures the Service exposing the gateway
+	// +optional
+	Service *GatewayServiceSpec `json:"service,omitempty"`
+
+	// ExternalEndpoints are the URLs the other sites reach the gateway at, e.g.
+	// https://edge-1.example.com:8443. They default to the load balancer address when the Service
+	// is a LoadBalancer and to the ClusterIP otherwise, which is only reachable from the cluster.
+	// They are required for a multisite gateway behind a NodePort Service.
+	// +optional
+	ExternalEndpoints []string `json:"externalEndpoints,omitempty"`
+
+	// Expose publishes the gateway outside of the cluster through an Ingress, a Route or an HTTPRoute
+	// +optional
+	Expose *GatewayExposeSpec `json:"expose,omitempty"`
+
+	// Placement tells on which nodes the gateway and its jobs run
+	// +optional
+	Placement *PlacementSpec `json:"placement,omitempty"`
+
+	// Resources are the compute resources of the gateway containers and its jobs
+	// +optional
+	Resources v1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Probes tunes the health checks of the gateway
+	// +optional
+	Probes *GatewayProbesSpec `json:"probes,omitempty"`
+}
+
+// GatewayProbesSpec tunes the probes of the gateway container, they query the gateway over http
+type GatewayProbesSpec struct {
+	// Liveness restarts the gateway when it stops answering
+	// +optional
+	Liveness *ProbeSpec `json:"liveness,omitempty"`
+
+	// Readiness removes the gateway from the Service endpoints when it stops answering
+	// +optional
+	Readiness *ProbeSpec `json:"readiness,omitempty"`
+
+	// Startup holds the other probes while the gateway starts, the first start initializes the
+	// database and can take several minutes on slow disks
+	// +optional
+	Startup *ProbeSpec `json:"startup,omitempty"`
+}
+
+// ProbeSpec overrides the settings of a probe, unset fields keep their default
+type ProbeSpec struct {
+	// Disabled removes the probe
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// InitialDelaySeconds is the number of seconds before the probe starts
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// PeriodSeconds is how often the probe runs
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// TimeoutSeconds is the timeout of a probe request
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures for the probe to fail
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// PlacementSpec is the scheduling configuration of the gateway pods
+type PlacementSpec struct {
+	// NodeSelector selects the nodes by their labels
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations allow the pods to run on tainted nodes
+	// +optional
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity is the node and pod affinity of the pods
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints spread the pods across the topology domains
+	// +optional
+	TopologySpreadConstraints []v1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// PriorityClassName is the priority class of the pods
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+}
+
+// ExposeType is the kind of object publishing the gateway
+// +kubebuilder:validation:Enum=Ingress;Route;HTTPRoute
+type ExposeType string
+
+const (
+	// ExposeTypeIngress publishes the gateway with a networking.k8s.io Ingress
+	ExposeTypeIngress ExposeType = "Ingress"
+	// ExposeTypeRoute publishes the gateway with an OpenShift Route
+	ExposeTypeRoute ExposeType = "Route"
+	// ExposeTypeHTTPRoute publishes the gateway with a Gateway API HTTPRoute
+	ExposeTypeHTTPRoute ExposeType = "HTTPRoute"
+)
+
+// GatewayExposeSpec publishes the gateway under a host name
+type GatewayExposeSpec struct {
+	// Type is the kind of object created to publish the gateway
+	Type ExposeType `json:"type"`
+
+	// Host is the host name of the gateway, e.g. s3.example.com
+	Host string `json:"host"`
+
+	// VirtualHostStyle also publishes the bucket sub-domains, e.g. *.s3.example.com, and
+	// configures the gateway to read the bucket name from the host
+	// +optional
+	VirtualHostStyle bool `json:"virtualHostStyle,omitempty"`
+
+	// Annotations are added to the created object, e.g. to configure the ingress controller
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// IngressClassName is the class of the Ingress
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// TLSSecretName is the Secret holding the certificate for the host name, the Ingress or Route
+	// terminates TLS with it. The published gateway is reached at its secure port when it serves
+	// TLS, the ingress controller must then be told the backend speaks https through its
+	// annotations and the HTTPRoute's Gateway must originate TLS. Without a certificate, a Route
+	// passes the TLS connections through to a gateway serving TLS.
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
+
+	// ParentRef is the Gateway the HTTPRoute is attached to
+	// +optional
+	ParentRef *ExposeParentRef `json:"parentRef,omitempty"`
+}
+
+// ExposeParentRef references a Gateway API Gateway
+type ExposeParentRef struct {
+	// Name is the name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Gateway, it defaults to the namespace of the ObjectStore
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName is the listener of the Gateway
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// GatewayServiceSpec is the Service exposing the gateway
+type GatewayServiceSpec struct {
+	// Type is the type of the Service
+	// +optional
+	// +kubebuilder:default=ClusterIP
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	Type v1.ServiceType `json:"type,omitempty"`
+
+	// Annotations are added to the Service, e.g. to select a load balancer pool
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GatewayTLSSpec is the certificate of the gateway, it comes either from an existing Secret or
+// from cert-manager
+type GatewayTLSSpec struct {
+	// SecretName is the name of a kubernetes.io/tls Secret holding the certificate and its key.
+	// When an issuer is set, cert-manager writes the certificate in this Secret. A certificate
+	// provided by the user must be valid for <service>.<namespace>.svc, the name the operator
+	// uses to reach the gateway.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// IssuerRef requests the certificate from this cert-manager issuer
+	// +optional
+	IssuerRef *CertificateIssuerRef `json:"issuerRef,omitempty"`
+
+	// DNSNames are added to the names of the gateway service in the requested certificate
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+}
+
+// CertificateIssuerRef references a cert-manager issuer
+type CertificateIssuerRef struct {
+	// Name is the name of the issuer
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer, Issuer or ClusterIssuer
+	// +optional
+	// +kubebuilder:default=Issuer
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+
+	// Group is the API group of the issuer
+	// +optional
+	// +kubebuilder:default=cert-manager.io
+	Group string `json:"group,omitempty"`
 }
 
 type MultisiteSpec struct {
 	// IsMainSite is true if this is the main site of the multisite
 	IsMainSite bool `json:"isMainSite,omitempty"`
 
+	// ZoneRef is the name of the ObjectZone, in the same namespace, served by the gateway. The
+	// realm, zonegroup and zone names and the role of the site come from the ObjectZone, its
+	// ObjectZoneGroup and its ObjectRealm, it cannot be combined with isMainSite and
+	// realmTokenSecretName.
+	// +optional
+	ZoneRef string `json:"zoneRef,omitempty"`
+
 	// RealmTokenSecretName is the name of the Kubernetes Secret that contains the realm token
 	// It is used to bootstrap the Zone
 	// +optional
 	RealmTokenSecretName string `json:"realmTokenSecretName,omitempty"`
+
+	// RealmTokenSecretNamespace is the namespace of the realm token secret, the namespace of the
+	// ObjectStore by default. Another namespace must be allowed with the --realm-token-namespaces flag
+	// of the operator, and its secret must be a realm token export or be annotated with
+	// object.rgw-standalone/realm-token=true. The token is then copied next to the ObjectStore.
+	// +optional
+	RealmTokenSecretNamespace string `json:"realmTokenSecretNamespace,omitempty"`
+
+	// RealmTokenExports publish the realm token of the site owning it, the main site or a promoted
+	// one, for the secondary sites of other namespaces or clusters
+	// +optional
+	RealmTokenExports []RealmTokenExport `json:"realmTokenExports,omitempty"`
+
+	// Promote makes the zone of a secondary site the master of the realm, to recover when the main
+	// site is lost. The realm token secret is rotated to point to this site and the other secondary
+	// sites using it pull the new period. A promoted zone cannot be demoted.
+	// +optional
+	Promote bool `json:"promote,omitempty"`
 }
 
+// RealmTokenExportFormat is how the realm token is published
+type RealmTokenExportFormat string
+
+const (
+	// RealmTokenExportSecret copies the token in the "token" key of a Secret, it can be referenced
+	// by realmTokenSecretName
+	RealmTokenExportSecret RealmTokenExportFormat = "Secret"
+	// RealmTokenExportBundle writes the manifest of such a Secret in the "realm-token.yaml" key of a
+	// Secret, to be applied on another cluster
+	RealmTokenExportBundle RealmTokenExportFormat = "Bundle"
+)
+
+// RealmTokenExport is a Secret the realm token is published to
+type RealmTokenExport struct {
+	// Name is the name of the Secret, and of the Secret described by a bundle
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Secret, the namespace of the ObjectStore by default. Other
+	// namespaces must be allowed as destinations of the namespace of the ObjectStore with the
+	// --realm-token-namespaces flag of the operator.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Format is how the token is published
+	// +optional
+	// +kubebuilder:validation:Enum=Secret;Bundle
+	// +kubebuilder:default=Secret
+	Format RealmTokenExportFormat `json:"format,omitempty"`
+}
+
+// Condition types reported in ObjectStoreStatus.Conditions
+const (
+	// ConditionReady is true when every other applicable condition is true
+	ConditionReady = "Ready"
+	// ConditionPVCBound is true when the PVC holding the database is bound to a volume
+	ConditionPVCBound = "PVCBound"
+	// ConditionPVCResized is true when the PVC capacity matches the storage request of the claim
+	// template, it does not affect the readiness since the gateway keeps serving while resizing
+	ConditionPVCResized = "PVCResized"
+	// ConditionServiceReady is true when the gateway Service has been assigned an address
+	ConditionServiceReady = "ServiceReady"
+	// ConditionDeploymentAvailable is true when the gateway pods are running
+	ConditionDeploymentAvailable = "DeploymentAvailable"
+	// ConditionMultisiteConfigured is true when the zone has been created from the realm token
+	ConditionMultisiteConfigured = "MultisiteConfigured"
+	// ConditionRealmBootstrapped is true when the main site realm and its token secret exist
+	ConditionRealmBootstrapped = "RealmBootstrapped"
+	// ConditionTLSReady is true when the certificate served on the secure port is available
+	ConditionTLSReady = "TLSReady"
+	// ConditionUpgraded is true when the gateway runs the image of the spec after an upgrade, the
+	// reason tells whether the last upgrade succeeded or failed
+	ConditionUpgraded = "Upgraded"
+	// ConditionRestored is true when the data was restored from the backup and its integrity checked
+	ConditionRestored = "Restored"
+	// ConditionAdminUserReady is true when the credentials of the admin ops API are available to the
+	// operator
+	ConditionAdminUserReady = "AdminUserReady"
+	// ConditionSyncHealthy is true when the zone replicates with the other zones of the realm, it is
+	// only set on multisite zones and does not affect the readiness
+	ConditionSyncHealthy = "SyncHealthy"
+	// ConditionPromoted is true when the zone of a secondary site has been made the master of the
+	// realm and the realm token secret points to it
+	ConditionPromoted = "Promoted"
+	// ConditionRealmTokenExported is true when the realm token is published to every export
+	ConditionRealmTokenExported = "RealmTokenExported"
+)
+
+// Phases reported in ObjectStoreStatus.Phase
+const (
+	PhaseProgressing = "Progressing"
+	PhaseReady       = "Ready"
+	PhaseFailed      = "Failed"
+	PhaseDeleting    = "Deleting"
+)
+
 // ObjectStoreStatus defines the observed state of ObjectStore
 type ObjectStoreStatus struct {
+	// Phase is a short summary of the ObjectStore state, the conditions hold the details
 	// +optional
 	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectStore
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Endpoint is the URL of the gateway service
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// ExternalEndpoints are the URLs advertised to the other sites of the multisite
+	// +optional
+	ExternalEndpoints []string `json:"externalEndpoints,omitempty"`
+
+	// Realm is the name of the multisite realm the gateway belongs to
+	// +optional
+	Realm string `json:"realm,omitempty"`
+
+	// ZoneGroup is the name of the multisite zonegroup of the zone, when set by an ObjectZoneGroup
+	// +optional
+	ZoneGroup string `json:"zoneGroup,omitempty"`
+
+	// Zone is the name of the multisite zone served by the gateway
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// RealmTokenSecretName is the secret holding the realm token of the site, in its namespace: the
+	// token bootstrapped by a main site, or the one a secondary site joined the realm with
+	// +optional
+	RealmTokenSecretName string `json:"realmTokenSecretName,omitempty"`
+
+	// Image is the image the gateway runs, it differs from the one of the spec during an upgrade
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Version is the version of the image the gateway runs, when its tag is a version
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Upgrade is the upgrade in progress
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+
+	// RestoredFrom is the key of the backup the data was restored from
+	// +optional
+	RestoredFrom string `json:"restoredFrom,omitempty"`
+
+	// FailedImage is the image whose upgrade failed and was rolled back, it is not retried until the
+	// image of the spec changes
+	// +optional
+	FailedImage string `json:"failedImage,omitempty"`
+
+	// MasterEndpoint is the endpoint of the master zone the period was last pulled from, it changes
+	// when another zone is promoted
+	// +optional
+	MasterEndpoint string `json:"masterEndpoint,omitempty"`
+
+	// Multisite is the replication state of the zone, as reported by the gateway
+	// +optional
+	Multisite *MultisiteStatus `json:"multisite,omitempty"`
+}
+
+// UpgradePhase is the step of an upgrade
+type UpgradePhase string
+
+const (
+	// UpgradePhaseBackup stops the gateway and copies its data
+	UpgradePhaseBackup UpgradePhase = "Backup"
+	// UpgradePhaseMigrate runs the migration command of the new image
+	UpgradePhaseMigrate UpgradePhase = "Migrate"
+	// UpgradePhaseRollout starts the gateway with the new image
+	UpgradePhaseRollout UpgradePhase = "Rollout"
+	// UpgradePhaseRollback stops the gateway and restores the copy of the data
+	UpgradePhaseRollback UpgradePhase = "Rollback"
+)
+
+// UpgradeStatus is the state of an upgrade
+type UpgradeStatus struct {
+	// FromImage is the image the gateway is upgraded from, and rolled back to on failure
+	FromImage string `json:"fromImage"`
+
+	// ToImage is the image the gateway is upgraded to
+	ToImage string `json:"toImage"`
+
+	// Phase is the current step of the upgrade
+	Phase UpgradePhase `json:"phase"`
+
+	// PhaseStartTime is when the current step started
+	PhaseStartTime metav1.Time `json:"phaseStartTime"`
+
+	// Reason tells why the upgrade is being rolled back
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// MultisiteStatus is the replication state of a multisite zone
+type MultisiteStatus struct {
+	// CheckTime is when the sync status was last collected
+	CheckTime metav1.Time `json:"checkTime"`
+
+	// Metadata is the sync state of the metadata, e.g. users and buckets, from the master zone
+	// +optional
+	Metadata SyncStatus `json:"metadata,omitempty"`
+
+	// DataSources are the sync states of the objects from each of the other zones
+	// +optional
+	DataSources []DataSyncStatus `json:"dataSources,omitempty"`
+
+	// ErrorCount is the number of entries in the sync error log of the zone
+	// +optional
+	ErrorCount int32 `json:"errorCount,omitempty"`
+}
+
+// SyncStatus is the sync state of the metadata or of the data from a zone
+type SyncStatus struct {
+	// State is the state reported by the gateway, e.g. "syncing"
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// CaughtUp is true when every change of the source has been applied
+	// +optional
+	CaughtUp bool `json:"caughtUp,omitempty"`
+
+	// ShardsBehind is the number of log shards with changes not yet applied
+	// +optional
+	ShardsBehind int32 `json:"shardsBehind,omitempty"`
+
+	// OldestChange is the time of the oldest change not yet applied
+	// +optional
+	OldestChange *metav1.Time `json:"oldestChange,omitempty"`
+
+	// Error is the failure reported by the gateway, e.g. when the source zone is unreachable
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// DataSyncStatus is the sync state of the objects from another zone
+type DataSyncStatus struct {
+	// SourceZone is the name of the zone the objects are replicated from
+	SourceZone string `json:"sourceZone"`
+
+	SyncStatus `json:",inline"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.status.endpoint`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // ObjectStore is the Schema for the objectstores API
 type ObjectStore struct {
@@ -99,3 +634,22 @@ func (o *ObjectStoreSpec) IsMultisite() bool {
 func (o *ObjectStoreSpec) IsMainSite() bool {
 	return o.Multisite != nil && o.Multisite.IsMainSite
 }
+
+// HasZoneRef returns whether the multisite topology of the gateway comes from an ObjectZone
+func (o *ObjectStoreSpec) HasZoneRef() bool {
+	return o.Multisite != nil && o.Multisite.ZoneRef != ""
+}
+
+// IsPromoted returns whether the secondary site is promoted to master of the realm
+func (o *ObjectStoreSpec) IsPromoted() bool {
+	return o.IsMultisite() && o.Multisite.Promote
+}
+
+// HasRealmTokenExports returns whether the realm token is published to other secrets
+func (o *ObjectStoreSpec) HasRealmTokenExports() bool {
+	return o.Multisite != nil && len(o.Multisite.RealmTokenExports) > 0
+}
+
+func (o *ObjectStoreSpec) IsTLSEnabled() bool {
+	return o.Gateway.TLS != nil
+}
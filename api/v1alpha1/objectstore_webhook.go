@@ -0,0 +1,334 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+const (
+	// DefaultGatewayPort is the port the gateway service listens on when none is set
+	DefaultGatewayPort int32 = 8080
+
+	// DefaultGatewaySecurePort is the TLS port the gateway service listens on when none is set
+	DefaultGatewaySecurePort int32 = 8443
+
+	// DefaultStorageSize is the size of the PVC holding the database when none is requested
+	DefaultStorageSize = "10Gi"
+)
+
+// log is for logging in this package.
+var objectstorelog = logf.Log.WithName("objectstore-resource")
+
+func (r *ObjectStore) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-object-rgw-standalone-v1alpha1-objectstore,mutating=true,failurePolicy=fail,sideEffects=None,groups=object.rgw-standalone,resources=objectstores,verbs=create;update,versions=v1alpha1,name=mobjectstore.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &ObjectStore{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *ObjectStore) Default() {
+	objectstorelog.Info("default", "name", r.Name)
+
+	if r.Spec.Gateway.Port == 0 {
+		r.Spec.Gateway.Port = DefaultGatewayPort
+	}
+	if r.Spec.IsTLSEnabled() && r.Spec.Gateway.SecurePort == 0 {
+		r.Spec.Gateway.SecurePort = DefaultGatewaySecurePort
+	}
+
+	// A missing template is rejected by the validation
+	if r.Spec.VolumeClaimTemplate == nil {
+		return
+	}
+	claimSpec := &r.Spec.VolumeClaimTemplate.Spec
+	if len(claimSpec.AccessModes) == 0 {
+		claimSpec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	}
+	if _, ok := claimSpec.Resources.Requests[v1.ResourceStorage]; !ok {
+		if claimSpec.Resources.Requests == nil {
+			claimSpec.Resources.Requests = v1.ResourceList{}
+		}
+		claimSpec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(DefaultStorageSize)
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-object-rgw-standalone-v1alpha1-objectstore,mutating=false,failurePolicy=fail,sideEffects=None,groups=object.rgw-standalone,resources=objectstores,verbs=create;update,versions=v1alpha1,name=vobjectstore.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ObjectStore{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStore) ValidateCreate() error {
+	objectstorelog.Info("validate create", "name", r.Name)
+
+	return r.toInvalidError(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStore) ValidateUpdate(old runtime.Object) error {
+	objectstorelog.Info("validate update", "name", r.Name)
+
+	oldObjectStore, ok := old.(*ObjectStore)
+	if !ok {
+		return fmt.Errorf("expected an ObjectStore but got a %T", old)
+	}
+
+	// The finalizer of an ObjectStore being deleted must be removable whatever its spec
+	if r.DeletionTimestamp != nil {
+		return nil
+	}
+
+	// An ObjectStore created before a validation was added keeps its invalid fields, only the new
+	// errors are rejected so that its labels, finalizers and other fields can still be updated
+	allErrs := newErrors(r.validateSpec(), oldObjectStore.validateSpec())
+	specPath := field.NewPath("spec")
+
+	if IsImageDowngrade(oldObjectStore.Spec.Image, r.Spec.Image) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("image"),
+			fmt.Sprintf("downgrading from %q is not supported", oldObjectStore.Spec.Image)))
+	}
+
+	if oldObjectStore.Spec.VolumeClaimTemplate != nil && r.Spec.VolumeClaimTemplate != nil {
+		oldSize := oldObjectStore.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage]
+		newSize := r.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage]
+		if newSize.Cmp(oldSize) < 0 {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("volumeClaimTemplate", "spec", "resources", "requests", "storage"),
+				fmt.Sprintf("the PVC cannot shrink from %s", oldSize.String())))
+		}
+	}
+
+	if oldObjectStore.Spec.RestoreFrom == nil && r.Spec.RestoreFrom != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("restoreFrom"), "a backup can only be restored when the ObjectStore is created"))
+	}
+
+	if oldObjectStore.Spec.multisiteRole() != r.Spec.multisiteRole() {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("multisite"),
+			fmt.Sprintf("the multisite role cannot be changed from %q to %q after creation", oldObjectStore.Spec.multisiteRole(), r.Spec.multisiteRole())))
+	}
+	if oldObjectStore.Spec.Multisite != nil && oldObjectStore.Spec.Multisite.Promote && (r.Spec.Multisite == nil || !r.Spec.Multisite.Promote) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("multisite", "promote"), "a promoted zone cannot be demoted"))
+	}
+
+	return r.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStore) ValidateDelete() error {
+	return nil
+}
+
+// managedConfigOptions are the Ceph options set by the operator, overriding them would break the gateway
+var managedConfigOptions = map[string]struct{}{
+	"id":                       {},
+	"host":                     {},
+	"conf":                     {},
+	"librados_sqlite_data_dir": {},
+	"rgw_frontends":            {},
+	"rgw_dns_name":             {},
+	"auth_client_required":     {},
+	"auth_service_required":    {},
+	"auth_cluster_required":    {},
+}
+
+// validateSpec returns the errors of the spec that do not depend on the previous version
+func (r *ObjectStore) validateSpec() field.ErrorList {
+	allErrs := field.ErrorList{}
+	specPath := field.NewPath("spec")
+
+	if r.Spec.VolumeClaimTemplate == nil {
+		allErrs = append(allErrs, field.Required(specPath.Child("volumeClaimTemplate"), "the PVC holding the database must be described"))
+	} else if volumeMode := r.Spec.VolumeClaimTemplate.Spec.VolumeMode; volumeMode != nil && *volumeMode == v1.PersistentVolumeBlock {
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("volumeClaimTemplate", "spec", "volumeMode"), *volumeMode,
+			[]string{string(v1.PersistentVolumeFilesystem)}))
+	}
+
+	gatewayPath := specPath.Child("gateway")
+	if tls := r.Spec.Gateway.TLS; tls != nil {
+		if tls.SecretName == "" && tls.IssuerRef == nil {
+			allErrs = append(allErrs, field.Required(gatewayPath.Child("tls"), "either secretName or issuerRef must be set"))
+		}
+	} else if r.Spec.Gateway.SecurePort != 0 {
+		allErrs = append(allErrs, field.Required(gatewayPath.Child("tls"), "the secure port requires a certificate"))
+	}
+	if r.Spec.Gateway.SecurePort != 0 && r.Spec.Gateway.SecurePort == r.Spec.Gateway.Port {
+		allErrs = append(allErrs, field.Duplicate(gatewayPath.Child("securePort"), r.Spec.Gateway.SecurePort))
+	}
+	if expose := r.Spec.Gateway.Expose; expose != nil {
+		exposePath := gatewayPath.Child("expose")
+		if strings.HasPrefix(expose.Host, "*") {
+			allErrs = append(allErrs, field.Invalid(exposePath.Child("host"), expose.Host, "the wildcard host is added by virtualHostStyle"))
+		}
+		if expose.Type == ExposeTypeHTTPRoute && expose.ParentRef == nil {
+			allErrs = append(allErrs, field.Required(exposePath.Child("parentRef"), "an HTTPRoute must be attached to a Gateway"))
+		}
+	}
+
+	// The node port is not reachable at the ClusterIP advertised by default
+	if service := r.Spec.Gateway.Service; service != nil && service.Type == v1.ServiceTypeNodePort &&
+		r.Spec.Multisite != nil && len(r.Spec.Gateway.ExternalEndpoints) == 0 {
+		allErrs = append(allErrs, field.Required(gatewayPath.Child("externalEndpoints"), "the other sites reach a NodePort service at the address of a node"))
+	}
+	for i, endpoint := range r.Spec.Gateway.ExternalEndpoints {
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil || (endpointURL.Scheme != "http" && endpointURL.Scheme != "https") || endpointURL.Host == "" {
+			allErrs = append(allErrs, field.Invalid(gatewayPath.Child("externalEndpoints").Index(i), endpoint, "must be an http or https URL"))
+		}
+	}
+
+	if restore := r.Spec.RestoreFrom; restore != nil {
+		restorePath := specPath.Child("restoreFrom")
+		if (restore.BackupName == "") == (restore.Target == nil) {
+			allErrs = append(allErrs, field.Invalid(restorePath, restore.BackupName, "exactly one of backupName and target must be set"))
+		}
+		if restore.Target != nil && restore.Key == "" {
+			allErrs = append(allErrs, field.Required(restorePath.Child("key"), "the key of the backup is required with a target"))
+		}
+		// The data of a PVC created from a snapshot would be overwritten
+		if claim := r.Spec.VolumeClaimTemplate; claim != nil && (claim.Spec.DataSource != nil || claim.Spec.DataSourceRef != nil) {
+			allErrs = append(allErrs, field.Forbidden(restorePath, "cannot be set when the volumeClaimTemplate has a dataSource"))
+		}
+	}
+
+	for key := range r.Spec.Config {
+		normalized := strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(key))
+		if normalized == "" {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("config"), key, "option name cannot be empty"))
+		} else if _, ok := managedConfigOptions[normalized]; ok {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("config").Key(key), "the option is managed by the operator"))
+		}
+	}
+
+	if r.Spec.Multisite != nil && r.Spec.Multisite.IsMainSite && r.Spec.Multisite.RealmTokenSecretName != "" {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("multisite", "realmTokenSecretName"), r.Spec.Multisite.RealmTokenSecretName,
+			"the main site creates the realm token, it cannot join a realm"))
+	}
+	if r.Spec.Multisite != nil && r.Spec.Multisite.Promote && r.Spec.Multisite.RealmTokenSecretName == "" && r.Spec.Multisite.ZoneRef == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("multisite", "realmTokenSecretName"), "only the zone of a secondary site can be promoted"))
+	}
+	if r.Spec.Multisite != nil && r.Spec.Multisite.RealmTokenSecretNamespace != "" && r.Spec.Multisite.RealmTokenSecretName == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("multisite", "realmTokenSecretName"), "the namespace of the realm token requires its name"))
+	}
+	if r.Spec.Multisite != nil && len(r.Spec.Multisite.RealmTokenExports) > 0 {
+		exportsPath := specPath.Child("multisite", "realmTokenExports")
+		if !r.Spec.Multisite.IsMainSite && !r.Spec.Multisite.Promote && !r.Spec.HasZoneRef() {
+			allErrs = append(allErrs, field.Forbidden(exportsPath, "only the main site or a promoted site owns the realm token"))
+		}
+		exports := map[string]struct{}{}
+		for i, export := range r.Spec.Multisite.RealmTokenExports {
+			if export.Name == "" {
+				allErrs = append(allErrs, field.Required(exportsPath.Index(i).Child("name"), "the name of the secret is required"))
+			}
+			key := export.Namespace + "/" + export.Name
+			if _, ok := exports[key]; ok {
+				allErrs = append(allErrs, field.Duplicate(exportsPath.Index(i), key))
+			}
+			exports[key] = struct{}{}
+		}
+	}
+	if r.Spec.HasZoneRef() && (r.Spec.Multisite.IsMainSite || r.Spec.Multisite.RealmTokenSecretName != "") {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("multisite", "zoneRef"), r.Spec.Multisite.ZoneRef,
+			"the role of the site comes from the ObjectZone, it cannot be combined with isMainSite and realmTokenSecretName"))
+	}
+
+	return allErrs
+}
+
+// newErrors returns the errors of allErrs on a field that did not already have an error of the
+// same type in oldErrs
+func newErrors(allErrs, oldErrs field.ErrorList) field.ErrorList {
+	existing := map[string]struct{}{}
+	for _, err := range oldErrs {
+		existing[string(err.Type)+" "+err.Field] = struct{}{}
+	}
+
+	errs := field.ErrorList{}
+	for _, err := range allErrs {
+		if _, ok := existing[string(err.Type)+" "+err.Field]; !ok {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (r *ObjectStore) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("ObjectStore").GroupKind(), r.Name, allErrs)
+}
+
+// multisiteRole returns the role of the gateway in the multisite, main, secondary or standalone, or
+// the ObjectZone it serves
+func (o *ObjectStoreSpec) multisiteRole() string {
+	switch {
+	case o.HasZoneRef():
+		return fmt.Sprintf("zone %s", o.Multisite.ZoneRef)
+	case o.IsMainSite():
+		return "main"
+	case o.IsMultisite():
+		return "secondary"
+	default:
+		return "standalone"
+	}
+}
+
+// IsImageDowngrade returns whether the tag of the new image is an older version than the one of
+// the old image, images whose tag is not a version, such as development builds, are not compared
+func IsImageDowngrade(oldImage, newImage string) bool {
+	oldVersion, err := ImageVersion(oldImage)
+	if err != nil {
+		return false
+	}
+	newVersion, err := ImageVersion(newImage)
+	if err != nil {
+		return false
+	}
+
+	return newVersion.LessThan(oldVersion)
+}
+
+// ImageVersion parses the tag of the image, e.g. quay.io/ceph/ceph:v17.2.3
+func ImageVersion(image string) (*version.Version, error) {
+	// Digests do not tell anything about the version
+	image = strings.Split(image, "@")[0]
+
+	// The registry may have a port, the tag is after the last path element
+	name := image[strings.LastIndex(image, "/")+1:]
+	index := strings.LastIndex(name, ":")
+	if index < 0 {
+		return nil, fmt.Errorf("image %q has no tag", image)
+	}
+
+	return version.ParseGeneric(name[index+1:])
+}
@@ -0,0 +1,365 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newValidObjectStore returns an ObjectStore accepted by the validation, the tests break one field
+func newValidObjectStore() *ObjectStore {
+	objectStore := &ObjectStore{
+		Spec: ObjectStoreSpec{
+			Image:               "quay.io/ceph/ceph:v17.2.3",
+			VolumeClaimTemplate: &v1.PersistentVolumeClaim{},
+		},
+	}
+	objectStore.Name = "store"
+	objectStore.Default()
+	return objectStore
+}
+
+// invalidFields returns the fields reported by a validation error
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) || !apierrors.IsInvalid(err) {
+		t.Fatalf("error %v is not an Invalid error", err)
+	}
+	fields := []string{}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestDefault(t *testing.T) {
+	objectStore := &ObjectStore{Spec: ObjectStoreSpec{
+		VolumeClaimTemplate: &v1.PersistentVolumeClaim{},
+		Gateway:             GatewaySpec{TLS: &GatewayTLSSpec{SecretName: "tls"}},
+	}}
+	objectStore.Default()
+
+	if objectStore.Spec.Gateway.Port != DefaultGatewayPort || objectStore.Spec.Gateway.SecurePort != DefaultGatewaySecurePort {
+		t.Errorf("ports %d and %d, want %d and %d", objectStore.Spec.Gateway.Port, objectStore.Spec.Gateway.SecurePort, DefaultGatewayPort, DefaultGatewaySecurePort)
+	}
+	claimSpec := objectStore.Spec.VolumeClaimTemplate.Spec
+	if !reflect.DeepEqual(claimSpec.AccessModes, []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}) {
+		t.Errorf("access modes %v, want ReadWriteOnce", claimSpec.AccessModes)
+	}
+	if size := claimSpec.Resources.Requests[v1.ResourceStorage]; size.String() != DefaultStorageSize {
+		t.Errorf("storage %s, want %s", size.String(), DefaultStorageSize)
+	}
+
+	// Values set by the user are kept
+	objectStore.Spec.Gateway.Port = 80
+	objectStore.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("1Gi")
+	objectStore.Default()
+	if size := objectStore.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage]; objectStore.Spec.Gateway.Port != 80 || size.String() != "1Gi" {
+		t.Errorf("port %d and storage %s were overridden", objectStore.Spec.Gateway.Port, size.String())
+	}
+}
+
+func TestValidateCreate(t *testing.T) {
+	block := v1.PersistentVolumeBlock
+
+	tests := []struct {
+		name   string
+		mutate func(o *ObjectStore)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			mutate: func(o *ObjectStore) {},
+		},
+		{
+			name:   "missing volume claim template",
+			mutate: func(o *ObjectStore) { o.Spec.VolumeClaimTemplate = nil },
+			want:   []string{"spec.volumeClaimTemplate"},
+		},
+		{
+			name:   "block volume",
+			mutate: func(o *ObjectStore) { o.Spec.VolumeClaimTemplate.Spec.VolumeMode = &block },
+			want:   []string{"spec.volumeClaimTemplate.spec.volumeMode"},
+		},
+		{
+			name:   "TLS without certificate",
+			mutate: func(o *ObjectStore) { o.Spec.Gateway.TLS = &GatewayTLSSpec{} },
+			want:   []string{"spec.gateway.tls"},
+		},
+		{
+			name:   "secure port without TLS",
+			mutate: func(o *ObjectStore) { o.Spec.Gateway.SecurePort = 8443 },
+			want:   []string{"spec.gateway.tls"},
+		},
+		{
+			name: "same port for http and https",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.TLS = &GatewayTLSSpec{SecretName: "tls"}
+				o.Spec.Gateway.SecurePort = o.Spec.Gateway.Port
+			},
+			want: []string{"spec.gateway.securePort"},
+		},
+		{
+			name: "wildcard expose host",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.Expose = &GatewayExposeSpec{Type: ExposeTypeIngress, Host: "*.s3.example.com"}
+			},
+			want: []string{"spec.gateway.expose.host"},
+		},
+		{
+			name: "HTTPRoute without Gateway",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.Expose = &GatewayExposeSpec{Type: ExposeTypeHTTPRoute, Host: "s3.example.com"}
+			},
+			want: []string{"spec.gateway.expose.parentRef"},
+		},
+		{
+			name: "invalid external endpoint",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.ExternalEndpoints = []string{"https://s3.example.com", "s3.example.com:443"}
+			},
+			want: []string{"spec.gateway.externalEndpoints[1]"},
+		},
+		{
+			name: "multisite behind a NodePort without external endpoints",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.Service = &GatewayServiceSpec{Type: v1.ServiceTypeNodePort}
+				o.Spec.Multisite = &MultisiteSpec{IsMainSite: true}
+			},
+			want: []string{"spec.gateway.externalEndpoints"},
+		},
+		{
+			name: "multisite behind a NodePort with external endpoints",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.Service = &GatewayServiceSpec{Type: v1.ServiceTypeNodePort}
+				o.Spec.Gateway.ExternalEndpoints = []string{"http://node-1.example.com:30080"}
+				o.Spec.Multisite = &MultisiteSpec{IsMainSite: true}
+			},
+		},
+		{
+			name: "restore from both a backup and a target",
+			mutate: func(o *ObjectStore) {
+				o.Spec.RestoreFrom = &RestoreSource{BackupName: "nightly", Target: &BackupTarget{}, Key: "backup.tar.gz"}
+			},
+			want: []string{"spec.restoreFrom"},
+		},
+		{
+			name:   "restore from a target without key",
+			mutate: func(o *ObjectStore) { o.Spec.RestoreFrom = &RestoreSource{Target: &BackupTarget{}} },
+			want:   []string{"spec.restoreFrom.key"},
+		},
+		{
+			name: "restore on a PVC with a data source",
+			mutate: func(o *ObjectStore) {
+				o.Spec.RestoreFrom = &RestoreSource{BackupName: "nightly"}
+				o.Spec.VolumeClaimTemplate.Spec.DataSource = &v1.TypedLocalObjectReference{Kind: "VolumeSnapshot", Name: "snapshot"}
+			},
+			want: []string{"spec.restoreFrom"},
+		},
+		{
+			name: "managed config option",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Config = map[string]string{"rgw frontends": "beast port=80", "rgw_max_chunk_size": "4M"}
+			},
+			want: []string{"spec.config[rgw frontends]"},
+		},
+		{
+			name: "main site joining a realm",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Multisite = &MultisiteSpec{IsMainSite: true, RealmTokenSecretName: "token"}
+			},
+			want: []string{"spec.multisite.realmTokenSecretName"},
+		},
+		{
+			name:   "promoted main site",
+			mutate: func(o *ObjectStore) { o.Spec.Multisite = &MultisiteSpec{IsMainSite: true, Promote: true} },
+			want:   []string{"spec.multisite.realmTokenSecretName"},
+		},
+		{
+			name: "realm token exports of a secondary site",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Multisite = &MultisiteSpec{RealmTokenSecretName: "token", RealmTokenExports: []RealmTokenExport{{Name: "copy"}}}
+			},
+			want: []string{"spec.multisite.realmTokenExports"},
+		},
+		{
+			name: "duplicate realm token exports",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Multisite = &MultisiteSpec{IsMainSite: true, RealmTokenExports: []RealmTokenExport{{Name: "copy"}, {Name: "copy", Format: RealmTokenExportBundle}}}
+			},
+			want: []string{"spec.multisite.realmTokenExports[1]"},
+		},
+		{
+			name: "zone reference with a role",
+			mutate: func(o *ObjectStore) {
+				o.Spec.Multisite = &MultisiteSpec{ZoneRef: "zone", IsMainSite: true}
+			},
+			want: []string{"spec.multisite.zoneRef"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := newValidObjectStore()
+			test.mutate(objectStore)
+			got := invalidFields(t, objectStore.ValidateCreate())
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ValidateCreate() invalid fields %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		old    func(o *ObjectStore)
+		mutate func(o *ObjectStore)
+		want   []string
+	}{
+		{
+			name:   "upgrade",
+			mutate: func(o *ObjectStore) { o.Spec.Image = "quay.io/ceph/ceph:v17.2.5" },
+		},
+		{
+			name:   "downgrade",
+			mutate: func(o *ObjectStore) { o.Spec.Image = "quay.io/ceph/ceph:v16.2.10" },
+			want:   []string{"spec.image"},
+		},
+		{
+			name:   "image without version",
+			mutate: func(o *ObjectStore) { o.Spec.Image = "registry.example.com/ceph:main" },
+		},
+		{
+			name: "PVC expansion",
+			mutate: func(o *ObjectStore) {
+				o.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("20Gi")
+			},
+		},
+		{
+			name: "PVC shrink",
+			mutate: func(o *ObjectStore) {
+				o.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("5Gi")
+			},
+			want: []string{"spec.volumeClaimTemplate.spec.resources.requests.storage"},
+		},
+		{
+			name:   "restore added after creation",
+			mutate: func(o *ObjectStore) { o.Spec.RestoreFrom = &RestoreSource{BackupName: "nightly"} },
+			want:   []string{"spec.restoreFrom"},
+		},
+		{
+			name:   "restore removed after creation",
+			old:    func(o *ObjectStore) { o.Spec.RestoreFrom = &RestoreSource{BackupName: "nightly"} },
+			mutate: func(o *ObjectStore) {},
+		},
+		{
+			name:   "standalone site becoming a main site",
+			mutate: func(o *ObjectStore) { o.Spec.Multisite = &MultisiteSpec{IsMainSite: true} },
+			want:   []string{"spec.multisite"},
+		},
+		{
+			name: "secondary site promoted",
+			old:  func(o *ObjectStore) { o.Spec.Multisite = &MultisiteSpec{RealmTokenSecretName: "token"} },
+			mutate: func(o *ObjectStore) {
+				o.Spec.Multisite = &MultisiteSpec{RealmTokenSecretName: "token", Promote: true}
+			},
+		},
+		{
+			name:   "promoted site demoted",
+			old:    func(o *ObjectStore) { o.Spec.Multisite = &MultisiteSpec{RealmTokenSecretName: "token", Promote: true} },
+			mutate: func(o *ObjectStore) { o.Spec.Multisite = &MultisiteSpec{RealmTokenSecretName: "token"} },
+			want:   []string{"spec.multisite.promote"},
+		},
+		{
+			name:   "invalid spec created before its validation",
+			old:    func(o *ObjectStore) { o.Spec.Config = map[string]string{"rgw_frontends": "beast"} },
+			mutate: func(o *ObjectStore) { o.Finalizers = append(o.Finalizers, "object.rgw-standalone/finalizer") },
+		},
+		{
+			name: "invalid field added to an invalid spec",
+			old:  func(o *ObjectStore) { o.Spec.Config = map[string]string{"rgw_frontends": "beast"} },
+			mutate: func(o *ObjectStore) {
+				o.Spec.Gateway.Expose = &GatewayExposeSpec{Host: "*.example.com"}
+			},
+			want: []string{"spec.gateway.expose.host"},
+		},
+		{
+			name: "finalizer removed from an ObjectStore being deleted",
+			old: func(o *ObjectStore) {
+				o.Spec.Config = map[string]string{"rgw_frontends": "beast"}
+				o.Finalizers = []string{"object.rgw-standalone/finalizer"}
+				now := metav1.Now()
+				o.DeletionTimestamp = &now
+			},
+			mutate: func(o *ObjectStore) { o.Finalizers = nil },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old := newValidObjectStore()
+			if test.old != nil {
+				test.old(old)
+			}
+			objectStore := old.DeepCopy()
+			test.mutate(objectStore)
+			got := invalidFields(t, objectStore.ValidateUpdate(old))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ValidateUpdate() invalid fields %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsImageDowngrade(t *testing.T) {
+	tests := []struct {
+		oldImage string
+		newImage string
+		want     bool
+	}{
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v17.2.5", want: false},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v17.2.3", want: false},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v16.2.10", want: true},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v16.2", want: true},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v16", want: false},
+		{oldImage: "registry.example.com:5000/ceph:17.2.3", newImage: "registry.example.com:5000/ceph:17.2.1", want: true},
+		{oldImage: "registry.example.com:5000/ceph", newImage: "registry.example.com:5000/ceph:17.2.1", want: false},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:main", want: false},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3", newImage: "quay.io/ceph/ceph:v16.2.10@sha256:0123456789abcdef", want: true},
+		{oldImage: "quay.io/ceph/ceph:v17.2.3-20220920", newImage: "quay.io/ceph/ceph:v17.2.3-20220801", want: false},
+	}
+
+	for _, test := range tests {
+		if got := IsImageDowngrade(test.oldImage, test.newImage); got != test.want {
+			t.Errorf("IsImageDowngrade(%q, %q) = %v, want %v", test.oldImage, test.newImage, got, test.want)
+		}
+	}
+}
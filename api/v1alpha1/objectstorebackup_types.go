@@ -0,0 +1,160 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionBackupSucceeded reports whether the last backup run succeeded
+const ConditionBackupSucceeded = "BackupSucceeded"
+
+// ObjectStoreBackupSpec defines the desired state of ObjectStoreBackup
+type ObjectStoreBackupSpec struct {
+	// ObjectStoreName is the name of the ObjectStore, in the same namespace, to back up
+	ObjectStoreName string `json:"objectStoreName"`
+
+	// Schedule is when the backups run, in the cron format, e.g. "0 2 * * *"
+	Schedule string `json:"schedule"`
+
+	// Suspend stops scheduling new backups
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Target is the S3-compatible storage the backups are uploaded to
+	Target BackupTarget `json:"target"`
+
+	// Retention tells which backups are kept on the target
+	// +optional
+	Retention BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupTarget is an S3-compatible storage holding the backups
+type BackupTarget struct {
+	// Endpoint is the URL of the S3 API, e.g. https://s3.example.com
+	Endpoint string `json:"endpoint"`
+
+	// Bucket is the bucket the backups are uploaded to, it must exist
+	Bucket string `json:"bucket"`
+
+	// Prefix is prepended to the name of the backups, it defaults to "<namespace>/<objectStoreName>/"
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Region is the region used to sign the requests
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecretName is the Secret, in the same namespace, holding the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY of the target, such as the one of an ObjectStoreUser
+	CredentialsSecretName string `json:"credentialsSecretName"`
+}
+
+// BackupRetention tells which backups are kept, the most recent backup is always kept
+type BackupRetention struct {
+	// KeepLast is the number of most recent backups kept
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	KeepLast int32 `json:"keepLast,omitempty"`
+
+	// MaxAgeDays deletes the backups older than this number of days
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxAgeDays int32 `json:"maxAgeDays,omitempty"`
+}
+
+// BackupRecord is a backup stored on the target
+type BackupRecord struct {
+	// Key is the key of the archive in the target bucket
+	Key string `json:"key"`
+
+	// Time is when the backup was uploaded
+	Time metav1.Time `json:"time"`
+
+	// Size is the size of the archive in bytes
+	// +optional
+	Size int64 `json:"size,omitempty"`
+}
+
+// ObjectStoreBackupStatus defines the observed state of ObjectStoreBackup
+type ObjectStoreBackupStatus struct {
+	// Phase is a short summary of the ObjectStoreBackup state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectStoreBackup
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// LastScheduleTime is when the last backup was started
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// LastSuccessfulTime is when the last successful backup completed
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// LastBackup is the key of the last successful backup
+	// +optional
+	LastBackup string `json:"lastBackup,omitempty"`
+
+	// Backups are the backups kept on the target, the most recent first
+	// +optional
+	Backups []BackupRecord `json:"backups,omitempty"`
+
+	// LastRetentionTime is when the retention was last applied to the target
+	// +optional
+	LastRetentionTime *metav1.Time `json:"lastRetentionTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="ObjectStore",type=string,JSONPath=`.spec.objectStoreName`
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.lastSuccessfulTime`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectStoreBackup is the Schema for the objectstorebackups API, it uploads archives of the data of
+// an ObjectStore on a schedule
+type ObjectStoreBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectStoreBackupSpec   `json:"spec,omitempty"`
+	Status ObjectStoreBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectStoreBackupList contains a list of ObjectStoreBackup
+type ObjectStoreBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectStoreBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectStoreBackup{}, &ObjectStoreBackupList{})
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ObjectStoreSnapshotSpec defines the desired state of ObjectStoreSnapshot, it cannot be changed
+// once the snapshot is taken
+type ObjectStoreSnapshotSpec struct {
+	// ObjectStoreName is the name of the ObjectStore, in the same namespace, to snapshot
+	ObjectStoreName string `json:"objectStoreName"`
+
+	// VolumeSnapshotClassName is the class of the VolumeSnapshot, the default class of the CSI driver
+	// of the PVC is used when empty
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// Online takes the snapshot without stopping the gateway, the snapshot is then only crash
+	// consistent
+	// +optional
+	Online bool `json:"online,omitempty"`
+
+	// QuiesceTimeoutSeconds is how long the gateway may stay stopped waiting for the snapshot to be
+	// taken, it is started again and the snapshot fails after it
+	// +optional
+	// +kubebuilder:validation:Minimum=30
+	// +kubebuilder:default=300
+	QuiesceTimeoutSeconds int32 `json:"quiesceTimeoutSeconds,omitempty"`
+}
+
+// ObjectStoreSnapshotStatus defines the observed state of ObjectStoreSnapshot
+type ObjectStoreSnapshotStatus struct {
+	// Phase is a short summary of the ObjectStoreSnapshot state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectStoreSnapshot
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// VolumeSnapshotName is the VolumeSnapshot of the PVC, to use as the dataSource of the
+	// volumeClaimTemplate of a new ObjectStore
+	// +optional
+	VolumeSnapshotName string `json:"volumeSnapshotName,omitempty"`
+
+	// QuiesceStartTime is when the gateway was asked to stop for the snapshot
+	// +optional
+	QuiesceStartTime *metav1.Time `json:"quiesceStartTime,omitempty"`
+
+	// CreationTime is when the snapshot was taken by the storage
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// ReadyToUse tells whether a PVC can be created from the snapshot
+	// +optional
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+
+	// RestoreSize is the minimum size of a PVC created from the snapshot
+	// +optional
+	RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="ObjectStore",type=string,JSONPath=`.spec.objectStoreName`
+//+kubebuilder:printcolumn:name="VolumeSnapshot",type=string,JSONPath=`.status.volumeSnapshotName`
+//+kubebuilder:printcolumn:name="Ready To Use",type=boolean,JSONPath=`.status.readyToUse`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectStoreSnapshot is the Schema for the objectstoresnapshots API, it takes a CSI VolumeSnapshot
+// of the PVC of an ObjectStore while its gateway is stopped
+type ObjectStoreSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectStoreSnapshotSpec   `json:"spec,omitempty"`
+	Status ObjectStoreSnapshotStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectStoreSnapshotList contains a list of ObjectStoreSnapshot
+type ObjectStoreSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectStoreSnapshot `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectStoreSnapshot{}, &ObjectStoreSnapshotList{})
+}
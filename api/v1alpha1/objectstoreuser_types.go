@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// OperatorUserID is the user the operator creates in every ObjectStore to call the admin ops API,
+// it cannot be managed through an ObjectStoreUser
+const OperatorUserID = "rgw-operator"
+
+// IsReservedUserID returns whether the user ID belongs to the operator
+func IsReservedUserID(uid string) bool {
+	return uid == OperatorUserID
+}
+
+// ObjectStoreUserSpec defines the desired state of ObjectStoreUser
+type ObjectStoreUserSpec struct {
+	// ObjectStoreName is the name of the ObjectStore, in the same namespace, the user belongs to
+	ObjectStoreName string `json:"objectStoreName"`
+
+	// DisplayName is the display name of the user, it defaults to the name of the ObjectStoreUser
+	// +optional
+	DisplayName string `json:"displayName,omitempty"`
+
+	// Capabilities are the admin capabilities granted to the user
+	// +optional
+	Capabilities *ObjectUserCapabilities `json:"capabilities,omitempty"`
+
+	// MaxBuckets is the maximum number of buckets the user can own, 0 means unlimited
+	// +optional
+	// +nullable
+	MaxBuckets *int `json:"maxBuckets,omitempty"`
+
+	// Quotas limit the space and objects used by the user
+	// +optional
+	Quotas *ObjectUserQuotaSpec `json:"quotas,omitempty"`
+}
+
+// ObjectUserCapabilities are the admin capabilities of a user
+type ObjectUserCapabilities struct {
+	// Users gives access to the user admin operations
+	// +optional
+	// +kubebuilder:validation:Enum={"*","read","write","read, write"}
+	Users string `json:"users,omitempty"`
+
+	// Buckets gives access to the bucket admin operations
+	// +optional
+	// +kubebuilder:validation:Enum={"*","read","write","read, write"}
+	Buckets string `json:"buckets,omitempty"`
+
+	// Metadata gives access to the metadata admin operations
+	// +optional
+	// +kubebuilder:validation:Enum={"*","read","write","read, write"}
+	Metadata string `json:"metadata,omitempty"`
+
+	// Usage gives access to the usage admin operations
+	// +optional
+	// +kubebuilder:validation:Enum={"*","read","write","read, write"}
+	Usage string `json:"usage,omitempty"`
+
+	// Zone gives access to the zone admin operations
+	// +optional
+	// +kubebuilder:validation:Enum={"*","read","write","read, write"}
+	Zone string `json:"zone,omitempty"`
+}
+
+// ObjectUserQuotaSpec is the quota of a user, unset fields are unlimited
+type ObjectUserQuotaSpec struct {
+	// MaxSize is the maximum amount of data the user can store
+	// +optional
+	// +nullable
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// MaxObjects is the maximum number of objects the user can store
+	// +optional
+	// +nullable
+	MaxObjects *int64 `json:"maxObjects,omitempty"`
+}
+
+// ObjectStoreUserStatus defines the observed state of ObjectStoreUser
+type ObjectStoreUserStatus struct {
+	// Phase is a short summary of the ObjectStoreUser state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectStoreUser
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// SecretName is the name of the Secret holding the S3 credentials of the user
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Usage is the space used by the user, refreshed every few minutes
+	// +optional
+	Usage *ObjectUserUsage `json:"usage,omitempty"`
+}
+
+// ObjectUserUsage is the space used by all the buckets of a user
+type ObjectUserUsage struct {
+	// Size is the size of the objects in bytes
+	Size int64 `json:"size"`
+
+	// Objects is the number of objects
+	Objects int64 `json:"objects"`
+
+	// Buckets is the number of buckets owned by the user
+	Buckets int32 `json:"buckets"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="ObjectStore",type=string,JSONPath=`.spec.objectStoreName`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectStoreUser is the Schema for the objectstoreusers API
+type ObjectStoreUser struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectStoreUserSpec   `json:"spec,omitempty"`
+	Status ObjectStoreUserStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectStoreUserList contains a list of ObjectStoreUser
+type ObjectStoreUserList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectStoreUser `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectStoreUser{}, &ObjectStoreUserList{})
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var objectstoreuserlog = logf.Log.WithName("objectstoreuser-resource")
+
+func (r *ObjectStoreUser) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-object-rgw-standalone-v1alpha1-objectstoreuser,mutating=false,failurePolicy=fail,sideEffects=None,groups=object.rgw-standalone,resources=objectstoreusers,verbs=create,versions=v1alpha1,name=vobjectstoreuser.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &ObjectStoreUser{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStoreUser) ValidateCreate() error {
+	objectstoreuserlog.Info("validate create", "name", r.Name)
+
+	// The name is the user ID and cannot change, it is only checked on creation
+	if IsReservedUserID(r.Name) {
+		return apierrors.NewInvalid(GroupVersion.WithKind("ObjectStoreUser").GroupKind(), r.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("metadata", "name"), fmt.Sprintf("user %q is reserved for the operator", r.Name)),
+		})
+	}
+
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStoreUser) ValidateUpdate(old runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ObjectStoreUser) ValidateDelete() error {
+	return nil
+}
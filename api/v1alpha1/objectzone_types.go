@@ -0,0 +1,93 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ObjectZoneSpec defines the desired state of ObjectZone, the zone is named after the resource and
+// served by the ObjectStore referencing it
+type ObjectZoneSpec struct {
+	// ZoneGroupRef is the name of the ObjectZoneGroup, in the same namespace, the zone belongs to
+	ZoneGroupRef string `json:"zoneGroupRef"`
+
+	// Master is true for the master zone of the zonegroup. The master zone of the master zonegroup
+	// bootstraps the realm, the other zones join it with the realm token.
+	// +optional
+	Master bool `json:"master,omitempty"`
+}
+
+// ObjectZoneStatus defines the observed state of ObjectZone
+type ObjectZoneStatus struct {
+	// Phase is a short summary of the ObjectZone state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectZone
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Realm is the ObjectRealm of the zonegroup of the zone
+	// +optional
+	Realm string `json:"realm,omitempty"`
+
+	// ObjectStore is the ObjectStore serving the zone
+	// +optional
+	ObjectStore string `json:"objectStore,omitempty"`
+
+	// Endpoints are the URLs the zone is advertised with in the realm
+	// +optional
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="ZoneGroup",type=string,JSONPath=`.spec.zoneGroupRef`
+//+kubebuilder:printcolumn:name="Realm",type=string,JSONPath=`.status.realm`
+//+kubebuilder:printcolumn:name="Master",type=boolean,JSONPath=`.spec.master`
+//+kubebuilder:printcolumn:name="ObjectStore",type=string,JSONPath=`.status.objectStore`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectZone is the Schema for the objectzones API, it is a zone of an ObjectZoneGroup
+type ObjectZone struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectZoneSpec   `json:"spec,omitempty"`
+	Status ObjectZoneStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectZoneList contains a list of ObjectZone
+type ObjectZoneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectZone `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectZone{}, &ObjectZoneList{})
+}
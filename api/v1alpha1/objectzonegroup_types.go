@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ObjectZoneGroupSpec defines the desired state of ObjectZoneGroup, the zonegroup is named after
+// the resource
+type ObjectZoneGroupSpec struct {
+	// RealmRef is the name of the ObjectRealm, in the same namespace, the zonegroup belongs to
+	RealmRef string `json:"realmRef"`
+
+	// Master is true for the master zonegroup of the realm, it is created with the realm. The other
+	// zonegroups are created by the master zone of the realm.
+	// +optional
+	Master bool `json:"master,omitempty"`
+}
+
+// ObjectZoneGroupStatus defines the observed state of ObjectZoneGroup
+type ObjectZoneGroupStatus struct {
+	// Phase is a short summary of the ObjectZoneGroup state, the conditions hold the details
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest available observations of the ObjectZoneGroup
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// MasterZone is the ObjectZone marked as master of the zonegroup
+	// +optional
+	MasterZone string `json:"masterZone,omitempty"`
+
+	// Zones are the ObjectZones of the zonegroup
+	// +optional
+	Zones []string `json:"zones,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Realm",type=string,JSONPath=`.spec.realmRef`
+//+kubebuilder:printcolumn:name="Master",type=boolean,JSONPath=`.spec.master`
+//+kubebuilder:printcolumn:name="Master Zone",type=string,JSONPath=`.status.masterZone`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObjectZoneGroup is the Schema for the objectzonegroups API, it is a zonegroup of an ObjectRealm
+// whose zones replicate the same buckets
+type ObjectZoneGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObjectZoneGroupSpec   `json:"spec,omitempty"`
+	Status ObjectZoneGroupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObjectZoneGroupList contains a list of ObjectZoneGroup
+type ObjectZoneGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObjectZoneGroup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObjectZoneGroup{}, &ObjectZoneGroupList{})
+}
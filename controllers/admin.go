@@ -0,0 +1,171 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syscall"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"github.com/redhat-et/rgw-standalone-operator/pkg/admin"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// adminUserID is the user of the operator, its keys sign the requests to the admin ops API
+	adminUserID = objectv1alpha1.OperatorUserID
+
+	// adminUserCaps are the capabilities the operator needs to manage the users and buckets
+	adminUserCaps = "buckets=*;metadata=read;usage=read;users=*"
+)
+
+// radosgwAdmin runs radosgw-admin in the gateway pod of the ObjectStore and returns its output, it is
+// only used to bootstrap what the admin ops API cannot do without credentials
+func radosgwAdmin(ctx context.Context, executor *RemotePodCommandExecutor, objectStore *objectv1alpha1.ObjectStore, args ...string) (string, error) {
+	stdout, stderr, err := executor.ExecCommandInContainerWithFullOutputWithTimeout(
+		ctx,
+		getLabelString(objectStore.Name),
+		"rgw",
+		objectStore.Namespace,
+		append([]string{"radosgw-admin-sqlite"}, args...)...,
+	)
+	if err != nil {
+		return stdout, fmt.Errorf("failed to run radosgw-admin %s: %s: %w", subcommand(args), lastLine(stderr), err)
+	}
+
+	return stdout, nil
+}
+
+// subcommand returns the subcommand of radosgw-admin in the arguments, e.g. "user info"
+func subcommand(args []string) string {
+	if len(args) > 2 {
+		args = args[:2]
+	}
+	return strings.Join(args, " ")
+}
+
+// lastLine returns the last line of the output of a command, the previous lines of stderr are
+// debug logs
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
+
+// isAdminNotFound returns whether radosgw-admin failed because the entity does not exist
+func isAdminNotFound(err error) bool {
+	code, codeErr := extractExitCode(err)
+	return codeErr == nil && code == int(syscall.ENOENT)
+}
+
+// reconcileAdminUser creates the user of the operator in the gateway and writes its keys in a Secret
+// owned by the ObjectStore. The user is created from the pod once, every other admin operation goes
+// through the admin ops API with its keys.
+func (r *ObjectStoreReconciler) reconcileAdminUser(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	secret := adminSecretMeta(objectStore)
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+	if err == nil && len(secret.Data["AWS_ACCESS_KEY_ID"]) > 0 {
+		return nil
+	}
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get admin secret %q: %w", secret.Name, err)
+	}
+
+	output, err := radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "user", "info", fmt.Sprintf("--uid=%s", adminUserID))
+	if err != nil {
+		if !isAdminNotFound(err) {
+			return err
+		}
+		output, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "user", "create",
+			fmt.Sprintf("--uid=%s", adminUserID),
+			"--display-name=rgw-standalone operator",
+			fmt.Sprintf("--caps=%s", adminUserCaps),
+		)
+		if err != nil {
+			return err
+		}
+		r.Logger.Info("successfully created admin user", "uid", adminUserID)
+	}
+
+	user := &admin.User{}
+	err = json.Unmarshal([]byte(output), user)
+	if err != nil {
+		return fmt.Errorf("failed to parse admin user info: %w", err)
+	}
+	if len(user.Keys) == 0 {
+		return fmt.Errorf("admin user %q has no S3 key", adminUserID)
+	}
+
+	err = controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+	}
+
+	mutateFunc := func() error {
+		secret.Labels = getLabels(objectStore.Name)
+		secret.Type = v1.SecretTypeOpaque
+		secret.Data = map[string][]byte{
+			"AWS_ACCESS_KEY_ID":     []byte(user.Keys[0].AccessKey),
+			"AWS_SECRET_ACCESS_KEY": []byte(user.Keys[0].SecretKey),
+		}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create or update admin secret %q: %w", secret.Name, err)
+	}
+	r.Logger.Info("admin ops credentials", "Secret", secret.Name, "opResult", opResult)
+
+	return nil
+}
+
+// adminClient returns a client of the admin ops API of the ObjectStore
+func adminClient(ctx context.Context, c client.Client, objectStore *objectv1alpha1.ObjectStore) (*admin.Client, error) {
+	secret := adminSecretMeta(objectStore)
+	err := c.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin secret %q of ObjectStore %q: %w", secret.Name, objectStore.Name, err)
+	}
+
+	httpClient, err := gatewayHTTPClient(ctx, c, objectStore)
+	if err != nil {
+		return nil, err
+	}
+
+	return admin.NewClient(
+		serviceEndpoint(objectStore),
+		string(secret.Data["AWS_ACCESS_KEY_ID"]),
+		string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+		httpClient,
+	), nil
+}
+
+func adminSecretMeta(objectStore *objectv1alpha1.ObjectStore) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-admin", instanceName(objectStore.Name, objectStore.Namespace)),
+			Namespace: objectStore.Namespace,
+		},
+	}
+}
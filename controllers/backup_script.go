@@ -0,0 +1,157 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+// s3Script sends requests signed with the AWS Signature Version 4 to the S3 target, so that the
+// backup and restore jobs do not need any S3 tool. It runs with the image of the gateway, which ships
+// python.
+const s3Script = `
+import datetime, hashlib, hmac, http.client, os, shutil, ssl, sqlite3, sys, tarfile, urllib.parse
+
+data_dir = os.environ["DATA_DIR"]
+staging_dir = os.environ["STAGING_DIR"]
+
+
+def sign(key, message):
+    return hmac.new(key, message.encode(), hashlib.sha256).digest()
+
+
+def s3_request(method, body=None, payload_hash=hashlib.sha256(b"").hexdigest(), size=0):
+    endpoint = urllib.parse.urlparse(os.environ["S3_ENDPOINT"])
+    region = os.environ.get("S3_REGION") or "us-east-1"
+    object_path = endpoint.path.rstrip("/") + "/" + urllib.parse.quote(os.environ["S3_BUCKET"], safe="~") + "/" + urllib.parse.quote(os.environ["S3_KEY"], safe="/~")
+
+    now = datetime.datetime.utcnow()
+    amz_date = now.strftime("%Y%m%dT%H%M%SZ")
+    day = now.strftime("%Y%m%d")
+    headers = {"host": endpoint.netloc, "x-amz-content-sha256": payload_hash, "x-amz-date": amz_date}
+    signed_headers = ";".join(sorted(headers))
+    canonical_request = "\n".join([
+        method, object_path, "",
+        "".join("%s:%s\n" % (name, headers[name]) for name in sorted(headers)),
+        signed_headers, payload_hash,
+    ])
+    scope = "%s/%s/s3/aws4_request" % (day, region)
+    string_to_sign = "\n".join(["AWS4-HMAC-SHA256", amz_date, scope, hashlib.sha256(canonical_request.encode()).hexdigest()])
+    key = ("AWS4" + os.environ["AWS_SECRET_ACCESS_KEY"]).encode()
+    for part in (day, region, "s3", "aws4_request"):
+        key = sign(key, part)
+    signature = hmac.new(key, string_to_sign.encode(), hashlib.sha256).hexdigest()
+    headers["authorization"] = "AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s" % (
+        os.environ["AWS_ACCESS_KEY_ID"], scope, signed_headers, signature)
+    if body is not None:
+        headers["content-length"] = str(size)
+
+    if endpoint.scheme == "https":
+        conn = http.client.HTTPSConnection(endpoint.netloc, context=ssl.create_default_context())
+    else:
+        conn = http.client.HTTPConnection(endpoint.netloc)
+    conn.request(method, object_path, body=body, headers=headers)
+    response = conn.getresponse()
+    if response.status >= 300:
+        sys.exit("%s %s failed: %d %s" % (method, os.environ["S3_KEY"], response.status, response.read().decode(errors="replace")))
+    return response
+
+
+def is_sqlite(path):
+    with open(path, "rb") as f:
+        return f.read(16) == b"SQLite format 3\x00"
+
+
+def is_journal(name):
+    return name.endswith(("-wal", "-shm", "-journal"))
+`
+
+// backupScript archives the data directory and uploads it to the S3 target while the gateway keeps
+// serving: the SQLite databases are copied with the online backup API so that the copy is
+// consistent, and their journals are skipped.
+const backupScript = s3Script + `
+excluded = set(os.environ.get("EXCLUDED_DIRS", "").split(","))
+
+
+def archive(path):
+    with tarfile.open(path, "w:gz") as tar:
+        for root, dirs, files in os.walk(data_dir):
+            if root == data_dir:
+                dirs[:] = [d for d in dirs if d not in excluded]
+            for name in sorted(files):
+                source = os.path.join(root, name)
+                arcname = os.path.relpath(source, data_dir)
+                if is_journal(name):
+                    continue
+                if not is_sqlite(source):
+                    tar.add(source, arcname)
+                    continue
+                copy = os.path.join(staging_dir, "copy.db")
+                src = sqlite3.connect(source)
+                dst = sqlite3.connect(copy)
+                src.backup(dst)
+                dst.close()
+                src.close()
+                tar.add(copy, arcname)
+                os.remove(copy)
+
+
+path = os.path.join(staging_dir, "backup.tar.gz")
+archive(path)
+digest = hashlib.sha256()
+with open(path, "rb") as f:
+    for chunk in iter(lambda: f.read(1 << 20), b""):
+        digest.update(chunk)
+with open(path, "rb") as f:
+    s3_request("PUT", body=f, payload_hash=digest.hexdigest(), size=os.path.getsize(path)).read()
+print("uploaded %s (%d bytes) to %s" % (os.environ["S3_KEY"], os.path.getsize(path), os.environ["S3_BUCKET"]))
+`
+
+// restoreScript downloads an archive made by backupScript, replaces the content of the data directory
+// with it and checks the integrity of the SQLite databases, the gateway must not be running
+const restoreScript = s3Script + `
+path = os.path.join(staging_dir, "restore.tar.gz")
+with open(path, "wb") as f:
+    shutil.copyfileobj(s3_request("GET"), f)
+
+with tarfile.open(path) as tar:
+    members = tar.getmembers()
+    for member in members:
+        target = os.path.realpath(os.path.join(data_dir, member.name))
+        if not target.startswith(os.path.realpath(data_dir) + os.sep) or member.issym() or member.islnk():
+            sys.exit("refusing to restore unsafe archive entry %s" % member.name)
+
+    for entry in os.listdir(data_dir):
+        if entry == "lost+found":
+            continue
+        entry_path = os.path.join(data_dir, entry)
+        if os.path.isdir(entry_path) and not os.path.islink(entry_path):
+            shutil.rmtree(entry_path)
+        else:
+            os.remove(entry_path)
+    tar.extractall(data_dir, members)
+
+for root, dirs, files in os.walk(data_dir):
+    for name in files:
+        db_path = os.path.join(root, name)
+        if is_journal(name) or not is_sqlite(db_path):
+            continue
+        conn = sqlite3.connect(db_path)
+        result = conn.execute("PRAGMA integrity_check").fetchone()[0]
+        conn.close()
+        if result != "ok":
+            sys.exit("integrity check of %s failed: %s" % (os.path.relpath(db_path, data_dir), result))
+        print("integrity check of %s passed" % os.path.relpath(db_path, data_dir))
+
+print("restored %s from %s" % (os.environ["S3_KEY"], os.environ["S3_BUCKET"]))
+`
@@ -0,0 +1,495 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"github.com/redhat-et/rgw-standalone-operator/pkg/s3"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reasons used in the Bucket conditions
+const (
+	reasonUserNotFound     = "UserNotFound"
+	reasonUserNotReady     = "UserNotReady"
+	reasonBucketReconciled = "BucketReconciled"
+	reasonDriftCorrected   = "DriftCorrected"
+	reasonNoDrift          = "NoDrift"
+)
+
+const (
+	// userNameIndex indexes the Buckets by the ObjectStoreUser owning them
+	userNameIndex = ".spec.userName"
+
+	// bucketResyncInterval is how often the buckets are checked for drift, changes made through
+	// the S3 API do not trigger any event
+	bucketResyncInterval = 5 * time.Minute
+)
+
+// BucketReconciler reconciles a Bucket object
+type BucketReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=buckets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=buckets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=buckets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoreusers,verbs=get;list;watch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BucketReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &objectv1alpha1.Bucket{}, userNameIndex, func(object client.Object) []string {
+		return []string{object.(*objectv1alpha1.Bucket).Spec.UserName}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index Buckets by ObjectStoreUser: %w", err)
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.Bucket{}).
+		// Buckets are created as soon as the credentials of their owner are available
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectStoreUser{}}, handler.EnqueueRequestsFromMapFunc(r.bucketsForUser)).
+		Complete(r)
+}
+
+// bucketsForUser maps an ObjectStoreUser to the buckets it owns
+func (r *BucketReconciler) bucketsForUser(object client.Object) []reconcile.Request {
+	buckets := &objectv1alpha1.BucketList{}
+	err := r.Client.List(context.Background(), buckets,
+		client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{userNameIndex: object.GetName()},
+	)
+	if err != nil {
+		r.Logger.Error(err, "failed to list Buckets of ObjectStoreUser", "ObjectStoreUser", object.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(buckets.Items))
+	for _, bucket := range buckets.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&bucket)})
+	}
+
+	return requests
+}
+
+// Reconcile creates the bucket and converges its configuration against the spec
+func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("Bucket", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	bucket := &objectv1alpha1.Bucket{}
+	err := r.Client.Get(ctx, req.NamespacedName, bucket)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("Bucket resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get Bucket: %w", err)
+	}
+
+	finalizerName := buildFinalizerName("Bucket")
+
+	// DELETE: the CR was deleted
+	if !bucket.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.ContainsFinalizer(bucket, finalizerName) {
+			return reconcile.Result{}, nil
+		}
+
+		if bucket.Spec.DeletionPolicy == objectv1alpha1.BucketDeletionPolicyDelete {
+			err = r.deleteBucket(ctx, bucket)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(bucket, finalizerName)
+		err = r.Client.Update(ctx, bucket)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+		}
+
+		r.Logger.Info("successfully deleted Bucket " + req.NamespacedName.String())
+		return reconcile.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(bucket, finalizerName) {
+		controllerutil.AddFinalizer(bucket, finalizerName)
+		err = r.Client.Update(ctx, bucket)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	original := bucket.DeepCopy()
+	err = r.reconcileBucket(ctx, bucket)
+	if err != nil {
+		setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	bucket.Status.Phase = phaseFromConditions(bucket.Status.Conditions, err)
+	bucket.Status.ObservedGeneration = bucket.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, bucket, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of Bucket %q: %w", bucket.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "Bucket", req.NamespacedName.String(), "Phase", bucket.Status.Phase)
+	return reconcile.Result{RequeueAfter: bucketResyncInterval}, nil
+}
+
+// reconcileBucket creates the bucket if needed and applies every setting that differs from the
+// spec. Differences found while the spec is unchanged since the last reconcile are reported as drift.
+func (r *BucketReconciler) reconcileBucket(ctx context.Context, bucket *objectv1alpha1.Bucket) error {
+	s3Client, ready, err := r.bucketClient(ctx, bucket)
+	if err != nil || !ready {
+		return err
+	}
+
+	name := bucketName(bucket)
+	exists, err := s3Client.BucketExists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check bucket %q: %w", name, err)
+	}
+	if !exists {
+		err = s3Client.CreateBucket(ctx, name, objectLockEnabled(bucket))
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %q: %w", name, err)
+		}
+		r.Logger.Info("successfully created bucket", "bucket", name)
+	}
+	bucket.Status.BucketName = name
+
+	changed, err := r.applyBucketConfiguration(ctx, s3Client, bucket, name)
+	if err != nil {
+		return err
+	}
+
+	// Only an existing bucket whose spec was already applied can drift
+	if exists && bucket.Status.ObservedGeneration == bucket.Generation && len(changed) > 0 {
+		bucket.Status.Drift = changed
+		r.Logger.Info("corrected bucket drift", "bucket", name, "settings", changed)
+		setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionDriftDetected, metav1.ConditionTrue, reasonDriftCorrected, fmt.Sprintf("settings changed outside of the operator were reverted: %s", strings.Join(changed, ", ")))
+	} else {
+		bucket.Status.Drift = nil
+		setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionDriftDetected, metav1.ConditionFalse, reasonNoDrift, "bucket matches the spec")
+	}
+
+	setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonBucketReconciled, fmt.Sprintf("bucket %q is available", name))
+	return nil
+}
+
+// bucketClient returns an S3 client authenticated as the owner of the bucket, it returns false
+// when the ObjectStore or the owner are not ready yet, their watch triggers a new reconcile
+func (r *BucketReconciler) bucketClient(ctx context.Context, bucket *objectv1alpha1.Bucket) (*s3.Client, bool, error) {
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: bucket.Namespace, Name: bucket.Spec.ObjectStoreName}, objectStore)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotFound, fmt.Sprintf("ObjectStore %q not found", bucket.Spec.ObjectStoreName))
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get ObjectStore %q: %w", bucket.Spec.ObjectStoreName, err)
+	}
+	if !meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionReady) {
+		setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotReady, fmt.Sprintf("waiting for ObjectStore %q to be ready", objectStore.Name))
+		return nil, false, nil
+	}
+
+	user := &objectv1alpha1.ObjectStoreUser{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: bucket.Namespace, Name: bucket.Spec.UserName}, user)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonUserNotFound, fmt.Sprintf("ObjectStoreUser %q not found", bucket.Spec.UserName))
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to get ObjectStoreUser %q: %w", bucket.Spec.UserName, err)
+	}
+	if user.Spec.ObjectStoreName != bucket.Spec.ObjectStoreName {
+		return nil, false, fmt.Errorf("ObjectStoreUser %q belongs to ObjectStore %q, not %q", user.Name, user.Spec.ObjectStoreName, bucket.Spec.ObjectStoreName)
+	}
+	if !meta.IsStatusConditionTrue(user.Status.Conditions, objectv1alpha1.ConditionReady) || user.Status.SecretName == "" {
+		setStatusCondition(&bucket.Status.Conditions, bucket.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonUserNotReady, fmt.Sprintf("waiting for ObjectStoreUser %q to be ready", user.Name))
+		return nil, false, nil
+	}
+
+	secret := &v1.Secret{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: bucket.Namespace, Name: user.Status.SecretName}, secret)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get credentials secret %q of ObjectStoreUser %q: %w", user.Status.SecretName, user.Name, err)
+	}
+
+	httpClient, err := gatewayHTTPClient(ctx, r.Client, objectStore)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return s3.NewClient(
+		serviceEndpoint(objectStore),
+		string(secret.Data["AWS_ACCESS_KEY_ID"]),
+		string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+		httpClient,
+	), true, nil
+}
+
+// applyBucketConfiguration applies the settings of the spec and returns the ones that changed
+func (r *BucketReconciler) applyBucketConfiguration(ctx context.Context, s3Client *s3.Client, bucket *objectv1alpha1.Bucket, name string) ([]string, error) {
+	changed := []string{}
+
+	if bucket.Spec.Versioning != "" {
+		status, err := s3Client.GetBucketVersioning(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get versioning of bucket %q: %w", name, err)
+		}
+		if status != bucket.Spec.Versioning {
+			err = s3Client.PutBucketVersioning(ctx, name, bucket.Spec.Versioning)
+			if err != nil {
+				return nil, fmt.Errorf("failed to set versioning of bucket %q: %w", name, err)
+			}
+			changed = append(changed, "versioning")
+		}
+	}
+
+	objectLockChanged, err := r.applyObjectLock(ctx, s3Client, bucket, name)
+	if err != nil {
+		return nil, err
+	}
+	if objectLockChanged {
+		changed = append(changed, "objectLock")
+	}
+
+	policyChanged, err := applyBucketPolicy(ctx, s3Client, bucket.Spec.Policy, name)
+	if err != nil {
+		return nil, err
+	}
+	if policyChanged {
+		changed = append(changed, "policy")
+	}
+
+	currentCORS, err := s3Client.GetBucketCors(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CORS rules of bucket %q: %w", name, err)
+	}
+	desiredCORS := corsRules(bucket.Spec.CORSRules)
+	if !sameJSON(currentCORS, desiredCORS) {
+		err = s3Client.PutBucketCors(ctx, name, desiredCORS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set CORS rules of bucket %q: %w", name, err)
+		}
+		changed = append(changed, "corsRules")
+	}
+
+	currentLifecycle, err := s3Client.GetBucketLifecycle(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lifecycle rules of bucket %q: %w", name, err)
+	}
+	desiredLifecycle := lifecycleRules(bucket.Spec.LifecycleRules)
+	if !sameJSON(currentLifecycle, desiredLifecycle) {
+		err = s3Client.PutBucketLifecycle(ctx, name, desiredLifecycle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set lifecycle rules of bucket %q: %w", name, err)
+		}
+		changed = append(changed, "lifecycleRules")
+	}
+
+	return changed, nil
+}
+
+// applyObjectLock sets the default retention of a bucket created with object lock
+func (r *BucketReconciler) applyObjectLock(ctx context.Context, s3Client *s3.Client, bucket *objectv1alpha1.Bucket, name string) (bool, error) {
+	if !objectLockEnabled(bucket) {
+		return false, nil
+	}
+
+	current, err := s3Client.GetObjectLockConfiguration(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get object lock of bucket %q: %w", name, err)
+	}
+	if current == nil || current.ObjectLockEnabled != "Enabled" {
+		return false, fmt.Errorf("object lock can only be enabled when bucket %q is created", name)
+	}
+
+	desired := &s3.ObjectLockConfiguration{ObjectLockEnabled: "Enabled"}
+	if retention := bucket.Spec.ObjectLock.DefaultRetention; retention != nil {
+		desired.Rule = &s3.ObjectLockRule{DefaultRetention: s3.DefaultRetention{
+			Mode:  retention.Mode,
+			Days:  retention.Days,
+			Years: retention.Years,
+		}}
+	}
+	if reflect.DeepEqual(current.Rule, desired.Rule) {
+		return false, nil
+	}
+
+	err = s3Client.PutObjectLockConfiguration(ctx, name, desired)
+	if err != nil {
+		return false, fmt.Errorf("failed to set object lock of bucket %q: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// applyBucketPolicy replaces the policy of the bucket unless it is semantically the same
+func applyBucketPolicy(ctx context.Context, s3Client *s3.Client, policy, name string) (bool, error) {
+	current, err := s3Client.GetBucketPolicy(ctx, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to get policy of bucket %q: %w", name, err)
+	}
+
+	if policy == "" {
+		if current == "" {
+			return false, nil
+		}
+		err = s3Client.DeleteBucketPolicy(ctx, name)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete policy of bucket %q: %w", name, err)
+		}
+		return true, nil
+	}
+
+	var desiredDoc, currentDoc interface{}
+	err = json.Unmarshal([]byte(policy), &desiredDoc)
+	if err != nil {
+		return false, fmt.Errorf("invalid policy for bucket %q: %w", name, err)
+	}
+	// An unparsable policy is replaced like a different one
+	if current != "" && json.Unmarshal([]byte(current), &currentDoc) == nil && reflect.DeepEqual(currentDoc, desiredDoc) {
+		return false, nil
+	}
+
+	err = s3Client.PutBucketPolicy(ctx, name, policy)
+	if err != nil {
+		return false, fmt.Errorf("failed to set policy of bucket %q: %w", name, err)
+	}
+
+	return true, nil
+}
+
+// deleteBucket deletes the bucket, it is left in place if the owner can no longer access it
+func (r *BucketReconciler) deleteBucket(ctx context.Context, bucket *objectv1alpha1.Bucket) error {
+	s3Client, ready, err := r.bucketClient(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		r.Logger.Info("owner of the bucket is not available, retaining bucket", "bucket", bucketName(bucket))
+		return nil
+	}
+
+	err = s3Client.DeleteBucket(ctx, bucketName(bucket))
+	if err != nil {
+		return fmt.Errorf("failed to delete bucket %q: %w", bucketName(bucket), err)
+	}
+	r.Logger.Info("successfully deleted bucket", "bucket", bucketName(bucket))
+
+	return nil
+}
+
+// bucketName returns the name of the bucket in the ObjectStore
+func bucketName(bucket *objectv1alpha1.Bucket) string {
+	if bucket.Spec.BucketName != "" {
+		return bucket.Spec.BucketName
+	}
+	return bucket.Name
+}
+
+func objectLockEnabled(bucket *objectv1alpha1.Bucket) bool {
+	return bucket.Spec.ObjectLock != nil && bucket.Spec.ObjectLock.Enabled
+}
+
+func corsRules(rules []objectv1alpha1.BucketCORSRule) []s3.CORSRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	s3Rules := make([]s3.CORSRule, 0, len(rules))
+	for _, rule := range rules {
+		s3Rules = append(s3Rules, s3.CORSRule{
+			ID:             rule.ID,
+			AllowedOrigins: rule.AllowedOrigins,
+			AllowedMethods: rule.AllowedMethods,
+			AllowedHeaders: rule.AllowedHeaders,
+			ExposeHeaders:  rule.ExposeHeaders,
+			MaxAgeSeconds:  rule.MaxAgeSeconds,
+		})
+	}
+
+	return s3Rules
+}
+
+func lifecycleRules(rules []objectv1alpha1.BucketLifecycleRule) []s3.LifecycleRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	s3Rules := make([]s3.LifecycleRule, 0, len(rules))
+	for _, rule := range rules {
+		s3Rule := s3.LifecycleRule{
+			ID:     rule.ID,
+			Filter: &s3.LifecycleFilter{Prefix: rule.Prefix},
+			Status: "Disabled",
+		}
+		if rule.Enabled {
+			s3Rule.Status = "Enabled"
+		}
+		if rule.ExpirationDays > 0 {
+			s3Rule.Expiration = &s3.LifecycleExpiration{Days: rule.ExpirationDays}
+		}
+		if rule.NoncurrentVersionExpirationDays > 0 {
+			s3Rule.NoncurrentVersionExpiration = &s3.NoncurrentVersionExpiration{NoncurrentDays: rule.NoncurrentVersionExpirationDays}
+		}
+		if rule.AbortIncompleteMultipartUploadDays > 0 {
+			s3Rule.AbortIncompleteMultipartUpload = &s3.AbortIncompleteMultipartUpload{DaysAfterInitiation: rule.AbortIncompleteMultipartUploadDays}
+		}
+		s3Rules = append(s3Rules, s3Rule)
+	}
+
+	return s3Rules
+}
+
+// sameJSON compares two configurations through their JSON form, which ignores the XML details
+// such as namespaces
+func sameJSON(a, b interface{}) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// realmLeaveTimeout is how long after the deletion of a secondary site its zone is retried to be
+// removed from the realm, the main site may be lost for good
+const realmLeaveTimeout = 10 * time.Minute
+
+// skipRealmLeaveAnnotation deletes a secondary site without removing its zone from the realm, e.g.
+// when its main site is known to be lost
+var skipRealmLeaveAnnotation = fmt.Sprintf("%s/skip-realm-leave", objectv1alpha1.GroupVersion.Group)
+
+// cleanupObjectStore tears down what the garbage collector cannot handle on its own before the
+// finalizer is released
+func (r *ObjectStoreReconciler) cleanupObjectStore(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	// The realm token exports of other namespaces cannot be owned by the ObjectStore
+	err := r.deleteRealmTokenExports(ctx, objectStore, nil)
+	if err != nil {
+		return err
+	}
+
+	// The role of a site serving an ObjectZone is resolved on a copy, the ObjectStore is updated to
+	// release its finalizer
+	if objectStore.Spec.HasZoneRef() {
+		objectStore = objectStore.DeepCopy()
+		_, err := r.resolveZone(ctx, objectStore)
+		if err != nil {
+			r.Logger.Info("failed to resolve zone, it must be removed from the realm manually", "Zone", zoneName(objectStore), "error", err.Error())
+			deleteSyncMetrics(objectStore)
+			return r.cleanupPVC(ctx, objectStore)
+		}
+	}
+
+	// A secondary site leaves the realm while its gateway is still around, a promoted one is the
+	// master and cannot be removed from its zonegroup
+	if objectStore.Spec.IsMultisite() && !objectStore.Spec.IsPromoted() {
+		err := r.leaveRealm(ctx, objectStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	if objectStore.Spec.IsMainSite() {
+		err := r.deleteRealmTokenSecret(ctx, objectStore)
+		if err != nil {
+			return err
+		}
+	}
+
+	deleteSyncMetrics(objectStore)
+
+	return r.cleanupPVC(ctx, objectStore)
+}
+
+// leaveRealm removes the zone of a secondary site from the realm on a best effort basis, the site
+// is often deleted because its main site is gone. The zone is left in the realm when the ObjectStore
+// has the skip annotation, or when it still cannot be removed realmLeaveTimeout after the deletion.
+func (r *ObjectStoreReconciler) leaveRealm(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	if objectStore.Annotations[skipRealmLeaveAnnotation] == "true" {
+		r.Logger.Info("not removing the zone from the realm, it must be removed manually", "Zone", zoneName(objectStore), "annotation", skipRealmLeaveAnnotation)
+		return nil
+	}
+
+	err := r.removeZoneFromRealm(ctx, objectStore)
+	if err == nil {
+		return nil
+	}
+
+	deadline := objectStore.GetDeletionTimestamp().Add(realmLeaveTimeout)
+	if time.Now().After(deadline) {
+		r.Logger.Error(err, "giving up removing the zone from the realm, it must be removed manually", "Zone", zoneName(objectStore))
+		return nil
+	}
+
+	return fmt.Errorf("failed to remove zone %q from the realm, retrying until %s or until the ObjectStore has the %s=true annotation: %w",
+		zoneName(objectStore), deadline.UTC().Format(time.RFC3339), skipRealmLeaveAnnotation, err)
+}
+
+// removeZoneFromRealm removes the zone of a secondary site from its zonegroup and commits the
+// period on the main site, so that the main site stops syncing with a zone that is going away
+func (r *ObjectStoreReconciler) removeZoneFromRealm(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	if !meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionMultisiteConfigured) {
+		r.Logger.Info("zone was never created, nothing to remove from the realm", "Zone", zoneName(objectStore))
+		return nil
+	}
+
+	_, ready, err := r.getReadyPod(ctx, objectStore)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		r.Logger.Info("no gateway ready, the zone must be removed from the realm manually", "Zone", zoneName(objectStore))
+		return nil
+	}
+
+	secret, err := r.getRealmTokenSecret(ctx, objectStore)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("realm token secret not found, the zone must be removed from the realm manually", "Zone", zoneName(objectStore))
+			return nil
+		}
+		return err
+	}
+
+	// The period commit is forwarded to the main site with the realm system user credentials
+	tokenInfo, err := decodeRealmToken(string(secret.Data["token"]))
+	if err != nil {
+		return err
+	}
+
+	_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, append([]string{"zonegroup", "remove"}, zoneArgs(objectStore, tokenInfo.RealmName)...)...)
+	if err != nil {
+		// The zone is already gone from the zonegroup
+		if !isAdminNotFound(err) {
+			return err
+		}
+	}
+	_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "period", "update", "--commit",
+		fmt.Sprintf("--rgw-realm=%s", tokenInfo.RealmName),
+		fmt.Sprintf("--url=%s", tokenInfo.Endpoint),
+		fmt.Sprintf("--access-key=%s", tokenInfo.AccessKey),
+		fmt.Sprintf("--secret=%s", tokenInfo.SecretKey),
+	)
+	if err != nil {
+		return err
+	}
+	r.Logger.Info("successfully removed zone from the realm", "Zone", zoneName(objectStore), "Realm", tokenInfo.RealmName)
+
+	return nil
+}
+
+// deleteRealmTokenSecret deletes the realm token generated by a main site
+func (r *ObjectStoreReconciler) deleteRealmTokenSecret(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	secret := realmTokenSecretMeta(objectStore)
+	err := r.Client.Delete(ctx, secret)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete realm token secret %q: %w", secret.Name, err)
+	}
+	r.Logger.Info("successfully deleted realm token secret", "Secret", secret.Name)
+
+	return nil
+}
+
+// cleanupPVC applies the PVC retention policy, the PVC is owned by the ObjectStore so it is
+// released from its owner to be retained
+func (r *ObjectStoreReconciler) cleanupPVC(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	pvc := &v1.PersistentVolumeClaim{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: instanceName(objectStore.Name, objectStore.Namespace)}, pvc)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get PVC: %w", err)
+	}
+
+	if objectStore.Spec.PVCRetentionPolicy == objectv1alpha1.PVCRetentionPolicyDelete {
+		err = r.Client.Delete(ctx, pvc, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete PVC %q: %w", pvc.Name, err)
+		}
+		r.Logger.Info("successfully deleted", "PVC", pvc.Name)
+		return nil
+	}
+
+	ownerReferences := []metav1.OwnerReference{}
+	for _, ownerReference := range pvc.OwnerReferences {
+		if ownerReference.UID != objectStore.UID {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	if len(ownerReferences) == len(pvc.OwnerReferences) {
+		return nil
+	}
+
+	pvc.OwnerReferences = ownerReferences
+	err = r.Client.Update(ctx, pvc)
+	if err != nil {
+		return fmt.Errorf("failed to release PVC %q from its owner: %w", pvc.Name, err)
+	}
+	r.Logger.Info("successfully retained", "PVC", pvc.Name)
+
+	return nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// configDirectory is where the rendered ceph.conf is mounted, /etc/ceph holds the dummy config
+	// file used by the CLI so it cannot be replaced
+	configDirectory  = "/etc/ceph/rgw"
+	configFileName   = "ceph.conf"
+	configVolumeName = "rgw-config"
+)
+
+// configHashAnnotation is set on the gateway pods, they are restarted when the configuration changes
+var configHashAnnotation = fmt.Sprintf("%s/config-hash", objectv1alpha1.GroupVersion.Group)
+
+// defaultDaemonConfig are the options of the gateway that can be overridden by the spec
+func defaultDaemonConfig() map[string]string {
+	return map[string]string{
+		// TODO: remove me one day? - currently it's helpful to see the DB's initialization progress
+		"debug_rgw": "15",
+		// TODO: remove me once caching is fixed
+		"rgw_cache_enabled": "false",
+	}
+}
+
+// daemonConfigFlags returns the flags of the options of the spec merged over the defaults, sorted so
+// that the pod template is stable
+func daemonConfigFlags(objectStore *objectv1alpha1.ObjectStore) []string {
+	config := defaultDaemonConfig()
+	for key, value := range objectStore.Spec.Config {
+		config[normalizeKey(strings.TrimSpace(key))] = value
+	}
+
+	flags := make([]string, 0, len(config))
+	for _, key := range sortedKeys(config) {
+		flags = append(flags, newFlag(key, config[key]))
+	}
+
+	return flags
+}
+
+// reconcileConfig renders the ConfigMap referenced by the spec into the ceph.conf of the gateway, it
+// returns the hash of the configuration which is set on the pods to restart them on changes
+func (r *ObjectStoreReconciler) reconcileConfig(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (string, error) {
+	configMap := configMapMeta(objectStore)
+	if objectStore.Spec.ConfigMapRef == nil {
+		err := r.Client.Delete(ctx, configMap)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("failed to delete config map %q: %w", configMap.Name, err)
+		}
+		return hash(strings.Join(daemonConfigFlags(objectStore), " ")), nil
+	}
+
+	source := &v1.ConfigMap{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: objectStore.Spec.ConfigMapRef.Name}, source)
+	if err != nil {
+		return "", fmt.Errorf("failed to get config map %q: %w", objectStore.Spec.ConfigMapRef.Name, err)
+	}
+	cephConf := renderCephConf(source.Data)
+
+	err = controllerutil.SetControllerReference(objectStore, configMap, r.Scheme)
+	if err != nil {
+		return "", fmt.Errorf("failed to set owner reference to config map %q: %w", configMap.Name, err)
+	}
+
+	mutateFunc := func() error {
+		configMap.Labels = getLabels(objectStore.Name)
+		configMap.Data = map[string]string{configFileName: cephConf}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, configMap, mutateFunc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create or update config map %q: %w", configMap.Name, err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("gateway configuration", "ConfigMap", configMap.Name, "opResult", opResult)
+	}
+
+	return hash(strings.Join(daemonConfigFlags(objectStore), " ") + cephConf), nil
+}
+
+// renderCephConf renders the options in the global section of a ceph.conf, the keys are normalized
+// and sorted so that the same options always give the same file
+func renderCephConf(options map[string]string) string {
+	config := map[string]string{}
+	for key, value := range options {
+		config[normalizeKey(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	var b strings.Builder
+	b.WriteString("[global]\n")
+	for _, key := range sortedKeys(config) {
+		fmt.Fprintf(&b, "%s = %s\n", key, config[key])
+	}
+
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func configMapMeta(objectStore *objectv1alpha1.ObjectStore) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-config", instanceName(objectStore.Name, objectStore.Namespace)),
+			Namespace: objectStore.Namespace,
+		},
+	}
+}
+
+func configVolume(objectStore *objectv1alpha1.ObjectStore) v1.Volume {
+	return v1.Volume{
+		Name: configVolumeName,
+		VolumeSource: v1.VolumeSource{
+			ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: configMapMeta(objectStore).Name},
+			},
+		},
+	}
+}
+
+func configVolumeMount() v1.VolumeMount {
+	return v1.VolumeMount{
+		Name:      configVolumeName,
+		MountPath: configDirectory,
+		ReadOnly:  true,
+	}
+}
+
+// configFilePath returns the path of the rendered ceph.conf in the gateway pod
+func configFilePath() string {
+	return path.Join(configDirectory, configFileName)
+}
@@ -75,11 +75,12 @@ func NewExecutor(clientSet kubernetes.Interface, restClient *rest.Config, logger
 // ExecWithOptions executes a command in the specified container,
 // returning stdout, stderr and error. `options` allowed for
 // additional parameters to be passed.
+// The secrets are masked in the logged command, in stderr and in the error, stdout is returned as
+// is since the callers parse it.
 func (e *RemotePodCommandExecutor) ExecWithOptions(options ExecOptions) (string, string, error) {
 	const tty = false
 
-	// Be careful this can leak the realm tokens
-	e.Logger.Info("ExecWithOptions", "Options", options)
+	e.Logger.Info("ExecWithOptions", "Pod", options.PodName, "Container", options.ContainerName, "Command", redactCommand(options.Command))
 
 	req := e.ClientSet.CoreV1().RESTClient().Post().
 		Resource("pods").
@@ -98,11 +99,14 @@ func (e *RemotePodCommandExecutor) ExecWithOptions(options ExecOptions) (string,
 
 	var stdout, stderr bytes.Buffer
 	err := execute(http.MethodPost, req.URL(), e.RestClient, options.Stdin, &stdout, &stderr, tty)
+	if err != nil {
+		err = &redactedError{err: err}
+	}
 
 	if options.PreserveWhitespace {
-		return stdout.String(), stderr.String(), err
+		return stdout.String(), redact(stderr.String()), err
 	}
-	return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), err
+	return strings.TrimSpace(stdout.String()), strings.TrimSpace(redact(stderr.String())), err
 }
 
 // ExecCommandInContainerWithFullOutput executes a command in the
@@ -0,0 +1,361 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// The Route and HTTPRoute are handled as unstructured so that their CRDs are only needed when used
+var (
+	routeGVK     = schema.GroupVersionKind{Group: "route.openshift.io", Version: "v1", Kind: "Route"}
+	httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "HTTPRoute"}
+)
+
+// reconcileExpose creates the object publishing the gateway under its host name, and deletes the
+// ones of the other types, e.g. after the type was changed
+func (r *ObjectStoreReconciler) reconcileExpose(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	var exposeType objectv1alpha1.ExposeType
+	if objectStore.Spec.Gateway.Expose != nil {
+		exposeType = objectStore.Spec.Gateway.Expose.Type
+	}
+
+	var err error
+	switch exposeType {
+	case objectv1alpha1.ExposeTypeIngress:
+		err = r.reconcileIngress(ctx, objectStore)
+	case objectv1alpha1.ExposeTypeRoute:
+		err = r.reconcileRoutes(ctx, objectStore)
+	case objectv1alpha1.ExposeTypeHTTPRoute:
+		err = r.reconcileHTTPRoute(ctx, objectStore)
+	}
+	if err != nil {
+		return err
+	}
+
+	name := instanceName(objectStore.Name, objectStore.Namespace)
+	if exposeType != objectv1alpha1.ExposeTypeIngress {
+		ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: objectStore.Namespace}}
+		err = r.deleteExposeObject(ctx, ingress)
+		if err != nil {
+			return err
+		}
+	}
+	if exposeType != objectv1alpha1.ExposeTypeRoute || !objectStore.Spec.Gateway.Expose.VirtualHostStyle {
+		err = r.deleteExposeObject(ctx, exposeObjectMeta(routeGVK, objectStore.Namespace, wildcardRouteName(objectStore)))
+		if err != nil {
+			return err
+		}
+	}
+	if exposeType != objectv1alpha1.ExposeTypeRoute {
+		err = r.deleteExposeObject(ctx, exposeObjectMeta(routeGVK, objectStore.Namespace, name))
+		if err != nil {
+			return err
+		}
+	}
+	if exposeType != objectv1alpha1.ExposeTypeHTTPRoute {
+		err = r.deleteExposeObject(ctx, exposeObjectMeta(httpRouteGVK, objectStore.Namespace, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ObjectStoreReconciler) reconcileIngress(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	expose := objectStore.Spec.Gateway.Expose
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instanceName(objectStore.Name, objectStore.Namespace),
+			Namespace: objectStore.Namespace,
+			Labels:    getLabels(objectStore.Name),
+		},
+	}
+
+	err := controllerutil.SetControllerReference(objectStore, ingress, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to ingress %q: %w", ingress.Name, err)
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	portName, _ := exposeBackendPort(objectStore)
+	backend := networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: instanceName(objectStore.Name, objectStore.Namespace),
+			Port: networkingv1.ServiceBackendPort{Name: portName},
+		},
+	}
+
+	mutateFunc := func() error {
+		ingress.Annotations = applyManagedAnnotations(ingress.Annotations, expose.Annotations)
+		ingress.Spec.IngressClassName = expose.IngressClassName
+		ingress.Spec.Rules = nil
+		for _, host := range exposeHosts(expose) {
+			ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1.IngressRule{
+				Host: host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{Path: "/", PathType: &pathType, Backend: backend}},
+					},
+				},
+			})
+		}
+		ingress.Spec.TLS = nil
+		if expose.TLSSecretName != "" {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: exposeHosts(expose), SecretName: expose.TLSSecretName}}
+		}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create or update ingress %q: %w", ingress.Name, err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("gateway ingress", "Ingress", ingress.Name, "opResult", opResult)
+	}
+
+	return nil
+}
+
+// reconcileRoutes creates the Route of the host name and, for virtual host style, a second one
+// for the sub-domains since a Route only has one host
+func (r *ObjectStoreReconciler) reconcileRoutes(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	expose := objectStore.Spec.Gateway.Expose
+
+	err := r.reconcileRoute(ctx, objectStore, instanceName(objectStore.Name, objectStore.Namespace), expose.Host, "None")
+	if err != nil {
+		return err
+	}
+	if expose.VirtualHostStyle {
+		// The router serves every sub-domain of the parent domain of a Subdomain route
+		err = r.reconcileRoute(ctx, objectStore, wildcardRouteName(objectStore), "wildcard."+expose.Host, "Subdomain")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ObjectStoreReconciler) reconcileRoute(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, name, host, wildcardPolicy string) error {
+	route := exposeObjectMeta(routeGVK, objectStore.Namespace, name)
+	route.SetLabels(getLabels(objectStore.Name))
+
+	err := controllerutil.SetControllerReference(objectStore, route, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to route %q: %w", name, err)
+	}
+
+	routeTLS, err := r.routeTLS(ctx, objectStore)
+	if err != nil {
+		return err
+	}
+	portName, _ := exposeBackendPort(objectStore)
+
+	mutateFunc := func() error {
+		route.SetAnnotations(applyManagedAnnotations(route.GetAnnotations(), objectStore.Spec.Gateway.Expose.Annotations))
+
+		spec := map[string]interface{}{
+			"host":           host,
+			"wildcardPolicy": wildcardPolicy,
+			"to": map[string]interface{}{
+				"kind": "Service",
+				"name": instanceName(objectStore.Name, objectStore.Namespace),
+			},
+			"port": map[string]interface{}{
+				"targetPort": portName,
+			},
+		}
+		if routeTLS != nil {
+			spec["tls"] = routeTLS
+		}
+		return unstructured.SetNestedField(route.Object, spec, "spec")
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, route, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create or update route %q: %w", name, err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("gateway route", "Route", name, "opResult", opResult)
+	}
+
+	return nil
+}
+
+// routeTLS returns the TLS configuration of a Route. A Route embeds its certificate, so it is copied
+// from the Secret and refreshed when the Secret is renewed. The router re-encrypts the traffic to a
+// gateway serving TLS, or passes it through when the Route has no certificate of its own.
+func (r *ObjectStoreReconciler) routeTLS(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (map[string]interface{}, error) {
+	secretName := objectStore.Spec.Gateway.Expose.TLSSecretName
+	if secretName == "" {
+		if !objectStore.Spec.IsTLSEnabled() {
+			return nil, nil
+		}
+		return map[string]interface{}{
+			"termination":                   "passthrough",
+			"insecureEdgeTerminationPolicy": "Redirect",
+		}, nil
+	}
+
+	secret := &v1.Secret{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: secretName}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get route TLS secret %q: %w", secretName, err)
+	}
+	routeTLS := map[string]interface{}{
+		"termination":                   "edge",
+		"insecureEdgeTerminationPolicy": "Redirect",
+		"certificate":                   string(secret.Data[v1.TLSCertKey]),
+		"key":                           string(secret.Data[v1.TLSPrivateKeyKey]),
+	}
+	if !objectStore.Spec.IsTLSEnabled() {
+		return routeTLS, nil
+	}
+
+	// The router verifies the gateway certificate against its CA, or the system ones without a CA.
+	// The gateway secret is written by cert-manager after the Route is created, its creation
+	// triggers a new reconcile.
+	routeTLS["termination"] = "reencrypt"
+	gatewaySecret := &v1.Secret{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: tlsSecretName(objectStore)}, gatewaySecret)
+	if err != nil && !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get gateway TLS secret %q: %w", tlsSecretName(objectStore), err)
+	}
+	if ca := gatewaySecret.Data["ca.crt"]; len(ca) > 0 {
+		routeTLS["destinationCACertificate"] = string(ca)
+	}
+
+	return routeTLS, nil
+}
+
+func (r *ObjectStoreReconciler) reconcileHTTPRoute(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	expose := objectStore.Spec.Gateway.Expose
+	if expose.ParentRef == nil {
+		return fmt.Errorf("spec.gateway.expose.parentRef is required for an HTTPRoute")
+	}
+
+	httpRoute := exposeObjectMeta(httpRouteGVK, objectStore.Namespace, instanceName(objectStore.Name, objectStore.Namespace))
+	httpRoute.SetLabels(getLabels(objectStore.Name))
+
+	err := controllerutil.SetControllerReference(objectStore, httpRoute, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to HTTPRoute %q: %w", httpRoute.GetName(), err)
+	}
+
+	parentRef := map[string]interface{}{"name": expose.ParentRef.Name}
+	if expose.ParentRef.Namespace != "" {
+		parentRef["namespace"] = expose.ParentRef.Namespace
+	}
+	if expose.ParentRef.SectionName != "" {
+		parentRef["sectionName"] = expose.ParentRef.SectionName
+	}
+	hostnames := []interface{}{}
+	for _, host := range exposeHosts(expose) {
+		hostnames = append(hostnames, host)
+	}
+
+	_, port := exposeBackendPort(objectStore)
+
+	mutateFunc := func() error {
+		httpRoute.SetAnnotations(applyManagedAnnotations(httpRoute.GetAnnotations(), expose.Annotations))
+
+		spec := map[string]interface{}{
+			"parentRefs": []interface{}{parentRef},
+			"hostnames":  hostnames,
+			"rules": []interface{}{
+				map[string]interface{}{
+					"backendRefs": []interface{}{
+						map[string]interface{}{
+							"name": instanceName(objectStore.Name, objectStore.Namespace),
+							"port": int64(port),
+						},
+					},
+				},
+			},
+		}
+		return unstructured.SetNestedField(httpRoute.Object, spec, "spec")
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, httpRoute, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create or update HTTPRoute %q: %w", httpRoute.GetName(), err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("gateway HTTPRoute", "HTTPRoute", httpRoute.GetName(), "opResult", opResult)
+	}
+
+	return nil
+}
+
+// deleteExposeObject deletes an object that is no longer needed, a missing CRD means there is
+// nothing to delete
+func (r *ObjectStoreReconciler) deleteExposeObject(ctx context.Context, object client.Object) error {
+	err := r.Client.Delete(ctx, object)
+	if err != nil {
+		if kerrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to delete %T %q: %w", object, object.GetName(), err)
+	}
+	r.Logger.Info("successfully deleted unused exposure", "name", object.GetName())
+
+	return nil
+}
+
+func exposeObjectMeta(gvk schema.GroupVersionKind, namespace, name string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	return object
+}
+
+func wildcardRouteName(objectStore *objectv1alpha1.ObjectStore) string {
+	return fmt.Sprintf("%s-wildcard", instanceName(objectStore.Name, objectStore.Namespace))
+}
+
+// exposeBackendPort returns the name and the number of the Service port the published gateway is
+// reached at, the secure port when the gateway serves TLS
+func exposeBackendPort(objectStore *objectv1alpha1.ObjectStore) (string, int32) {
+	if objectStore.Spec.IsTLSEnabled() {
+		return "https", gatewaySecurePort(objectStore)
+	}
+	return "http", gatewayPort(objectStore)
+}
+
+// exposeHosts returns the host names the gateway is published under
+func exposeHosts(expose *objectv1alpha1.GatewayExposeSpec) []string {
+	if expose.VirtualHostStyle {
+		return []string{expose.Host, "*." + expose.Host}
+	}
+	return []string{expose.Host}
+}
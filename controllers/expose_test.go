@@ -0,0 +1,189 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newExposedObjectStore(exposeType objectv1alpha1.ExposeType, tls bool) *objectv1alpha1.ObjectStore {
+	objectStore := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "rgw", UID: "uid"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Gateway: objectv1alpha1.GatewaySpec{
+				Port: 8080,
+				Expose: &objectv1alpha1.GatewayExposeSpec{
+					Type:        exposeType,
+					Host:        "s3.example.com",
+					Annotations: map[string]string{"a": "1", "b": "2"},
+					ParentRef:   &objectv1alpha1.ExposeParentRef{Name: "gateway"},
+				},
+			},
+		},
+	}
+	if tls {
+		objectStore.Spec.Gateway.SecurePort = 8443
+		objectStore.Spec.Gateway.TLS = &objectv1alpha1.GatewayTLSSpec{SecretName: "gateway-tls"}
+	}
+	return objectStore
+}
+
+func TestExposeBackendPort(t *testing.T) {
+	if name, port := exposeBackendPort(newExposedObjectStore(objectv1alpha1.ExposeTypeIngress, false)); name != "http" || port != 8080 {
+		t.Errorf("exposeBackendPort() without TLS = %q, %d, want http, 8080", name, port)
+	}
+	if name, port := exposeBackendPort(newExposedObjectStore(objectv1alpha1.ExposeTypeIngress, true)); name != "https" || port != 8443 {
+		t.Errorf("exposeBackendPort() with TLS = %q, %d, want https, 8443", name, port)
+	}
+}
+
+func TestReconcileIngress(t *testing.T) {
+	objectStore := newExposedObjectStore(objectv1alpha1.ExposeTypeIngress, true)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()
+	r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+	if err := r.reconcileIngress(context.Background(), objectStore); err != nil {
+		t.Fatalf("reconcileIngress() = %v", err)
+	}
+	delete(objectStore.Spec.Gateway.Expose.Annotations, "b")
+	if err := r.reconcileIngress(context.Background(), objectStore); err != nil {
+		t.Fatalf("reconcileIngress() = %v", err)
+	}
+
+	ingress := &networkingv1.Ingress{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "rgw", Name: instanceName("store", "rgw")}, ingress); err != nil {
+		t.Fatalf("failed to get ingress: %v", err)
+	}
+	if port := ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Name; port != "https" {
+		t.Errorf("backend port %q, want https", port)
+	}
+	if want := map[string]string{"a": "1", managedAnnotationsAnnotation: "a"}; !reflect.DeepEqual(ingress.Annotations, want) {
+		t.Errorf("annotations %v, want %v", ingress.Annotations, want)
+	}
+}
+
+func TestRouteTLS(t *testing.T) {
+	hostSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "host-tls", Namespace: "rgw"},
+		Data:       map[string][]byte{v1.TLSCertKey: []byte("host-cert"), v1.TLSPrivateKeyKey: []byte("host-key")},
+	}
+	gatewaySecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "gateway-tls", Namespace: "rgw"},
+		Data:       map[string][]byte{"ca.crt": []byte("gateway-ca")},
+	}
+
+	tests := []struct {
+		name          string
+		tls           bool
+		hostSecret    string
+		gatewaySecret bool
+		want          map[string]interface{}
+	}{
+		{
+			name: "plain",
+		},
+		{
+			name:       "edge",
+			hostSecret: "host-tls",
+			want: map[string]interface{}{
+				"termination": "edge", "insecureEdgeTerminationPolicy": "Redirect", "certificate": "host-cert", "key": "host-key",
+			},
+		},
+		{
+			name: "passthrough",
+			tls:  true,
+			want: map[string]interface{}{"termination": "passthrough", "insecureEdgeTerminationPolicy": "Redirect"},
+		},
+		{
+			name:          "reencrypt",
+			tls:           true,
+			hostSecret:    "host-tls",
+			gatewaySecret: true,
+			want: map[string]interface{}{
+				"termination": "reencrypt", "insecureEdgeTerminationPolicy": "Redirect", "certificate": "host-cert", "key": "host-key",
+				"destinationCACertificate": "gateway-ca",
+			},
+		},
+		{
+			name:       "reencrypt before the gateway certificate is issued",
+			tls:        true,
+			hostSecret: "host-tls",
+			want: map[string]interface{}{
+				"termination": "reencrypt", "insecureEdgeTerminationPolicy": "Redirect", "certificate": "host-cert", "key": "host-key",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objects := []client.Object{hostSecret.DeepCopy()}
+			if test.gatewaySecret {
+				objects = append(objects, gatewaySecret.DeepCopy())
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+			r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+			objectStore := newExposedObjectStore(objectv1alpha1.ExposeTypeRoute, test.tls)
+			objectStore.Spec.Gateway.Expose.TLSSecretName = test.hostSecret
+
+			got, err := r.routeTLS(context.Background(), objectStore)
+			if err != nil {
+				t.Fatalf("routeTLS() = %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("routeTLS() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReconcileHTTPRoute(t *testing.T) {
+	objectStore := newExposedObjectStore(objectv1alpha1.ExposeTypeHTTPRoute, true)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()
+	r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+	if err := r.reconcileHTTPRoute(context.Background(), objectStore); err != nil {
+		t.Fatalf("reconcileHTTPRoute() = %v", err)
+	}
+	objectStore.Spec.Gateway.Expose.Annotations = nil
+	if err := r.reconcileHTTPRoute(context.Background(), objectStore); err != nil {
+		t.Fatalf("reconcileHTTPRoute() = %v", err)
+	}
+
+	httpRoute := exposeObjectMeta(httpRouteGVK, "rgw", instanceName("store", "rgw"))
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(httpRoute), httpRoute); err != nil {
+		t.Fatalf("failed to get HTTPRoute: %v", err)
+	}
+	rules, _, _ := unstructured.NestedSlice(httpRoute.Object, "spec", "rules")
+	backendRefs := rules[0].(map[string]interface{})["backendRefs"].([]interface{})
+	if port := backendRefs[0].(map[string]interface{})["port"]; port != int64(8443) {
+		t.Errorf("backend port %v, want 8443", port)
+	}
+	if annotations := httpRoute.GetAnnotations(); len(annotations) > 0 {
+		t.Errorf("annotations %v were not removed", annotations)
+	}
+}
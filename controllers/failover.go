@@ -0,0 +1,151 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// getRealmToken returns the decoded realm token of a secondary site
+func (r *ObjectStoreReconciler) getRealmToken(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*realmTokenInfo, error) {
+	secret, err := r.getRealmTokenSecret(ctx, objectStore)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeRealmToken(string(secret.Data["token"]))
+}
+
+// promoteZone makes the zone of a secondary site the master of the realm when its main site is
+// lost, then writes a realm token pointing to this site in its own realm token secret. The secret
+// the site joined with is left untouched, it may belong to the lost main site. The zone is promoted
+// once the endpoint of the master is its own. It returns false when the gateway is restarted to
+// apply the new period, the caller must wait for it to run again.
+func (r *ObjectStoreReconciler) promoteZone(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, pod v1.Pod, endpoints []string) (bool, error) {
+	secret := realmTokenSecretMeta(objectStore)
+	if objectStore.Status.MasterEndpoint == endpoints[0] {
+		err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+		if err == nil {
+			return true, nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get realm token secret %q: %w", secret.Name, err)
+		}
+	}
+
+	tokenInfo, err := r.getRealmToken(ctx, objectStore)
+	if err != nil {
+		return false, err
+	}
+
+	if objectStore.Status.MasterEndpoint != endpoints[0] {
+		// Both commands are idempotent, they are run again if the promotion is interrupted
+		_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, append([]string{"zone", "modify", "--master", "--default"}, zoneArgs(objectStore, tokenInfo.RealmName)...)...)
+		if err != nil {
+			return false, err
+		}
+		_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "period", "update", "--commit", fmt.Sprintf("--rgw-realm=%s", tokenInfo.RealmName))
+		if err != nil {
+			return false, err
+		}
+		r.Logger.Info("zone promoted to master", "Zone", zoneName(objectStore), "Realm", tokenInfo.RealmName)
+	}
+
+	// The system user is shared by all the zones of the realm, only the endpoint changes
+	tokenInfo.Endpoint = endpoints[0]
+	token, err := encodeRealmToken(tokenInfo)
+	if err != nil {
+		return false, err
+	}
+
+	// With an ObjectZone, the secret is the one of the realm and may still be owned by the lost main
+	// site, it now follows the lifecycle of the new master
+	mutateFunc := func() error {
+		secret.OwnerReferences = nil
+		err := controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
+		if err != nil {
+			return fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+		}
+		secret.Labels = getLabels(objectStore.Name)
+		secret.Type = v1.SecretTypeOpaque
+		secret.Data = map[string][]byte{"token": []byte(token)}
+		return nil
+	}
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, mutateFunc)
+	if err != nil {
+		return false, fmt.Errorf("failed to write realm token secret %q: %w", secret.Name, err)
+	}
+	r.Logger.Info("realm token of the promoted site", "Secret", secret.Name, "Endpoint", tokenInfo.Endpoint, "opResult", opResult)
+
+	if objectStore.Status.MasterEndpoint == endpoints[0] {
+		return true, nil
+	}
+	objectStore.Status.MasterEndpoint = tokenInfo.Endpoint
+
+	// The pod watch triggers a new reconcile once the new pod runs
+	r.Logger.Info("deleting pod to restart the gateway and apply the new period", "Pod", pod.Name)
+	err = r.Client.Delete(ctx, pod.DeepCopy())
+	if err != nil {
+		return false, fmt.Errorf("failed to delete pod %q: %w", pod.Name, err)
+	}
+
+	return false, nil
+}
+
+// followMaster pulls the period from the master zone of the realm token when the token points to a
+// new master, e.g. after another secondary site was promoted. It returns false when the gateway is
+// restarted to apply the new period, the caller must wait for it to run again.
+func (r *ObjectStoreReconciler) followMaster(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, pod v1.Pod) (bool, error) {
+	tokenInfo, err := r.getRealmToken(ctx, objectStore)
+	if err != nil {
+		return false, err
+	}
+
+	// The zone was created from the token, it already follows its master
+	if objectStore.Status.MasterEndpoint == "" || objectStore.Status.MasterEndpoint == tokenInfo.Endpoint {
+		objectStore.Status.MasterEndpoint = tokenInfo.Endpoint
+		return true, nil
+	}
+
+	_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "period", "pull",
+		fmt.Sprintf("--url=%s", tokenInfo.Endpoint),
+		fmt.Sprintf("--access-key=%s", tokenInfo.AccessKey),
+		fmt.Sprintf("--secret=%s", tokenInfo.SecretKey),
+	)
+	if err != nil {
+		return false, err
+	}
+	r.Logger.Info("pulled period from the new master", "Zone", zoneName(objectStore), "Endpoint", tokenInfo.Endpoint)
+
+	objectStore.Status.MasterEndpoint = tokenInfo.Endpoint
+
+	// The pod watch triggers a new reconcile once the new pod runs
+	r.Logger.Info("deleting pod to restart the gateway and apply the new period", "Pod", pod.Name)
+	err = r.Client.Delete(ctx, pod.DeepCopy())
+	if err != nil {
+		return false, fmt.Errorf("failed to delete pod %q: %w", pod.Name, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// syncMetadata and syncData are the values of the "sync" label of the sync gauges
+	syncMetadata = "metadata"
+	syncData     = "data"
+)
+
+var (
+	zoneLabels = []string{"namespace", "objectstore", "zone"}
+	syncLabels = append([]string{"sync", "source_zone"}, zoneLabels...)
+
+	syncHealthyGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rgw_standalone_multisite_sync_healthy",
+		Help: "Whether the zone replicates with the other zones of the realm (1) or not (0)",
+	}, zoneLabels)
+
+	syncErrorsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rgw_standalone_multisite_sync_errors",
+		Help: "Number of entries in the sync error log of the zone",
+	}, zoneLabels)
+
+	syncShardsBehindGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rgw_standalone_multisite_sync_shards_behind",
+		Help: "Number of log shards with changes not yet applied, for the metadata or the data of a source zone",
+	}, syncLabels)
+
+	syncOldestChangeAgeGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rgw_standalone_multisite_sync_oldest_change_age_seconds",
+		Help: "Age of the oldest change not yet applied, 0 when caught up",
+	}, syncLabels)
+)
+
+func init() {
+	// The registry of controller-runtime is served on the metrics endpoint of the manager
+	metrics.Registry.MustRegister(syncHealthyGauge, syncErrorsGauge, syncShardsBehindGauge, syncOldestChangeAgeGauge)
+}
+
+// recordSyncMetrics exports the sync status of the zone, the series of the source zones that are
+// no longer reported are deleted
+func recordSyncMetrics(objectStore *objectv1alpha1.ObjectStore, status *objectv1alpha1.MultisiteStatus, healthy bool, now time.Time) {
+	zone := zoneName(objectStore)
+	deleteSyncMetrics(objectStore)
+
+	healthyValue := 0.0
+	if healthy {
+		healthyValue = 1
+	}
+	syncHealthyGauge.WithLabelValues(objectStore.Namespace, objectStore.Name, zone).Set(healthyValue)
+	if status == nil {
+		return
+	}
+	syncErrorsGauge.WithLabelValues(objectStore.Namespace, objectStore.Name, zone).Set(float64(status.ErrorCount))
+
+	record := func(sync, sourceZone string, state objectv1alpha1.SyncStatus) {
+		labels := []string{sync, sourceZone, objectStore.Namespace, objectStore.Name, zone}
+		syncShardsBehindGauge.WithLabelValues(labels...).Set(float64(state.ShardsBehind))
+		age := 0.0
+		if state.OldestChange != nil {
+			age = now.Sub(state.OldestChange.Time).Seconds()
+		}
+		syncOldestChangeAgeGauge.WithLabelValues(labels...).Set(age)
+	}
+	record(syncMetadata, "", status.Metadata)
+	for _, source := range status.DataSources {
+		record(syncData, source.SourceZone, source.SyncStatus)
+	}
+}
+
+// deleteSyncMetrics removes the series of the zone, the source zones are read from the last
+// reported status since the series can only be deleted with all their labels
+func deleteSyncMetrics(objectStore *objectv1alpha1.ObjectStore) {
+	zone := zoneName(objectStore)
+	syncHealthyGauge.DeleteLabelValues(objectStore.Namespace, objectStore.Name, zone)
+	syncErrorsGauge.DeleteLabelValues(objectStore.Namespace, objectStore.Name, zone)
+
+	status := objectStore.Status.Multisite
+	if status == nil {
+		return
+	}
+	syncShardsBehindGauge.DeleteLabelValues(syncMetadata, "", objectStore.Namespace, objectStore.Name, zone)
+	syncOldestChangeAgeGauge.DeleteLabelValues(syncMetadata, "", objectStore.Namespace, objectStore.Name, zone)
+	for _, source := range status.DataSources {
+		syncShardsBehindGauge.DeleteLabelValues(syncData, source.SourceZone, objectStore.Namespace, objectStore.Name, zone)
+		syncOldestChangeAgeGauge.DeleteLabelValues(syncData, source.SourceZone, objectStore.Namespace, objectStore.Name, zone)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The multisite zone job and the realm token secret used to have the same name for every
+// ObjectStore of a namespace. The resources an ObjectStore created under these names are adopted,
+// so that existing installations are not configured again.
+const (
+	legacyMultisiteJobName     = "object-store-multisite-zone-job"
+	legacyRealmTokenSecretName = "object-store-realm-token"
+)
+
+// adoptLegacyRealmTokenSecret keeps the realm token secret a main site created under the legacy
+// name, the secondary sites of the realm may still reference it. The name is recorded in the
+// status and read by realmTokenSecretMeta.
+func (r *ObjectStoreReconciler) adoptLegacyRealmTokenSecret(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	if !objectStore.Spec.IsMainSite() || objectStore.Spec.HasZoneRef() || objectStore.Status.RealmTokenSecretName != "" {
+		return nil
+	}
+
+	secret := &v1.Secret{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: legacyRealmTokenSecretName}, secret)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get legacy realm token secret %q: %w", legacyRealmTokenSecretName, err)
+	}
+	// Another ObjectStore of the namespace created it
+	if !metav1.IsControlledBy(secret, objectStore) {
+		return nil
+	}
+
+	objectStore.Status.RealmTokenSecretName = legacyRealmTokenSecretName
+	r.Logger.Info("adopted legacy realm token secret", "Secret", secret.Name)
+
+	return nil
+}
+
+// getMultisiteJob returns the multisite zone job of the ObjectStore, or the one it created under
+// the legacy name. A completed legacy job is kept so that the zone is not created again.
+func (r *ObjectStoreReconciler) getMultisiteJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*batchv1.Job, error) {
+	job := multisiteJobMeta(objectStore)
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(job), job)
+	if err == nil || !kerrors.IsNotFound(err) {
+		return job, err
+	}
+
+	legacyJob := &batchv1.Job{}
+	legacyErr := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: legacyMultisiteJobName}, legacyJob)
+	if legacyErr != nil {
+		if kerrors.IsNotFound(legacyErr) {
+			return job, err
+		}
+		return nil, legacyErr
+	}
+	// Another ObjectStore of the namespace created it
+	if !metav1.IsControlledBy(legacyJob, objectStore) {
+		return job, err
+	}
+
+	return legacyJob, nil
+}
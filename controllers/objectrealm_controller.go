@@ -0,0 +1,174 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reasons used in the conditions of the multisite topology resources
+const (
+	reasonTopologyReady     = "TopologyReady"
+	reasonTopologyInvalid   = "TopologyInvalid"
+	reasonRealmTokenMissing = "RealmTokenMissing"
+)
+
+// ObjectRealmReconciler reconciles a ObjectRealm object
+type ObjectRealmReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectrealms,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectrealms/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzonegroups,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectRealmReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectRealm{}).
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectZoneGroup{}}, handler.EnqueueRequestsFromMapFunc(realmForZoneGroup)).
+		// The realm token is created by the master zone, or copied from its cluster
+		Watches(&source.Kind{Type: &v1.Secret{}}, handler.EnqueueRequestsFromMapFunc(realmForSecret)).
+		Complete(r)
+}
+
+// realmForZoneGroup maps an ObjectZoneGroup to its ObjectRealm
+func realmForZoneGroup(object client.Object) []reconcile.Request {
+	zoneGroup := object.(*objectv1alpha1.ObjectZoneGroup)
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: zoneGroup.Namespace, Name: zoneGroup.Spec.RealmRef}},
+	}
+}
+
+// realmForSecret maps a realm token secret to the ObjectRealm it is named after
+func realmForSecret(object client.Object) []reconcile.Request {
+	realm := strings.TrimSuffix(object.GetName(), realmTokenSecretName(""))
+	if realm == object.GetName() || realm == "" {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: realm}},
+	}
+}
+
+// Reconcile reports the zonegroups of the realm and whether its token is available to the zones
+func (r *ObjectRealmReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectRealm", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	realm := &objectv1alpha1.ObjectRealm{}
+	err := r.Client.Get(ctx, req.NamespacedName, realm)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectRealm resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectRealm: %w", err)
+	}
+
+	original := realm.DeepCopy()
+	err = r.reconcileRealm(ctx, realm)
+	if err != nil {
+		setStatusCondition(&realm.Status.Conditions, realm.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	realm.Status.Phase = phaseFromConditions(realm.Status.Conditions, err)
+	realm.Status.ObservedGeneration = realm.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, realm, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectRealm %q: %w", realm.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectRealm", req.NamespacedName.String(), "Phase", realm.Status.Phase)
+	return reconcile.Result{}, nil
+}
+
+func (r *ObjectRealmReconciler) reconcileRealm(ctx context.Context, realm *objectv1alpha1.ObjectRealm) error {
+	zoneGroups := &objectv1alpha1.ObjectZoneGroupList{}
+	err := r.Client.List(ctx, zoneGroups, client.InNamespace(realm.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to list ObjectZoneGroups: %w", err)
+	}
+
+	names := []string{}
+	masters := []string{}
+	for _, zoneGroup := range zoneGroups.Items {
+		if zoneGroup.Spec.RealmRef != realm.Name {
+			continue
+		}
+		names = append(names, zoneGroup.Name)
+		if zoneGroup.Spec.Master {
+			masters = append(masters, zoneGroup.Name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(masters)
+	realm.Status.ZoneGroups = names
+	realm.Status.MasterZoneGroup = strings.Join(masters, ",")
+	realm.Status.RealmTokenSecretName = realmTokenSecretName(realm.Name)
+
+	if len(masters) > 1 {
+		setStatusCondition(&realm.Status.Conditions, realm.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonTopologyInvalid,
+			fmt.Sprintf("a realm has a single master zonegroup, found %s", strings.Join(masters, ", ")))
+		return nil
+	}
+
+	secret := &v1.Secret{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: realm.Namespace, Name: realm.Status.RealmTokenSecretName}, secret)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get realm token secret %q: %w", realm.Status.RealmTokenSecretName, err)
+		}
+		realm.Status.MasterEndpoint = ""
+		setStatusCondition(&realm.Status.Conditions, realm.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonRealmTokenMissing,
+			fmt.Sprintf("waiting for the master zone to bootstrap the realm, or for secret %q to be copied from its cluster", realm.Status.RealmTokenSecretName))
+		return nil
+	}
+
+	tokenInfo, err := decodeRealmToken(string(secret.Data["token"]))
+	if err != nil {
+		return err
+	}
+	realm.Status.MasterEndpoint = tokenInfo.Endpoint
+	setStatusCondition(&realm.Status.Conditions, realm.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonTopologyReady,
+		fmt.Sprintf("realm token is available in secret %q", realm.Status.RealmTokenSecretName))
+
+	return nil
+}
@@ -18,19 +18,30 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 	"syscall"
 	"time"
 
+	apps "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
@@ -43,24 +54,201 @@ type ObjectStoreReconciler struct {
 	*runtime.Scheme
 	logr.Logger
 	*RemotePodCommandExecutor
+
+	// RealmTokenNamespaces are the pairs of namespaces a realm token may go between, besides the
+	// namespace of each ObjectStore
+	RealmTokenNamespaces []RealmTokenNamespacePair
 }
 
 //+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores/finalizers,verbs=update
-//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;delete;get;list
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstorebackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzones;objectzonegroups;objectrealms,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=create;delete;get;list;watch;update
+//+kubebuilder:rbac:groups="storage.k8s.io",resources=storageclasses,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=services,verbs=create;delete;get;update;list;watch
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;delete;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=create;delete;get;update;list;watch
 //+kubebuilder:rbac:groups="",resources=pods,verbs=list;watch;delete
 //+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
 //+kubebuilder:rbac:groups="apps",resources=deployments,verbs=create;delete;get;update;list;watch
 //+kubebuilder:rbac:groups="batch",resources=jobs,verbs=create;delete;get;list;watch
+//+kubebuilder:rbac:groups="cert-manager.io",resources=certificates,verbs=create;delete;get;update;list;watch
+//+kubebuilder:rbac:groups="networking.k8s.io",resources=ingresses,verbs=create;delete;get;update;list;watch
+//+kubebuilder:rbac:groups="route.openshift.io",resources=routes;routes/custom-host,verbs=create;delete;get;update;list;watch
+//+kubebuilder:rbac:groups="gateway.networking.k8s.io",resources=httproutes,verbs=create;delete;get;update;list;watch
+
+// requeueInterval is how long to wait before checking again on a resource that is progressing.
+// The watches usually trigger a reconcile earlier.
+const requeueInterval = 15 * time.Second
+
+// secretRefsIndex indexes the ObjectStores by the secrets they reference and do not own, such
+// as the realm token of a secondary site or a TLS certificate
+const secretRefsIndex = ".spec.secretRefs"
+
+// configMapRefIndex indexes the ObjectStores by the ConfigMap their configuration is rendered from
+const configMapRefIndex = ".spec.configMapRef"
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ObjectStoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &objectv1alpha1.ObjectStore{}, secretRefsIndex, func(object client.Object) []string {
+		objectStore := object.(*objectv1alpha1.ObjectStore)
+		secrets := []string{}
+		if objectStore.Spec.IsMultisite() {
+			// A secret of another namespace is indexed with its namespace
+			key := realmTokenSourceKey(objectStore)
+			if key.Namespace == objectStore.Namespace {
+				secrets = append(secrets, key.Name)
+			} else {
+				secrets = append(secrets, key.String())
+			}
+		}
+		// The realm of an ObjectZone is only known once resolved in the status
+		if objectStore.Spec.HasZoneRef() && objectStore.Status.Realm != "" {
+			secrets = append(secrets, realmTokenSecretName(objectStore.Status.Realm))
+		}
+		if objectStore.Spec.IsTLSEnabled() {
+			secrets = append(secrets, tlsSecretName(objectStore))
+		}
+		// A Route embeds a copy of the certificate of its host
+		if expose := objectStore.Spec.Gateway.Expose; expose != nil && expose.TLSSecretName != "" {
+			secrets = append(secrets, expose.TLSSecretName)
+		}
+		return secrets
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index ObjectStores by referenced secrets: %w", err)
+	}
+
+	err = mgr.GetFieldIndexer().IndexField(context.Background(), &objectv1alpha1.ObjectStore{}, configMapRefIndex, func(object client.Object) []string {
+		objectStore := object.(*objectv1alpha1.ObjectStore)
+		if objectStore.Spec.ConfigMapRef == nil {
+			return nil
+		}
+		return []string{objectStore.Spec.ConfigMapRef.Name}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index ObjectStores by referenced config map: %w", err)
+	}
+
+	// Every resource created by the operator is watched so that drift is corrected right away
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&objectv1alpha1.ObjectStore{}).
-		Complete(r)
+		Owns(&apps.Deployment{}).
+		Owns(&v1.Service{}).
+		Owns(&batchv1.Job{}).
+		Owns(&v1.Secret{}).
+		Owns(&v1.PersistentVolumeClaim{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&v1.ConfigMap{}).
+		// Pods are owned by the deployment's replica set, so they are mapped through their label
+		Watches(&source.Kind{Type: &v1.Pod{}}, handler.EnqueueRequestsFromMapFunc(objectStoreForPod)).
+		// The realm token secret of a secondary site is not owned by it, it is copied from the main
+		// site, and neither is the TLS secret
+		Watches(&source.Kind{Type: &v1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.objectStoresForSecret)).
+		// The configuration is rendered from a ConfigMap provided by the user
+		Watches(&source.Kind{Type: &v1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.objectStoresForConfigMap)).
+		// The names and the role of a site come from the ObjectZone it serves
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectZone{}}, handler.EnqueueRequestsFromMapFunc(r.objectStoresForZone))
+
+	// The Routes and HTTPRoutes can only be watched when their CRD is installed
+	for _, gvk := range []schema.GroupVersionKind{routeGVK, httpRouteGVK} {
+		_, err = mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if !meta.IsNoMatchError(err) {
+				return fmt.Errorf("failed to get the mapping of %s: %w", gvk.Kind, err)
+			}
+			r.Logger.Info("not watching the exposure objects, their CRD is not installed", "kind", gvk.Kind)
+			continue
+		}
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(gvk)
+		builder = builder.Owns(object)
+	}
+
+	return builder.Complete(r)
+}
+
+// objectStoresForZone maps an ObjectZone to the ObjectStores serving it
+func (r *ObjectStoreReconciler) objectStoresForZone(object client.Object) []reconcile.Request {
+	objectStores := &objectv1alpha1.ObjectStoreList{}
+	err := r.Client.List(context.Background(), objectStores, client.InNamespace(object.GetNamespace()))
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectStores", "ObjectZone", object.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, objectStore := range objectStores.Items {
+		if objectStore.Spec.HasZoneRef() && objectStore.Spec.Multisite.ZoneRef == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&objectStore)})
+		}
+	}
+	return requests
+}
+
+// objectStoresForSecret maps a secret to the ObjectStores referencing it
+func (r *ObjectStoreReconciler) objectStoresForSecret(object client.Object) []reconcile.Request {
+	objectStores := &objectv1alpha1.ObjectStoreList{}
+	err := r.Client.List(context.Background(), objectStores,
+		client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{secretRefsIndex: object.GetName()},
+	)
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectStores referencing secret", "Secret", object.GetName())
+		return nil
+	}
+
+	// The realm token of a secondary site may come from another namespace
+	crossNamespace := &objectv1alpha1.ObjectStoreList{}
+	err = r.Client.List(context.Background(), crossNamespace,
+		client.MatchingFields{secretRefsIndex: client.ObjectKeyFromObject(object).String()},
+	)
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectStores referencing secret", "Secret", client.ObjectKeyFromObject(object).String())
+		return nil
+	}
+	objectStores.Items = append(objectStores.Items, crossNamespace.Items...)
+
+	requests := make([]reconcile.Request, 0, len(objectStores.Items))
+	for _, objectStore := range objectStores.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&objectStore)})
+	}
+
+	return requests
+}
+
+// objectStoresForConfigMap maps a config map to the ObjectStores rendering their configuration from it
+func (r *ObjectStoreReconciler) objectStoresForConfigMap(object client.Object) []reconcile.Request {
+	objectStores := &objectv1alpha1.ObjectStoreList{}
+	err := r.Client.List(context.Background(), objectStores,
+		client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{configMapRefIndex: object.GetName()},
+	)
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectStores referencing config map", "ConfigMap", object.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(objectStores.Items))
+	for _, objectStore := range objectStores.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&objectStore)})
+	}
+
+	return requests
+}
+
+// objectStoreForPod maps a gateway pod to the ObjectStore it belongs to
+func objectStoreForPod(object client.Object) []reconcile.Request {
+	name, ok := object.GetLabels()[objectStoreLabel]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: name}},
+	}
 }
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -82,17 +270,41 @@ func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, fmt.Errorf("failed to get ObjectStore: %w", err)
 	}
 
-	// Build finalizer name
-	finalizerName := buildFinalizerName(objectStore.GetObjectKind().GroupVersionKind().Kind)
+	// Build finalizer name, the kind is not always set on objects read from the cache
+	finalizerName := buildFinalizerName("ObjectStore")
 
 	// DELETE: the CR was deleted
 	if !objectStore.GetDeletionTimestamp().IsZero() {
-		// updateStatus(r.client, request.NamespacedName, cephv1.ConditionDeleting, buildStatusInfo(cephObjectStore))
+		if !controllerutil.ContainsFinalizer(objectStore, finalizerName) {
+			return reconcile.Result{}, nil
+		}
 
-		// DO WHATEVER CLEANUP
+		original := objectStore.DeepCopy()
+		setCondition(objectStore, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonDeleting, "object store is being deleted")
+		objectStore.Status.Phase = objectv1alpha1.PhaseDeleting
+		err = r.updateStatus(ctx, objectStore, original)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
 
-		// Remove finalizer
+		err = r.cleanupObjectStore(ctx, objectStore)
+		if err != nil {
+			// The Ready condition tells what blocks the deletion
+			original = objectStore.DeepCopy()
+			setCondition(objectStore, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonDeleting, fmt.Sprintf("object store is being deleted: %v", err))
+			statusErr := r.updateStatus(ctx, objectStore, original)
+			if statusErr != nil {
+				r.Logger.Error(statusErr, "failed to report the cleanup failure")
+			}
+			return reconcile.Result{}, fmt.Errorf("failed to clean up ObjectStore: %w", err)
+		}
+
+		// Remove finalizer, the garbage collector deletes the remaining owned resources
 		controllerutil.RemoveFinalizer(objectStore, finalizerName)
+		err = r.Client.Update(ctx, objectStore)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+		}
 
 		// Return and do not requeue. Successful deletion.
 		r.Logger.Info("successfully deleted ObjectStore" + req.NamespacedName.String())
@@ -102,137 +314,590 @@ func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	// Main reconcile logic starts here
 	if !controllerutil.ContainsFinalizer(objectStore, finalizerName) {
 		controllerutil.AddFinalizer(objectStore, finalizerName)
+		err = r.Client.Update(ctx, objectStore)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	original := objectStore.DeepCopy()
+	result, err := r.reconcileObjectStore(ctx, objectStore)
+	computeReadiness(objectStore, err)
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.updateStatus(ctx, objectStore, original)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, statusErr
+	}
+
+	// A Ready multisite ObjectStore is also requeued to collect its sync status
+	if result.RequeueAfter > 0 && objectStore.Status.Phase != objectv1alpha1.PhaseReady {
+		r.Logger.Info("waiting for the object store to progress", "Phase", objectStore.Status.Phase, "RequeueAfter", result.RequeueAfter)
+		return result, nil
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectStore", req.NamespacedName.String())
+	return result, nil
+}
+
+// reconcileObjectStore converges the ObjectStore resources and records each step in the status
+// conditions. It never blocks waiting on a resource, instead it asks to be requeued and relies on
+// the watches of the owned resources to be triggered again as soon as they progress.
+func (r *ObjectStoreReconciler) reconcileObjectStore(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (ctrl.Result, error) {
+	// The names and the role of a site serving an ObjectZone come from the topology resources
+	topology, err := r.resolveZone(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to resolve zone: %w", err)
 	}
 
 	// Create PVC from provided SC
-	err = r.createPVC(ctx, objectStore)
+	err = r.reconcilePVC(ctx, objectStore)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("failed to create PVC: %w", err)
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile PVC: %w", err)
+	}
+
+	// Restore the data from a backup before the gateway starts for the first time
+	restored, err := r.reconcileRestore(ctx, objectStore)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	if !restored {
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
 	}
 
 	// Reconcile objectStore service
-	serviceIP, err := r.reconcileService(ctx, objectStore)
+	service, err := r.reconcileService(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionServiceReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile Service: %w", err)
+	}
+
+	// Publish the service under its host name
+	err = r.reconcileExpose(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionServiceReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to expose Service: %w", err)
+	}
+
+	serviceIP := service.Spec.ClusterIP
+	endpoint := gatewayEndpoint(objectStore, serviceIP)
+	objectStore.Status.Endpoint = endpoint
+
+	// The endpoints advertised in the realm cannot be changed easily, so wait for the load balancer
+	endpoints, ok := advertisedEndpoints(objectStore, service)
+	if !ok {
+		setCondition(objectStore, objectv1alpha1.ConditionServiceReady, metav1.ConditionFalse, reasonProgressing, fmt.Sprintf("waiting for load balancer of service %q", service.Name))
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+	objectStore.Status.ExternalEndpoints = endpoints
+	setCondition(objectStore, objectv1alpha1.ConditionServiceReady, metav1.ConditionTrue, reasonServiceCreated, fmt.Sprintf("service is reachable at %s", strings.Join(endpoints, ", ")))
+
+	// The gateway cannot start before its certificate is available
+	tlsReady, err := r.reconcileTLS(ctx, objectStore, serviceIP, endpoints)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("failed to reconcile Service: %w", err)
+		setCondition(objectStore, objectv1alpha1.ConditionTLSReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile TLS: %w", err)
+	}
+	if !tlsReady {
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+	if !objectStore.Spec.IsTLSEnabled() {
+		meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionTLSReady)
 	}
 
 	// Configure multisite will import the realm token from the main site
 	if objectStore.Spec.IsMultisite() {
-		err = r.configureMultisite(ctx, objectStore, serviceIP)
+		configured, err := r.configureMultisite(ctx, objectStore, endpoints)
 		if err != nil {
-			return reconcile.Result{}, fmt.Errorf("failed to configure multisite: %w", err)
+			setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to configure multisite: %w", err)
 		}
+		if !configured {
+			// A failed zone job is retried when the spec or the realm token secret changes, both are watched
+			condition := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionMultisiteConfigured)
+			if condition != nil && condition.Reason == reasonZoneJobFailed {
+				return ctrl.Result{}, nil
+			}
+			return ctrl.Result{RequeueAfter: requeueInterval}, nil
+		}
+		setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionTrue, reasonZoneCreated, fmt.Sprintf("zone %q created", zoneName(objectStore)))
+	} else {
+		meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionMultisiteConfigured)
 	}
 
-	// Reconcile objectStore deployment
-	port := int32(8080)
-	if objectStore.Spec.Gateway.Port != 0 {
-		port = objectStore.Spec.Gateway.Port
+	// Render the configuration of the gateway
+	configHash, err := r.reconcileConfig(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile configuration: %w", err)
 	}
-	reconcileResult, err := r.createOrUpdateDeployment(ctx, objectStore, fmt.Sprintf("http://%s:%d", serviceIP, port))
+
+	// Upgrade the gateway when the image changes, it is stopped while its data is copied
+	stopped, err := r.reconcileUpgrade(ctx, objectStore)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to upgrade: %w", err)
+	}
+
+	// Reconcile objectStore deployment
+	reconcileResult, err := r.createOrUpdateDeployment(ctx, objectStore, strings.Join(endpoints, ","), configHash)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("failed to create or update deployment: %w", err)
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to create or update deployment: %w", err)
 	}
 	r.Logger.Info("successful deployed", "DeploymentResults", reconcileResult)
+	if stopped {
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonProgressing,
+			fmt.Sprintf("gateway is stopped for the upgrade to %q", objectStore.Status.Upgrade.ToImage))
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+	if snapshotName := quiescedBy(objectStore); snapshotName != "" {
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonProgressing,
+			fmt.Sprintf("gateway is stopped for ObjectStoreSnapshot %q", snapshotName))
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+
+	// Check whether the pod is ready, the pod watch triggers a new reconcile once it is
+	pod, ready, err := r.getReadyPod(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to check whether pods are ready: %w", err)
+	}
+	if !ready {
+		setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionFalse, reasonProgressing, "waiting for the gateway pod(s) to be ready")
+		return ctrl.Result{RequeueAfter: requeueInterval}, nil
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionDeploymentAvailable, metav1.ConditionTrue, reasonPodsReady, fmt.Sprintf("pod %q is ready", pod.Name))
+	r.completeUpgrade(ctx, objectStore)
 
-	// Wait for the pod to be ready
-	pod, err := r.waitForLabeledPodsToRunWithRetries(ctx, objectStore, 5)
+	// The PVC is bound once the pod has been scheduled with WaitForFirstConsumer storage classes
+	err = r.updatePVCCondition(ctx, objectStore)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("failed to wait for pods to be ready: %w", err)
+		return ctrl.Result{}, err
 	}
 
 	// Bootstrap my own realm
 	if objectStore.Spec.IsMainSite() {
-		err = r.bootstrapRealm(ctx, objectStore, pod, serviceIP)
+		err = r.adoptLegacyRealmTokenSecret(ctx, objectStore)
 		if err != nil {
-			return reconcile.Result{}, fmt.Errorf("failed to bootstrap realm: %w", err)
+			setCondition(objectStore, objectv1alpha1.ConditionRealmBootstrapped, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+			return ctrl.Result{}, err
+		}
+		bootstrapped, err := r.bootstrapRealm(ctx, objectStore, pod, endpoints)
+		if err != nil {
+			setCondition(objectStore, objectv1alpha1.ConditionRealmBootstrapped, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to bootstrap realm: %w", err)
+		}
+		if !bootstrapped {
+			setCondition(objectStore, objectv1alpha1.ConditionRealmBootstrapped, metav1.ConditionFalse, reasonProgressing, "restarting the gateway to apply the realm configuration")
+			return ctrl.Result{RequeueAfter: requeueInterval}, nil
+		}
+		if topology != nil {
+			err = r.reconcileZoneGroups(ctx, objectStore)
+			if err != nil {
+				setCondition(objectStore, objectv1alpha1.ConditionRealmBootstrapped, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+				return ctrl.Result{}, fmt.Errorf("failed to create zonegroups: %w", err)
+			}
+		}
+		setCondition(objectStore, objectv1alpha1.ConditionRealmBootstrapped, metav1.ConditionTrue, reasonRealmBootstrapped, fmt.Sprintf("realm %q bootstrapped", realmName(objectStore)))
+		objectStore.Status.Realm = realmName(objectStore)
+		objectStore.Status.Zone = zoneName(objectStore)
+		objectStore.Status.RealmTokenSecretName = realmTokenSecretMeta(objectStore).Name
+	} else {
+		meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionRealmBootstrapped)
+		if !objectStore.Spec.IsMultisite() {
+			objectStore.Status.RealmTokenSecretName = ""
 		}
 	}
 
-	r.Logger.Info("successfully reconciled", "ObjectStore", req.NamespacedName.String())
+	// A promoted secondary site becomes the master of the realm, the other secondary sites follow the
+	// master of their realm token
+	if objectStore.Spec.IsPromoted() {
+		promoted, err := r.promoteZone(ctx, objectStore, pod, endpoints)
+		if err != nil {
+			setCondition(objectStore, objectv1alpha1.ConditionPromoted, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to promote zone: %w", err)
+		}
+		if !promoted {
+			setCondition(objectStore, objectv1alpha1.ConditionPromoted, metav1.ConditionFalse, reasonProgressing, "restarting the gateway to apply the new period")
+			return ctrl.Result{RequeueAfter: requeueInterval}, nil
+		}
+		setCondition(objectStore, objectv1alpha1.ConditionPromoted, metav1.ConditionTrue, reasonZonePromoted, fmt.Sprintf("zone %q is the master of the realm", zoneName(objectStore)))
+		objectStore.Status.RealmTokenSecretName = realmTokenSecretMeta(objectStore).Name
+	} else {
+		meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionPromoted)
+		if objectStore.Spec.IsMultisite() {
+			following, err := r.followMaster(ctx, objectStore, pod)
+			if err != nil {
+				setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+				return ctrl.Result{}, fmt.Errorf("failed to pull period from the master zone: %w", err)
+			}
+			if !following {
+				setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonProgressing, fmt.Sprintf("restarting the gateway to apply the period of the master at %s", objectStore.Status.MasterEndpoint))
+				return ctrl.Result{RequeueAfter: requeueInterval}, nil
+			}
+			if topology != nil {
+				err = r.reconcileZoneGroupMaster(ctx, objectStore, topology)
+				if err != nil {
+					setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+					return ctrl.Result{}, fmt.Errorf("failed to make zone %q the master of zonegroup %q: %w", zoneName(objectStore), zoneGroupName(objectStore), err)
+				}
+			}
+		}
+	}
+
+	// The site owning the realm token publishes it where the secondary sites can read it
+	if objectStore.Spec.HasRealmTokenExports() && (objectStore.Spec.IsMainSite() || objectStore.Spec.IsPromoted()) {
+		exported, conflicts, err := r.exportRealmToken(ctx, objectStore)
+		if err != nil {
+			setCondition(objectStore, objectv1alpha1.ConditionRealmTokenExported, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to export realm token: %w", err)
+		}
+		if len(conflicts) > 0 {
+			setCondition(objectStore, objectv1alpha1.ConditionRealmTokenExported, metav1.ConditionFalse, reasonRealmTokenExportConflict, fmt.Sprintf("secrets %s already exist and are not realm token exports of this ObjectStore", strings.Join(conflicts, ", ")))
+		} else {
+			setCondition(objectStore, objectv1alpha1.ConditionRealmTokenExported, metav1.ConditionTrue, reasonRealmTokenExported, fmt.Sprintf("realm token exported to %s", strings.Join(exported, ", ")))
+		}
+	} else {
+		meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionRealmTokenExported)
+		err = r.deleteRealmTokenExports(ctx, objectStore, nil)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The users and buckets are managed through the admin ops API with the keys of the operator
+	err = r.reconcileAdminUser(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionAdminUserReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to reconcile admin user: %w", err)
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionAdminUserReady, metav1.ConditionTrue, reasonAdminUserCreated, fmt.Sprintf("admin ops credentials are available in secret %q", adminSecretMeta(objectStore).Name))
+
+	// The replication of a multisite zone is checked periodically, it does not affect the readiness
+	if objectStore.Spec.IsMultisite() || objectStore.Spec.IsMainSite() {
+		return ctrl.Result{RequeueAfter: r.reconcileSyncStatus(ctx, objectStore)}, nil
+	}
+	meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionSyncHealthy)
+	objectStore.Status.Multisite = nil
+
 	return ctrl.Result{}, nil
 }
 
+// updatePVCCondition reports whether the ObjectStore PVC is bound and whether it has the requested size
+func (r *ObjectStoreReconciler) updatePVCCondition(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	pvc := &v1.PersistentVolumeClaim{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: instanceName(objectStore.Name, objectStore.Namespace)}, pvc)
+	if err != nil {
+		return fmt.Errorf("failed to get PVC: %w", err)
+	}
+
+	if pvc.Status.Phase == v1.ClaimBound {
+		setCondition(objectStore, objectv1alpha1.ConditionPVCBound, metav1.ConditionTrue, reasonPVCBound, fmt.Sprintf("PVC %q is bound to %q", pvc.Name, pvc.Spec.VolumeName))
+	} else {
+		setCondition(objectStore, objectv1alpha1.ConditionPVCBound, metav1.ConditionFalse, reasonPVCPending, fmt.Sprintf("PVC %q is %s", pvc.Name, pvc.Status.Phase))
+		return nil
+	}
+
+	// The resize conditions are set by the external resizer and then by the kubelet for the file system
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case v1.PersistentVolumeClaimResizing:
+			setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionFalse, reasonPVCResizing, fmt.Sprintf("PVC %q volume is being expanded", pvc.Name))
+			return nil
+		case v1.PersistentVolumeClaimFileSystemResizePending:
+			setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionFalse, reasonFileSystemResizePending, fmt.Sprintf("PVC %q volume was expanded, waiting for the node to resize the file system", pvc.Name))
+			return nil
+		}
+	}
+
+	requested := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	capacity := pvc.Status.Capacity[v1.ResourceStorage]
+	if capacity.Cmp(requested) < 0 {
+		setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionFalse, reasonPVCResizing, fmt.Sprintf("PVC %q has %s out of the %s requested", pvc.Name, capacity.String(), requested.String()))
+		return nil
+	}
+
+	// Keep a more specific failure reported by reconcilePVC
+	resized := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionPVCResized)
+	if resized != nil && resized.Reason == reasonExpansionNotSupported && resized.ObservedGeneration == objectStore.Generation {
+		return nil
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionTrue, reasonPVCResized, fmt.Sprintf("PVC %q has a capacity of %s", pvc.Name, capacity.String()))
+
+	return nil
+}
+
+// reconcilePVC creates the PVC storing the ObjectStore database from the claim template, and
+// expands it when the storage request of the template grows
+func (r *ObjectStoreReconciler) reconcilePVC(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	// The validating webhook rejects it, but it may not be deployed
+	if objectStore.Spec.VolumeClaimTemplate == nil {
+		return fmt.Errorf("spec.volumeClaimTemplate is required")
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: instanceName(objectStore.Name, objectStore.Namespace)}, pvc)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return r.createPVC(ctx, objectStore)
+		}
+		return fmt.Errorf("failed to get PVC: %w", err)
+	}
+
+	changed := false
+	// A PVC retained from a previously deleted ObjectStore is adopted again
+	if !metav1.IsControlledBy(pvc, objectStore) {
+		err = controllerutil.SetControllerReference(objectStore, pvc, r.Scheme)
+		if err != nil {
+			return fmt.Errorf("failed to set owner reference to PVC %q: %w", pvc.Name, err)
+		}
+		changed = true
+	}
+	for key, value := range getLabels(objectStore.Name) {
+		if pvc.Labels[key] != value {
+			if pvc.Labels == nil {
+				pvc.Labels = map[string]string{}
+			}
+			pvc.Labels[key] = value
+			changed = true
+		}
+	}
+
+	// The rest of the PVC spec is immutable, only the storage request can grow. Without a request
+	// in the template, e.g. when the webhook is not deployed, the PVC keeps its size.
+	desired, ok := objectStore.Spec.VolumeClaimTemplate.Spec.Resources.Requests[v1.ResourceStorage]
+	current := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	comparison := 0
+	if ok {
+		comparison = desired.Cmp(current)
+	}
+	switch comparison {
+	case 0:
+		// A shrink or an expansion that was refused is reverted, the capacity is reported by
+		// updatePVCCondition
+		resized := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionPVCResized)
+		if resized != nil && resized.Reason == reasonExpansionNotSupported {
+			meta.RemoveStatusCondition(&objectStore.Status.Conditions, objectv1alpha1.ConditionPVCResized)
+		}
+	case 1:
+		expandable, err := r.isPVCExpandable(ctx, pvc)
+		if err != nil {
+			return err
+		}
+		if !expandable {
+			setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionFalse, reasonExpansionNotSupported, fmt.Sprintf("the storage class of PVC %q does not allow volume expansion", pvc.Name))
+			break
+		}
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = desired
+		changed = true
+		r.Logger.Info("expanding PVC", "PVC", pvc.Name, "from", current.String(), "to", desired.String())
+	case -1:
+		setCondition(objectStore, objectv1alpha1.ConditionPVCResized, metav1.ConditionFalse, reasonExpansionNotSupported, fmt.Sprintf("PVC %q cannot shrink from %s to %s", pvc.Name, current.String(), desired.String()))
+	}
+
+	if !changed {
+		return nil
+	}
+
+	err = r.Client.Update(ctx, pvc)
+	if err != nil {
+		return fmt.Errorf("failed to update PVC %q: %w", pvc.Name, err)
+	}
+	r.Logger.Info("successfully updated", "PVC", pvc.Name)
+
+	return nil
+}
+
 // createPVC will create a PVC for the given ObjectStore
-// It will be used to store the ObjectStore database
+// It will be used to store the ObjectStore database, the dataSource of the template is kept so that
+// the PVC can be created from the VolumeSnapshot of an ObjectStoreSnapshot
 func (r *ObjectStoreReconciler) createPVC(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
-	volumeMode := v1.PersistentVolumeFilesystem
 	pvc := &v1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      instanceName(objectStore.Name, objectStore.Namespace),
-			Namespace: objectStore.Namespace,
+			Name:        instanceName(objectStore.Name, objectStore.Namespace),
+			Namespace:   objectStore.Namespace,
+			Labels:      map[string]string{},
+			Annotations: objectStore.Spec.VolumeClaimTemplate.Annotations,
 		},
-		Spec: objectStore.Spec.VolumeClaimTemplate.Spec,
+		Spec: *objectStore.Spec.VolumeClaimTemplate.Spec.DeepCopy(),
+	}
+	for key, value := range objectStore.Spec.VolumeClaimTemplate.Labels {
+		pvc.Labels[key] = value
+	}
+	for key, value := range getLabels(objectStore.Name) {
+		pvc.Labels[key] = value
 	}
 
-	// TODO: do not override user's settings
-	pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
-	pvc.Spec.VolumeMode = &volumeMode
+	// Only fill what the defaulting webhook would have, the database needs a file system
+	if len(pvc.Spec.AccessModes) == 0 {
+		pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	}
+	if pvc.Spec.VolumeMode == nil {
+		volumeMode := v1.PersistentVolumeFilesystem
+		pvc.Spec.VolumeMode = &volumeMode
+	}
 
-	err := r.Create(ctx, pvc, &client.CreateOptions{})
+	// Set ObjectStore instance as the owner and controller of the PVC so that it is watched.
+	err := controllerutil.SetControllerReference(objectStore, pvc, r.Scheme)
 	if err != nil {
-		if kerrors.IsAlreadyExists(err) {
-			r.Logger.Info("PVC", pvc.Name, "already exists")
-			return nil
-		} else {
-			return fmt.Errorf("failed to create PVC %q: %w", pvc.Name, err)
-		}
+		return fmt.Errorf("failed to set owner reference to PVC %q: %w", pvc.Name, err)
+	}
+
+	err = r.Create(ctx, pvc, &client.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create PVC %q: %w", pvc.Name, err)
 	}
 	r.Logger.Info("successfully provisioned", "PVC", pvc.Name)
 
 	return nil
 }
 
-func (r *ObjectStoreReconciler) configureMultisite(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, serviceIP string) error {
-	secret := &v1.Secret{}
-	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: objectStore.Spec.Multisite.RealmTokenSecretName}, secret)
+// isPVCExpandable returns whether the storage class of the PVC allows volume expansion
+func (r *ObjectStoreReconciler) isPVCExpandable(ctx context.Context, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+
+	storageClass := &storagev1.StorageClass{}
+	err := r.Client.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, storageClass)
 	if err != nil {
-		return fmt.Errorf("failed to get realm token secret %q: %w", objectStore.Spec.Multisite.RealmTokenSecretName, err)
+		return false, fmt.Errorf("failed to get storage class %q: %w", *pvc.Spec.StorageClassName, err)
+	}
+
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// configureMultisite runs the job creating the zone from the realm token of the main site, it
+// returns true once the job has completed and reports its progress in the MultisiteConfigured
+// condition otherwise
+func (r *ObjectStoreReconciler) configureMultisite(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoints []string) (bool, error) {
+	secret, err := r.getRealmTokenSecret(ctx, objectStore)
+	if err != nil {
+		return false, err
 	}
 
 	realmToken := string(secret.Data["token"])
 	if realmToken == "" {
-		return fmt.Errorf("failed to find realm token secret, 'token' key missing or empty?")
+		return false, fmt.Errorf("failed to find realm token secret, 'token' key missing or empty?")
 	}
 
-	port := int32(8080)
-	if objectStore.Spec.Gateway.Port != 0 {
-		port = objectStore.Spec.Gateway.Port
+	// The zone job mounts the token, a secret of another namespace is copied next to it
+	err = r.copyRealmToken(ctx, objectStore, secret)
+	if err != nil {
+		return false, err
 	}
 
-	// Create multisite zone
-	// The realm token is stored in the zone secret and mounted in the pod
-	err = r.createMultisiteZoneJob(ctx, objectStore, fmt.Sprintf("http://%s:%d", serviceIP, port))
+	// The realm name is only known from the token on secondary sites
+	tokenInfo, err := decodeRealmToken(realmToken)
 	if err != nil {
-		return fmt.Errorf("failed to create multisite zone job: %w", err)
+		r.Logger.Info("failed to read the realm name from the realm token", "error", err.Error())
+	} else {
+		objectStore.Status.Realm = tokenInfo.RealmName
 	}
 
-	// Wait for the multisite zone job completion
-	err = r.waitForJobCompletion(ctx, multisiteJobMeta(objectStore), time.Minute)
+	progressing := fmt.Sprintf("waiting for job %q to create zone %q", multisiteJobMeta(objectStore).Name, zoneName(objectStore))
+	jobHash := zoneJobHash(objectStore, strings.Join(endpoints, ","), realmToken)
+	job, err := r.getMultisiteJob(ctx, objectStore)
 	if err != nil {
-		return fmt.Errorf("failed to wait for multisite zone job to complete: %w", err)
+		if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get multisite zone job %q: %w", job.Name, err)
+		}
+
+		// Create multisite zone
+		// The realm token is stored in the zone secret and mounted in the pod
+		err = r.createMultisiteZoneJob(ctx, objectStore, strings.Join(endpoints, ","), jobHash)
+		if err != nil {
+			return false, fmt.Errorf("failed to create multisite zone job: %w", err)
+		}
+		r.Logger.Info("created multisite zone job", "Job", job.Name)
+		setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonProgressing, progressing)
+
+		return false, nil
+	}
+
+	completed, err := jobCompleted(job)
+	if err != nil {
+		// Running the same job again would fail the same way, it is kept until its inputs change
+		if job.Annotations[zoneJobHashAnnotation] == jobHash {
+			setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonZoneJobFailed, r.jobFailureMessage(ctx, job, err))
+			return false, nil
+		}
+
+		// Delete the failed job so that it is created again on the next reconcile
+		err = r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete failed multisite zone job %q: %w", job.Name, err)
+		}
+		r.Logger.Info("deleted failed multisite zone job, its spec or realm token changed", "Job", job.Name)
+		setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonProgressing, progressing)
+		return false, nil
 	}
+	if !completed {
+		setCondition(objectStore, objectv1alpha1.ConditionMultisiteConfigured, metav1.ConditionFalse, reasonProgressing, progressing)
+		return false, nil
+	}
+
+	objectStore.Status.Zone = zoneName(objectStore)
+	objectStore.Status.RealmTokenSecretName = localRealmTokenSecretName(objectStore)
 	r.Logger.Info("successfully configured multisite")
 
-	return nil
+	return true, nil
+}
+
+// jobFailureMessage returns why a job failed, from the termination message of its last failed pod
+// when there is one
+func (r *ObjectStoreReconciler) jobFailureMessage(ctx context.Context, job *batchv1.Job, jobErr error) string {
+	pods := &v1.PodList{}
+	err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		r.Logger.Info("failed to list the pods of the failed job", "Job", job.Name, "error", err.Error())
+		return jobErr.Error()
+	}
+
+	var last *v1.ContainerStateTerminated
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.ExitCode == 0 || strings.TrimSpace(terminated.Message) == "" {
+				continue
+			}
+			if last == nil || last.FinishedAt.Before(&terminated.FinishedAt) {
+				last = terminated
+			}
+		}
+	}
+	if last == nil {
+		return jobErr.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", jobErr.Error(), redact(lastLine(last.Message)))
 }
 
 // bootstrapRealm bootstrap my own realm in case another gw wants to connect with me
-func (r *ObjectStoreReconciler) bootstrapRealm(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, pod v1.Pod, serviceIP string) error {
-	secret := &v1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "object-store-realm-token",
-			Namespace: objectStore.Namespace,
-		},
+// It returns true once the realm token secret exists, the gateway pod is restarted right after the
+// bootstrap so the caller must wait for it to run again.
+func (r *ObjectStoreReconciler) bootstrapRealm(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, pod v1.Pod, endpoints []string) (bool, error) {
+	secret := realmTokenSecretMeta(objectStore)
+
+	// The secret is only created once the realm has been bootstrapped
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+	if err == nil {
+		return true, nil
 	}
-	err := controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
-	if err != nil {
-		return fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+	if !kerrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get realm token secret %q: %w", secret.Name, err)
 	}
 
-	port := int32(8080)
-	if objectStore.Spec.Gateway.Port != 0 {
-		port = objectStore.Spec.Gateway.Port
+	err = controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
+	if err != nil {
+		return false, fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
 	}
 
 	output, stderr, err := r.RemotePodCommandExecutor.ExecCommandInContainerWithFullOutputWithTimeout(
@@ -242,24 +907,30 @@ func (r *ObjectStoreReconciler) bootstrapRealm(ctx context.Context, objectStore
 		objectStore.Namespace,
 		append([]string{
 			"rgwam-sqlite"},
-			[]string{
-				"realm",
-				"bootstrap",
-				fmt.Sprintf("--realm=%s-%s", objectStore.Name, objectStore.Namespace),
-				fmt.Sprintf("--endpoints=http://%s:%d", serviceIP, port),
-			}...,
+			bootstrapRealmArgs(objectStore, endpoints)...,
 		)...,
 	)
 	// TODO: re-add this once rgwam-sqlite stops logging to stderr
 	// if err != nil || stderr != "" {
 	if err != nil {
 		if code, err := extractExitCode(err); err == nil {
-			if code != int(syscall.EEXIST) {
-				r.Logger.Info("realm has been created already")
-				return nil
+			if code == int(syscall.EEXIST) {
+				// The realm is already in the database, e.g. restored from a backup, only its secret
+				// is missing. The gateway already runs with the realm so it is not restarted.
+				r.Logger.Info("realm has been created already, recovering its token", "Realm", realmName(objectStore))
+				token, err := r.recoverRealmToken(ctx, objectStore, endpoints)
+				if err != nil {
+					return false, err
+				}
+				secret.Data = map[string][]byte{"token": []byte(token)}
+				err = r.Client.Create(ctx, secret)
+				if err != nil && !kerrors.IsAlreadyExists(err) {
+					return false, fmt.Errorf("failed to create realm token secret: %w", err)
+				}
+				return true, nil
 			}
 			if code != 0 || strings.Contains(stderr, "ERROR") {
-				return fmt.Errorf("failed to bootstrap realm: %w", err)
+				return false, fmt.Errorf("failed to bootstrap realm: %w", err)
 			}
 		}
 	}
@@ -267,7 +938,7 @@ func (r *ObjectStoreReconciler) bootstrapRealm(ctx context.Context, objectStore
 	// Parse the output to get the token
 	outputParse := regexp.MustCompile(`^Realm Token: (\S+)$`).FindStringSubmatch(output)
 	if len(outputParse) != 2 {
-		return fmt.Errorf("failed to parse realm token from output: %s", output)
+		return false, fmt.Errorf("failed to parse realm token from output: %s", redact(output))
 	}
 
 	token := outputParse[1]
@@ -279,27 +950,65 @@ func (r *ObjectStoreReconciler) bootstrapRealm(ctx context.Context, objectStore
 		if err != nil {
 			if kerrors.IsAlreadyExists(err) {
 				r.Logger.Info("Realm Secret", secret.Name, "already exists")
-				return nil
+				return true, nil
 			}
-			return fmt.Errorf("failed to create realm token secret: %w", err)
+			return false, fmt.Errorf("failed to create realm token secret: %w", err)
 		}
 
 	} else {
-		return fmt.Errorf("failed to parse realm token")
+		return false, fmt.Errorf("failed to parse realm token")
 	}
 
+	// The pod watch triggers a new reconcile once the new pod runs
 	r.Logger.Info("deleting pod to restart the gateway and apply realm configuration", "Pod", pod.Name)
 	err = r.Client.Delete(ctx, pod.DeepCopy())
 	if err != nil {
-		return fmt.Errorf("failed to delete pod %q: %w", pod.Name, err)
+		return false, fmt.Errorf("failed to delete pod %q: %w", pod.Name, err)
 	}
 
-	// Wait for the pod to be ready
-	_, err = r.waitForLabeledPodsToRunWithRetries(ctx, objectStore, 5)
+	r.Logger.Info("successfully configured realm")
+	return false, nil
+}
+
+// recoverRealmToken rebuilds the token of a realm bootstrapped earlier from the realm and the
+// system key of its master zone, the same content `rgwam-sqlite realm bootstrap` prints
+func (r *ObjectStoreReconciler) recoverRealmToken(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoints []string) (string, error) {
+	output, err := radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "realm", "get", fmt.Sprintf("--rgw-realm=%s", realmName(objectStore)))
+	if err != nil {
+		return "", err
+	}
+	realm := struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	err = json.Unmarshal([]byte(output), &realm)
 	if err != nil {
-		return fmt.Errorf("failed to wait for pods to be ready: %w", err)
+		return "", fmt.Errorf("failed to parse realm %q: %w", realmName(objectStore), err)
 	}
 
-	r.Logger.Info("successfully configured realm")
-	return nil
+	output, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "zone", "get", fmt.Sprintf("--rgw-zone=%s", zoneName(objectStore)))
+	if err != nil {
+		return "", err
+	}
+	zone := struct {
+		SystemKey struct {
+			AccessKey string `json:"access_key"`
+			SecretKey string `json:"secret_key"`
+		} `json:"system_key"`
+	}{}
+	err = json.Unmarshal([]byte(output), &zone)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse zone %q: %w", zoneName(objectStore), err)
+	}
+	if zone.SystemKey.AccessKey == "" || zone.SystemKey.SecretKey == "" {
+		return "", fmt.Errorf("realm %q exists but zone %q has no system key, its token cannot be recovered", realm.Name, zoneName(objectStore))
+	}
+
+	return encodeRealmToken(&realmTokenInfo{
+		RealmName: realm.Name,
+		RealmID:   realm.ID,
+		Endpoint:  endpoints[0],
+		AccessKey: zone.SystemKey.AccessKey,
+		SecretKey: zone.SystemKey.SecretKey,
+	})
 }
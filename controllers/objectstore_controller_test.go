@@ -0,0 +1,208 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcilePVC(t *testing.T) {
+	tests := []struct {
+		name          string
+		request       string
+		condition     *metav1.Condition
+		wantCondition string
+		wantSize      string
+	}{
+		{
+			name:     "no storage request",
+			wantSize: "10Gi",
+		},
+		{
+			name:          "shrink",
+			request:       "5Gi",
+			wantCondition: reasonExpansionNotSupported,
+			wantSize:      "10Gi",
+		},
+		{
+			name:    "shrink reverted",
+			request: "10Gi",
+			condition: &metav1.Condition{
+				Type:   objectv1alpha1.ConditionPVCResized,
+				Status: metav1.ConditionFalse,
+				Reason: reasonExpansionNotSupported,
+			},
+			wantSize: "10Gi",
+		},
+		{
+			name:    "resize in progress",
+			request: "10Gi",
+			condition: &metav1.Condition{
+				Type:   objectv1alpha1.ConditionPVCResized,
+				Status: metav1.ConditionFalse,
+				Reason: reasonFileSystemResizePending,
+			},
+			wantCondition: reasonFileSystemResizePending,
+			wantSize:      "10Gi",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := &objectv1alpha1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "rgw", UID: "uid"},
+				Spec:       objectv1alpha1.ObjectStoreSpec{VolumeClaimTemplate: &v1.PersistentVolumeClaim{}},
+			}
+			if test.request != "" {
+				objectStore.Spec.VolumeClaimTemplate.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse(test.request)}
+			}
+			if test.condition != nil {
+				meta.SetStatusCondition(&objectStore.Status.Conditions, *test.condition)
+			}
+			pvc := &v1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: instanceName(objectStore.Name, objectStore.Namespace), Namespace: objectStore.Namespace},
+				Spec: v1.PersistentVolumeClaimSpec{
+					Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("10Gi")}},
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(pvc).Build()
+			r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+			if err := r.reconcilePVC(context.Background(), objectStore); err != nil {
+				t.Fatalf("reconcilePVC() = %v", err)
+			}
+
+			condition := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionPVCResized)
+			if (condition == nil && test.wantCondition != "") || (condition != nil && condition.Reason != test.wantCondition) {
+				t.Errorf("PVCResized condition %+v, want reason %q", condition, test.wantCondition)
+			}
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(pvc), pvc); err != nil {
+				t.Fatalf("failed to get PVC: %v", err)
+			}
+			if size := pvc.Spec.Resources.Requests[v1.ResourceStorage]; size.String() != test.wantSize {
+				t.Errorf("PVC size %s, want %s", size.String(), test.wantSize)
+			}
+		})
+	}
+}
+
+func TestConfigureMultisite(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "rgw", UID: "uid"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Image:     "quay.io/ceph/ceph:v17.2.3",
+			Multisite: &objectv1alpha1.MultisiteSpec{RealmTokenSecretName: "token"},
+		},
+	}
+	endpoints := []string{"http://10.0.0.1:8080"}
+	failed := func(jobHash string) *batchv1.Job {
+		job := multisiteJobMeta(objectStore)
+		job.Annotations = map[string]string{zoneJobHashAnnotation: jobHash}
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Message: "Job has reached the specified backoff limit"}}
+		return job
+	}
+	failedPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "zone-job-1", Namespace: "rgw", Labels: map[string]string{"job-name": multisiteJobMeta(objectStore).Name}},
+		Status: v1.PodStatus{
+			ContainerStatuses: []v1.ContainerStatus{{
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Message: "debug\nfailed to pull the period from the master\n"}},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		job           *batchv1.Job
+		wantReason    string
+		wantMessage   string
+		wantJobExists bool
+		wantJobHash   string
+	}{
+		{
+			name:          "job created",
+			wantReason:    reasonProgressing,
+			wantJobExists: true,
+			wantJobHash:   zoneJobHash(objectStore, endpoints[0], "token"),
+		},
+		{
+			name:          "job failed",
+			job:           failed(zoneJobHash(objectStore, endpoints[0], "token")),
+			wantReason:    reasonZoneJobFailed,
+			wantMessage:   "failed to pull the period from the master",
+			wantJobExists: true,
+			wantJobHash:   zoneJobHash(objectStore, endpoints[0], "token"),
+		},
+		{
+			name:       "job failed with another realm token",
+			job:        failed(zoneJobHash(objectStore, endpoints[0], "previous")),
+			wantReason: reasonProgressing,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := objectStore.DeepCopy()
+			token := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "rgw"},
+				Data:       map[string][]byte{"token": []byte("token")},
+			}
+			objects := []client.Object{token, failedPod}
+			if test.job != nil {
+				objects = append(objects, test.job)
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+			r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+			configured, err := r.configureMultisite(context.Background(), objectStore, endpoints)
+			if err != nil || configured {
+				t.Fatalf("configureMultisite() = %v, %v, want false, nil", configured, err)
+			}
+
+			condition := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionMultisiteConfigured)
+			if condition == nil || condition.Reason != test.wantReason || !strings.Contains(condition.Message, test.wantMessage) {
+				t.Errorf("MultisiteConfigured condition %+v, want reason %q and message %q", condition, test.wantReason, test.wantMessage)
+			}
+
+			job := multisiteJobMeta(objectStore)
+			err = c.Get(context.Background(), client.ObjectKeyFromObject(job), job)
+			if (err == nil) != test.wantJobExists {
+				t.Fatalf("job exists %v, want %v", err == nil, test.wantJobExists)
+			}
+			if !test.wantJobExists {
+				return
+			}
+			if job.Annotations[zoneJobHashAnnotation] != test.wantJobHash {
+				t.Errorf("job hash %q, want %q", job.Annotations[zoneJobHashAnnotation], test.wantJobHash)
+			}
+			if test.job == nil && (*job.Spec.BackoffLimit != zoneJobBackoffLimit || job.Spec.Template.Spec.RestartPolicy != v1.RestartPolicyNever) {
+				t.Errorf("job retries %d times with restart policy %s", *job.Spec.BackoffLimit, job.Spec.Template.Spec.RestartPolicy)
+			}
+		})
+	}
+}
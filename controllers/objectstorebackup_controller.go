@@ -0,0 +1,485 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"github.com/redhat-et/rgw-standalone-operator/pkg/s3"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reasons used in the ObjectStoreBackup conditions
+const (
+	reasonScheduled       = "Scheduled"
+	reasonBackupCompleted = "BackupCompleted"
+	reasonBackupFailed    = "BackupFailed"
+)
+
+const (
+	// backupLabel is set on the backup jobs, its value is the name of the ObjectStoreBackup
+	backupLabel = "object_store_backup"
+
+	// backupStagingDirectory is where the archive is written before it is uploaded
+	backupStagingDirectory = "/var/lib/ceph/radosgw/staging"
+
+	// backupResyncInterval is how often the status of the backup jobs is checked
+	backupResyncInterval = 5 * time.Minute
+
+	// backupRetentionInterval is how often the retention is applied when no new backup completed,
+	// for the backups expiring with maxAgeDays
+	backupRetentionInterval = time.Hour
+
+	defaultBackupKeepLast = 7
+)
+
+// ObjectStoreBackupReconciler reconciles a ObjectStoreBackup object
+type ObjectStoreBackupReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstorebackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstorebackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstorebackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores,verbs=get;list;watch
+//+kubebuilder:rbac:groups="batch",resources=cronjobs,verbs=create;delete;get;update;list;watch
+//+kubebuilder:rbac:groups="batch",resources=jobs,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectStoreBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectStoreBackup{}).
+		Owns(&batchv1.CronJob{}).
+		// The jobs are owned by the CronJob, they are mapped through their label
+		Watches(&source.Kind{Type: &batchv1.Job{}}, handler.EnqueueRequestsFromMapFunc(backupForJob)).
+		Complete(r)
+}
+
+// backupForJob maps a backup job to its ObjectStoreBackup
+func backupForJob(object client.Object) []reconcile.Request {
+	name, ok := object.GetLabels()[backupLabel]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: name}},
+	}
+}
+
+// Reconcile schedules the backups of the ObjectStore, records their results and applies the retention
+func (r *ObjectStoreBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectStoreBackup", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	backup := &objectv1alpha1.ObjectStoreBackup{}
+	err := r.Client.Get(ctx, req.NamespacedName, backup)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectStoreBackup resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectStoreBackup: %w", err)
+	}
+
+	// The CronJob is owned by the ObjectStoreBackup and the archives are kept on the target, so
+	// there is nothing to clean up on deletion
+	if !backup.GetDeletionTimestamp().IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	original := backup.DeepCopy()
+	err = r.reconcileBackup(ctx, backup)
+	if err != nil {
+		setStatusCondition(&backup.Status.Conditions, backup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	backup.Status.Phase = phaseFromConditions(backup.Status.Conditions, err)
+	backup.Status.ObservedGeneration = backup.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, backup, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectStoreBackup %q: %w", backup.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectStoreBackup", req.NamespacedName.String(), "Phase", backup.Status.Phase)
+	return reconcile.Result{RequeueAfter: backupResyncInterval}, nil
+}
+
+func (r *ObjectStoreBackupReconciler) reconcileBackup(ctx context.Context, backup *objectv1alpha1.ObjectStoreBackup) error {
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.ObjectStoreName}, objectStore)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			setStatusCondition(&backup.Status.Conditions, backup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotFound,
+				fmt.Sprintf("ObjectStore %q not found", backup.Spec.ObjectStoreName))
+			return nil
+		}
+		return fmt.Errorf("failed to get ObjectStore %q: %w", backup.Spec.ObjectStoreName, err)
+	}
+
+	cronJob, err := r.reconcileCronJob(ctx, backup, objectStore)
+	if err != nil {
+		return err
+	}
+	backup.Status.LastScheduleTime = cronJob.Status.LastScheduleTime
+
+	err = r.updateLastRun(ctx, backup)
+	if err != nil {
+		return err
+	}
+
+	if retentionDue(backup, time.Now()) {
+		err = r.applyRetention(ctx, backup)
+		if err != nil {
+			return err
+		}
+	}
+
+	setStatusCondition(&backup.Status.Conditions, backup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonScheduled,
+		fmt.Sprintf("backups of ObjectStore %q are scheduled at %q", objectStore.Name, backup.Spec.Schedule))
+	return nil
+}
+
+// reconcileCronJob creates the CronJob running the backups. Its pods run next to the gateway since
+// they mount its PVC, which is usually ReadWriteOnce.
+func (r *ObjectStoreBackupReconciler) reconcileCronJob(ctx context.Context, backup *objectv1alpha1.ObjectStoreBackup, objectStore *objectv1alpha1.ObjectStore) (*batchv1.CronJob, error) {
+	cronJob := &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupCronJobName(backup),
+			Namespace: backup.Namespace,
+		},
+	}
+
+	err := controllerutil.SetControllerReference(backup, cronJob, r.Scheme)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set owner reference to cron job %q: %w", cronJob.Name, err)
+	}
+
+	mutateFunc := func() error {
+		suspend := backup.Spec.Suspend
+		backoffLimit := int32(1)
+		historyLimit := int32(3)
+
+		podSpec := v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:    "backup",
+					Image:   gatewayImage(objectStore),
+					Command: []string{"python3", "-c", backupScript},
+					Env:     backupEnvVars(backup),
+					VolumeMounts: []v1.VolumeMount{
+						daemonVolumeMountPVC(),
+						{Name: "staging", MountPath: backupStagingDirectory},
+					},
+				},
+			},
+			Volumes: []v1.Volume{
+				DaemonVolumesDataPVC(instanceName(objectStore.Name, objectStore.Namespace)),
+				{Name: "staging", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+			},
+			RestartPolicy: v1.RestartPolicyNever,
+			SecurityContext: &v1.PodSecurityContext{
+				RunAsUser:  &CephUID,
+				RunAsGroup: &cephGID,
+				FSGroup:    &CephUID,
+			},
+		}
+		applyPodPlacement(objectStore, &podSpec)
+		addGatewayPodAffinity(objectStore, &podSpec)
+
+		cronJob.Spec = batchv1.CronJobSpec{
+			Schedule:                   backup.Spec.Schedule,
+			Suspend:                    &suspend,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{backupLabel: backup.Name},
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: v1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: map[string]string{backupLabel: backup.Name},
+						},
+						Spec: podSpec,
+					},
+				},
+			},
+		}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, cronJob, mutateFunc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create or update cron job %q: %w", cronJob.Name, err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("backup cron job", "CronJob", cronJob.Name, "opResult", opResult)
+	}
+
+	return cronJob, nil
+}
+
+// updateLastRun records the result of the last finished backup job
+func (r *ObjectStoreBackupReconciler) updateLastRun(ctx context.Context, backup *objectv1alpha1.ObjectStoreBackup) error {
+	jobs := &batchv1.JobList{}
+	err := r.Client.List(ctx, jobs, client.InNamespace(backup.Namespace), client.MatchingLabels{backupLabel: backup.Name})
+	if err != nil {
+		return fmt.Errorf("failed to list backup jobs: %w", err)
+	}
+
+	var lastJob *batchv1.Job
+	var lastFinished time.Time
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		finished, ok := jobFinishedTime(job)
+		if ok && finished.After(lastFinished) {
+			lastJob = job
+			lastFinished = finished
+		}
+	}
+	if lastJob == nil {
+		return nil
+	}
+
+	_, err = jobCompleted(lastJob)
+	if err != nil {
+		setStatusCondition(&backup.Status.Conditions, backup.Generation, objectv1alpha1.ConditionBackupSucceeded, metav1.ConditionFalse, reasonBackupFailed, err.Error())
+		return nil
+	}
+
+	backup.Status.LastSuccessfulTime = lastJob.Status.CompletionTime
+	backup.Status.LastBackup = backupKey(backup, lastJob.Name)
+	setStatusCondition(&backup.Status.Conditions, backup.Generation, objectv1alpha1.ConditionBackupSucceeded, metav1.ConditionTrue, reasonBackupCompleted,
+		fmt.Sprintf("backup %q uploaded to bucket %q", backup.Status.LastBackup, backup.Spec.Target.Bucket))
+	return nil
+}
+
+// retentionDue returns whether the retention must be applied: a backup completed or the spec
+// changed since it was last applied, or it was applied too long ago
+func retentionDue(backup *objectv1alpha1.ObjectStoreBackup, now time.Time) bool {
+	last := backup.Status.LastRetentionTime
+	if last == nil || backup.Status.ObservedGeneration != backup.Generation {
+		return true
+	}
+	if backup.Status.LastSuccessfulTime != nil && backup.Status.LastSuccessfulTime.After(last.Time) {
+		return true
+	}
+	return now.Sub(last.Time) >= backupRetentionInterval
+}
+
+// applyRetention deletes the backups beyond the retention from the target and records the
+// remaining ones, the most recent backup is always kept. Only the archives uploaded by the jobs of
+// this ObjectStoreBackup are considered, the prefix may be shared.
+func (r *ObjectStoreBackupReconciler) applyRetention(ctx context.Context, backup *objectv1alpha1.ObjectStoreBackup) error {
+	s3Client, err := backupTargetClient(ctx, r.Client, backup)
+	if err != nil {
+		return err
+	}
+
+	objects, err := s3Client.ListObjects(ctx, backup.Spec.Target.Bucket, backupPrefix(backup))
+	if err != nil {
+		return fmt.Errorf("failed to list backups in bucket %q: %w", backup.Spec.Target.Bucket, err)
+	}
+	archives := []s3.Object{}
+	for _, object := range objects {
+		if isBackupArchive(backup, object.Key) {
+			archives = append(archives, object)
+		}
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].LastModified.After(archives[j].LastModified) })
+
+	keepLast := int(backup.Spec.Retention.KeepLast)
+	if keepLast == 0 {
+		keepLast = defaultBackupKeepLast
+	}
+	var oldest time.Time
+	if backup.Spec.Retention.MaxAgeDays > 0 {
+		oldest = time.Now().AddDate(0, 0, -int(backup.Spec.Retention.MaxAgeDays))
+	}
+
+	records := []objectv1alpha1.BackupRecord{}
+	for i, archive := range archives {
+		if i > 0 && (i >= keepLast || archive.LastModified.Before(oldest)) {
+			err = s3Client.DeleteObject(ctx, backup.Spec.Target.Bucket, archive.Key)
+			if err != nil {
+				return fmt.Errorf("failed to delete expired backup %q: %w", archive.Key, err)
+			}
+			r.Logger.Info("deleted expired backup", "key", archive.Key)
+			continue
+		}
+		records = append(records, objectv1alpha1.BackupRecord{
+			Key:  archive.Key,
+			Time: metav1.NewTime(archive.LastModified),
+			Size: archive.Size,
+		})
+	}
+	backup.Status.Backups = records
+	now := metav1.Now()
+	backup.Status.LastRetentionTime = &now
+
+	return nil
+}
+
+// backupTargetClient returns an S3 client for the target of the backup
+func backupTargetClient(ctx context.Context, c client.Client, backup *objectv1alpha1.ObjectStoreBackup) (*s3.Client, error) {
+	secret := &v1.Secret{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.Target.CredentialsSecretName}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials secret %q: %w", backup.Spec.Target.CredentialsSecretName, err)
+	}
+
+	s3Client := s3.NewClient(backup.Spec.Target.Endpoint,
+		string(secret.Data["AWS_ACCESS_KEY_ID"]),
+		string(secret.Data["AWS_SECRET_ACCESS_KEY"]),
+		nil,
+	)
+	if backup.Spec.Target.Region != "" {
+		s3Client.Region = backup.Spec.Target.Region
+	}
+
+	return s3Client, nil
+}
+
+func backupEnvVars(backup *objectv1alpha1.ObjectStoreBackup) []v1.EnvVar {
+	return append([]v1.EnvVar{
+		// The job name is unique per run, it names the archive
+		{Name: "JOB_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels['job-name']"}}},
+		{Name: "EXCLUDED_DIRS", Value: upgradeBackupDirectory},
+	}, s3TargetEnvVars(&backup.Spec.Target, backupKey(backup, ContainerEnvVarReference("JOB_NAME")))...)
+}
+
+// s3TargetEnvVars returns the environment of the backup and restore scripts for the archive at key
+func s3TargetEnvVars(target *objectv1alpha1.BackupTarget, key string) []v1.EnvVar {
+	credential := func(name string) v1.EnvVar {
+		return v1.EnvVar{
+			Name: name,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: target.CredentialsSecretName},
+					Key:                  name,
+				},
+			},
+		}
+	}
+
+	return []v1.EnvVar{
+		{Name: "DATA_DIR", Value: objectStoreDataDirectory},
+		{Name: "STAGING_DIR", Value: backupStagingDirectory},
+		{Name: "S3_ENDPOINT", Value: target.Endpoint},
+		{Name: "S3_REGION", Value: target.Region},
+		{Name: "S3_BUCKET", Value: target.Bucket},
+		{Name: "S3_KEY", Value: key},
+		credential("AWS_ACCESS_KEY_ID"),
+		credential("AWS_SECRET_ACCESS_KEY"),
+	}
+}
+
+// addGatewayPodAffinity schedules the pod on the node of the gateway so that it can mount the PVC
+func addGatewayPodAffinity(objectStore *objectv1alpha1.ObjectStore, podSpec *v1.PodSpec) {
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &v1.Affinity{}
+	} else {
+		podSpec.Affinity = podSpec.Affinity.DeepCopy()
+	}
+	if podSpec.Affinity.PodAffinity == nil {
+		podSpec.Affinity.PodAffinity = &v1.PodAffinity{}
+	}
+	podSpec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution = append(
+		podSpec.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution,
+		v1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: getLabels(objectStore.Name)},
+			TopologyKey:   v1.LabelHostname,
+		},
+	)
+}
+
+// jobFinishedTime returns when the job succeeded or failed
+func jobFinishedTime(job *batchv1.Job) (time.Time, bool) {
+	for _, condition := range job.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == v1.ConditionTrue {
+			return condition.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func backupCronJobName(backup *objectv1alpha1.ObjectStoreBackup) string {
+	return fmt.Sprintf("%s-backup-%s", appName, backup.Name)
+}
+
+// backupPrefix returns the prefix of the backup keys in the target bucket
+func backupPrefix(backup *objectv1alpha1.ObjectStoreBackup) string {
+	if backup.Spec.Target.Prefix != "" {
+		return backup.Spec.Target.Prefix
+	}
+	return fmt.Sprintf("%s/%s/", backup.Namespace, backup.Spec.ObjectStoreName)
+}
+
+// isBackupArchive returns whether the key is an archive uploaded by a job of the backup, the jobs of
+// a CronJob are named after it with the scheduled time as suffix
+func isBackupArchive(backup *objectv1alpha1.ObjectStoreBackup, key string) bool {
+	name := strings.TrimPrefix(key, backupPrefix(backup))
+	if name == key && backupPrefix(backup) != "" {
+		return false
+	}
+	suffix := strings.TrimPrefix(name, backupCronJobName(backup)+"-")
+	if suffix == name {
+		return false
+	}
+	timestamp := strings.TrimSuffix(suffix, ".tar.gz")
+	if timestamp == suffix || timestamp == "" {
+		return false
+	}
+	for _, c := range timestamp {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// backupKey returns the key of the archive uploaded by the job
+func backupKey(backup *objectv1alpha1.ObjectStoreBackup, jobName string) string {
+	return fmt.Sprintf("%s%s.tar.gz", backupPrefix(backup), jobName)
+}
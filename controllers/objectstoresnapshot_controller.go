@@ -0,0 +1,420 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reasons used in the ObjectStoreSnapshot conditions
+const (
+	reasonQuiescing        = "Quiescing"
+	reasonSnapshotCreating = "SnapshotCreating"
+	reasonSnapshotPending  = "SnapshotPending"
+	reasonSnapshotReady    = "SnapshotReady"
+	reasonSnapshotFailed   = "SnapshotFailed"
+)
+
+const (
+	// snapshotPollInterval is how often the snapshot is checked while the gateway is stopped, the
+	// VolumeSnapshot is not watched since its CRD is optional
+	snapshotPollInterval = 2 * time.Second
+
+	defaultQuiesceTimeout = 5 * time.Minute
+)
+
+// quiesceAnnotation is set on the ObjectStore while its gateway is stopped for a snapshot, its value
+// is the name of the ObjectStoreSnapshot
+var quiesceAnnotation = fmt.Sprintf("%s/quiesced-by", objectv1alpha1.GroupVersion.Group)
+
+// volumeSnapshotGVK is the CSI VolumeSnapshot, it is handled as unstructured so that the snapshot
+// CRDs are only needed when used
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1", Kind: "VolumeSnapshot"}
+
+// ObjectStoreSnapshotReconciler reconciles a ObjectStoreSnapshot object
+type ObjectStoreSnapshotReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoresnapshots,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoresnapshots/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoresnapshots/finalizers,verbs=update
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=list;watch
+//+kubebuilder:rbac:groups="snapshot.storage.k8s.io",resources=volumesnapshots,verbs=create;delete;get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectStoreSnapshotReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectStoreSnapshot{}).
+		// The snapshot is taken as soon as the last gateway pod is gone
+		Watches(&source.Kind{Type: &v1.Pod{}}, handler.EnqueueRequestsFromMapFunc(r.snapshotForPod)).
+		Complete(r)
+}
+
+// snapshotForPod maps a gateway pod to the ObjectStoreSnapshot that stopped its gateway
+func (r *ObjectStoreSnapshotReconciler) snapshotForPod(object client.Object) []reconcile.Request {
+	name, ok := object.GetLabels()[objectStoreLabel]
+	if !ok {
+		return nil
+	}
+
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err := r.Client.Get(context.Background(), client.ObjectKey{Namespace: object.GetNamespace(), Name: name}, objectStore)
+	if err != nil {
+		return nil
+	}
+	snapshotName := quiescedBy(objectStore)
+	if snapshotName == "" {
+		return nil
+	}
+
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: object.GetNamespace(), Name: snapshotName}},
+	}
+}
+
+// Reconcile stops the gateway of the ObjectStore, takes a VolumeSnapshot of its PVC and starts the
+// gateway again as soon as the storage has taken the snapshot
+func (r *ObjectStoreSnapshotReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectStoreSnapshot", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	snapshot := &objectv1alpha1.ObjectStoreSnapshot{}
+	err := r.Client.Get(ctx, req.NamespacedName, snapshot)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectStoreSnapshot resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectStoreSnapshot: %w", err)
+	}
+
+	finalizerName := buildFinalizerName("ObjectStoreSnapshot")
+
+	// The gateway must not stay stopped when the snapshot is deleted while it is being taken, the
+	// VolumeSnapshot is owned and deleted by the garbage collector
+	if !snapshot.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.ContainsFinalizer(snapshot, finalizerName) {
+			return reconcile.Result{}, nil
+		}
+
+		err = r.releaseGateway(ctx, snapshot)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		controllerutil.RemoveFinalizer(snapshot, finalizerName)
+		err = r.Client.Update(ctx, snapshot)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(snapshot, finalizerName) {
+		controllerutil.AddFinalizer(snapshot, finalizerName)
+		err = r.Client.Update(ctx, snapshot)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	original := snapshot.DeepCopy()
+	requeueAfter, err := r.reconcileSnapshot(ctx, snapshot)
+	if err != nil {
+		setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	snapshot.Status.Phase = phaseFromConditions(snapshot.Status.Conditions, err)
+	if ready := meta.FindStatusCondition(snapshot.Status.Conditions, objectv1alpha1.ConditionReady); ready != nil && ready.Reason == reasonSnapshotFailed {
+		snapshot.Status.Phase = objectv1alpha1.PhaseFailed
+	}
+	snapshot.Status.ObservedGeneration = snapshot.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, snapshot, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectStoreSnapshot %q: %w", snapshot.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectStoreSnapshot", req.NamespacedName.String(), "Phase", snapshot.Status.Phase)
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcileSnapshot moves the snapshot forward, it returns how long to wait before checking again
+func (r *ObjectStoreSnapshotReconciler) reconcileSnapshot(ctx context.Context, snapshot *objectv1alpha1.ObjectStoreSnapshot) (time.Duration, error) {
+	// A failed snapshot is not retried, a new ObjectStoreSnapshot must be created instead
+	if ready := meta.FindStatusCondition(snapshot.Status.Conditions, objectv1alpha1.ConditionReady); ready != nil && ready.Reason == reasonSnapshotFailed {
+		return 0, r.releaseGateway(ctx, snapshot)
+	}
+
+	volumeSnapshot := volumeSnapshotMeta(snapshot)
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(volumeSnapshot), volumeSnapshot)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonSnapshotPending,
+				"the snapshot.storage.k8s.io/v1 API is not available, the CSI external snapshotter must be installed")
+			return requeueInterval, nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return 0, fmt.Errorf("failed to get VolumeSnapshot %q: %w", volumeSnapshot.GetName(), err)
+		}
+		if snapshot.Status.CreationTime != nil {
+			failSnapshot(snapshot, fmt.Sprintf("VolumeSnapshot %q was deleted", volumeSnapshot.GetName()))
+			return 0, nil
+		}
+		return r.takeSnapshot(ctx, snapshot, volumeSnapshot)
+	}
+
+	return r.updateSnapshotStatus(ctx, snapshot, volumeSnapshot)
+}
+
+// takeSnapshot creates the VolumeSnapshot of the PVC, once the gateway is stopped unless the snapshot
+// is online
+func (r *ObjectStoreSnapshotReconciler) takeSnapshot(ctx context.Context, snapshot *objectv1alpha1.ObjectStoreSnapshot, volumeSnapshot *unstructured.Unstructured) (time.Duration, error) {
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.Spec.ObjectStoreName}, objectStore)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotFound,
+				fmt.Sprintf("ObjectStore %q not found", snapshot.Spec.ObjectStoreName))
+			return requeueInterval, nil
+		}
+		return 0, fmt.Errorf("failed to get ObjectStore %q: %w", snapshot.Spec.ObjectStoreName, err)
+	}
+
+	if !snapshot.Spec.Online {
+		stopped, err := r.quiesceGateway(ctx, snapshot, objectStore)
+		if err != nil {
+			return 0, err
+		}
+		if !stopped {
+			return snapshotPollInterval, nil
+		}
+	}
+
+	pvcName := instanceName(objectStore.Name, objectStore.Namespace)
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{"persistentVolumeClaimName": pvcName},
+	}
+	if snapshot.Spec.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = snapshot.Spec.VolumeSnapshotClassName
+	}
+	err = unstructured.SetNestedField(volumeSnapshot.Object, spec, "spec")
+	if err != nil {
+		return 0, fmt.Errorf("failed to set spec of VolumeSnapshot %q: %w", volumeSnapshot.GetName(), err)
+	}
+	volumeSnapshot.SetLabels(getLabels(objectStore.Name))
+
+	err = controllerutil.SetControllerReference(snapshot, volumeSnapshot, r.Scheme)
+	if err != nil {
+		return 0, fmt.Errorf("failed to set owner reference to VolumeSnapshot %q: %w", volumeSnapshot.GetName(), err)
+	}
+
+	err = r.Client.Create(ctx, volumeSnapshot)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create VolumeSnapshot %q: %w", volumeSnapshot.GetName(), err)
+	}
+	r.Logger.Info("created VolumeSnapshot", "VolumeSnapshot", volumeSnapshot.GetName(), "PVC", pvcName)
+
+	snapshot.Status.VolumeSnapshotName = volumeSnapshot.GetName()
+	setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonSnapshotCreating,
+		fmt.Sprintf("taking VolumeSnapshot %q of PVC %q", volumeSnapshot.GetName(), pvcName))
+	return snapshotPollInterval, nil
+}
+
+// quiesceGateway asks the ObjectStore controller to scale the gateway down, it returns true once
+// the gateway pods are gone so that the SQLite database is consistent on the volume
+func (r *ObjectStoreSnapshotReconciler) quiesceGateway(ctx context.Context, snapshot *objectv1alpha1.ObjectStoreSnapshot, objectStore *objectv1alpha1.ObjectStore) (bool, error) {
+	owner := quiescedBy(objectStore)
+	if owner != "" && owner != snapshot.Name {
+		setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonProgressing,
+			fmt.Sprintf("waiting for ObjectStoreSnapshot %q to start the gateway again", owner))
+		return false, nil
+	}
+
+	if owner == "" {
+		// An upgrade stops the gateway as well and changes the data, wait for it to finish
+		if objectStore.Status.Upgrade != nil {
+			setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonProgressing,
+				fmt.Sprintf("waiting for the upgrade of ObjectStore %q to finish", objectStore.Name))
+			return false, nil
+		}
+
+		original := objectStore.DeepCopy()
+		metav1.SetMetaDataAnnotation(&objectStore.ObjectMeta, quiesceAnnotation, snapshot.Name)
+		err := r.Client.Patch(ctx, objectStore, client.MergeFrom(original))
+		if err != nil {
+			return false, fmt.Errorf("failed to stop the gateway of ObjectStore %q: %w", objectStore.Name, err)
+		}
+		r.Logger.Info("stopping the gateway for the snapshot", "ObjectStore", objectStore.Name)
+	}
+	if snapshot.Status.QuiesceStartTime == nil {
+		now := metav1.Now()
+		snapshot.Status.QuiesceStartTime = &now
+	}
+
+	if quiesceTimedOut(snapshot) {
+		failSnapshot(snapshot, fmt.Sprintf("the gateway did not stop within %s", quiesceTimeout(snapshot)))
+		return false, r.releaseGateway(ctx, snapshot)
+	}
+
+	pods := &v1.PodList{}
+	err := r.Client.List(ctx, pods, client.InNamespace(objectStore.Namespace), client.MatchingLabels(getLabels(objectStore.Name)))
+	if err != nil {
+		return false, fmt.Errorf("failed to list gateway pods: %w", err)
+	}
+	if len(pods.Items) > 0 {
+		setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonQuiescing,
+			fmt.Sprintf("waiting for %d gateway pod(s) of ObjectStore %q to stop", len(pods.Items), objectStore.Name))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// updateSnapshotStatus reports the state of the VolumeSnapshot, the gateway is started again as soon
+// as the storage has taken the snapshot, it does not need to wait for it to be ready to use
+func (r *ObjectStoreSnapshotReconciler) updateSnapshotStatus(ctx context.Context, snapshot *objectv1alpha1.ObjectStoreSnapshot, volumeSnapshot *unstructured.Unstructured) (time.Duration, error) {
+	snapshot.Status.VolumeSnapshotName = volumeSnapshot.GetName()
+
+	if message, _, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "error", "message"); message != "" {
+		failSnapshot(snapshot, fmt.Sprintf("VolumeSnapshot %q failed: %s", volumeSnapshot.GetName(), message))
+		return 0, r.releaseGateway(ctx, snapshot)
+	}
+
+	creationTime, _, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "creationTime")
+	if creationTime == "" {
+		if !snapshot.Spec.Online && quiesceTimedOut(snapshot) {
+			// The snapshot would be taken while the gateway runs again, it may not be consistent
+			err := r.Client.Delete(ctx, volumeSnapshot)
+			if err != nil && !kerrors.IsNotFound(err) {
+				return 0, fmt.Errorf("failed to delete VolumeSnapshot %q: %w", volumeSnapshot.GetName(), err)
+			}
+			failSnapshot(snapshot, fmt.Sprintf("VolumeSnapshot %q was not taken within %s", volumeSnapshot.GetName(), quiesceTimeout(snapshot)))
+			return 0, r.releaseGateway(ctx, snapshot)
+		}
+		setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonSnapshotCreating,
+			fmt.Sprintf("waiting for the storage to take VolumeSnapshot %q", volumeSnapshot.GetName()))
+		return snapshotPollInterval, nil
+	}
+
+	err := r.releaseGateway(ctx, snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	if taken, err := time.Parse(time.RFC3339, creationTime); err == nil {
+		snapshot.Status.CreationTime = &metav1.Time{Time: taken}
+	}
+	if restoreSize, _, _ := unstructured.NestedString(volumeSnapshot.Object, "status", "restoreSize"); restoreSize != "" {
+		if quantity, err := resource.ParseQuantity(restoreSize); err == nil {
+			snapshot.Status.RestoreSize = &quantity
+		}
+	}
+	readyToUse, _, _ := unstructured.NestedBool(volumeSnapshot.Object, "status", "readyToUse")
+	snapshot.Status.ReadyToUse = readyToUse
+	if !readyToUse {
+		setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonSnapshotPending,
+			fmt.Sprintf("VolumeSnapshot %q was taken, waiting for it to be ready to use", volumeSnapshot.GetName()))
+		return requeueInterval, nil
+	}
+
+	setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonSnapshotReady,
+		fmt.Sprintf("VolumeSnapshot %q is ready to use", volumeSnapshot.GetName()))
+	return 0, nil
+}
+
+// releaseGateway lets the ObjectStore controller start the gateway again if the snapshot stopped it
+func (r *ObjectStoreSnapshotReconciler) releaseGateway(ctx context.Context, snapshot *objectv1alpha1.ObjectStoreSnapshot) error {
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.Spec.ObjectStoreName}, objectStore)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get ObjectStore %q: %w", snapshot.Spec.ObjectStoreName, err)
+	}
+	if quiescedBy(objectStore) != snapshot.Name {
+		return nil
+	}
+
+	original := objectStore.DeepCopy()
+	delete(objectStore.Annotations, quiesceAnnotation)
+	err = r.Client.Patch(ctx, objectStore, client.MergeFrom(original))
+	if err != nil {
+		return fmt.Errorf("failed to start the gateway of ObjectStore %q again: %w", objectStore.Name, err)
+	}
+	r.Logger.Info("starting the gateway again after the snapshot", "ObjectStore", objectStore.Name)
+
+	return nil
+}
+
+// quiescedBy returns the name of the ObjectStoreSnapshot that stopped the gateway, if any
+func quiescedBy(objectStore *objectv1alpha1.ObjectStore) string {
+	return objectStore.Annotations[quiesceAnnotation]
+}
+
+func failSnapshot(snapshot *objectv1alpha1.ObjectStoreSnapshot, message string) {
+	setStatusCondition(&snapshot.Status.Conditions, snapshot.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonSnapshotFailed, message)
+}
+
+func quiesceTimedOut(snapshot *objectv1alpha1.ObjectStoreSnapshot) bool {
+	return snapshot.Status.QuiesceStartTime != nil && time.Since(snapshot.Status.QuiesceStartTime.Time) > quiesceTimeout(snapshot)
+}
+
+func quiesceTimeout(snapshot *objectv1alpha1.ObjectStoreSnapshot) time.Duration {
+	if snapshot.Spec.QuiesceTimeoutSeconds > 0 {
+		return time.Duration(snapshot.Spec.QuiesceTimeoutSeconds) * time.Second
+	}
+	return defaultQuiesceTimeout
+}
+
+// volumeSnapshotMeta returns the VolumeSnapshot of the ObjectStoreSnapshot, it has the same name so
+// that it is easy to find
+func volumeSnapshotMeta(snapshot *objectv1alpha1.ObjectStoreSnapshot) *unstructured.Unstructured {
+	volumeSnapshot := &unstructured.Unstructured{}
+	volumeSnapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	volumeSnapshot.SetName(snapshot.Name)
+	volumeSnapshot.SetNamespace(snapshot.Namespace)
+	return volumeSnapshot
+}
@@ -0,0 +1,514 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"github.com/redhat-et/rgw-standalone-operator/pkg/admin"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Reasons used in the ObjectStoreUser conditions
+const (
+	reasonObjectStoreNotFound = "ObjectStoreNotFound"
+	reasonObjectStoreNotReady = "ObjectStoreNotReady"
+	reasonUserReconciled      = "UserReconciled"
+	reasonUserReserved        = "UserReserved"
+	reasonUserExists          = "UserExists"
+)
+
+var (
+	// adoptUserAnnotation lets an ObjectStoreUser manage a user that already exists in the
+	// ObjectStore, e.g. one created with radosgw-admin, such a user is left alone otherwise
+	adoptUserAnnotation = fmt.Sprintf("%s/adopt-user", objectv1alpha1.GroupVersion.Group)
+
+	// createdUserAnnotation records that the operator created the user of the ObjectStoreUser, it is
+	// set before the user is created so that the user is never mistaken for a pre-existing one
+	createdUserAnnotation = fmt.Sprintf("%s/user-created", objectv1alpha1.GroupVersion.Group)
+)
+
+const (
+	// objectStoreNameIndex indexes the resources by the ObjectStore they belong to
+	objectStoreNameIndex = ".spec.objectStoreName"
+
+	// userResyncInterval is how often the usage of the users is refreshed
+	userResyncInterval = 5 * time.Minute
+)
+
+// ObjectStoreUserReconciler reconciles a ObjectStoreUser object
+type ObjectStoreUserReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoreusers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoreusers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstoreusers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectstores,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectStoreUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &objectv1alpha1.ObjectStoreUser{}, objectStoreNameIndex, func(object client.Object) []string {
+		return []string{object.(*objectv1alpha1.ObjectStoreUser).Spec.ObjectStoreName}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to index ObjectStoreUsers by ObjectStore: %w", err)
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectStoreUser{}).
+		Owns(&v1.Secret{}).
+		// Users are created as soon as their ObjectStore is ready
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectStore{}}, handler.EnqueueRequestsFromMapFunc(r.usersForObjectStore)).
+		Complete(r)
+}
+
+// usersForObjectStore maps an ObjectStore to the users it holds
+func (r *ObjectStoreUserReconciler) usersForObjectStore(object client.Object) []reconcile.Request {
+	users := &objectv1alpha1.ObjectStoreUserList{}
+	err := r.Client.List(context.Background(), users,
+		client.InNamespace(object.GetNamespace()),
+		client.MatchingFields{objectStoreNameIndex: object.GetName()},
+	)
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectStoreUsers of ObjectStore", "ObjectStore", object.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(users.Items))
+	for _, user := range users.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&user)})
+	}
+
+	return requests
+}
+
+// Reconcile creates the user in the ObjectStore and publishes its credentials in a Secret
+func (r *ObjectStoreUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectStoreUser", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	user := &objectv1alpha1.ObjectStoreUser{}
+	err := r.Client.Get(ctx, req.NamespacedName, user)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectStoreUser resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectStoreUser: %w", err)
+	}
+
+	objectStore := &objectv1alpha1.ObjectStore{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: user.Namespace, Name: user.Spec.ObjectStoreName}, objectStore)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return reconcile.Result{}, fmt.Errorf("failed to get ObjectStore %q: %w", user.Spec.ObjectStoreName, err)
+		}
+		objectStore = nil
+	}
+
+	finalizerName := buildFinalizerName("ObjectStoreUser")
+
+	// DELETE: the CR was deleted
+	if !user.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.ContainsFinalizer(user, finalizerName) {
+			return reconcile.Result{}, nil
+		}
+
+		// There is nothing left to clean up once the ObjectStore is gone, and a user the
+		// ObjectStoreUser does not manage is kept
+		if objectStore != nil && objectStore.GetDeletionTimestamp().IsZero() && managesUser(user) {
+			err = r.deleteUser(ctx, objectStore, user)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(user, finalizerName)
+		err = r.Client.Update(ctx, user)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to remove finalizer: %w", err)
+		}
+
+		r.Logger.Info("successfully deleted ObjectStoreUser " + req.NamespacedName.String())
+		return reconcile.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(user, finalizerName) {
+		controllerutil.AddFinalizer(user, finalizerName)
+		err = r.Client.Update(ctx, user)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("failed to add finalizer: %w", err)
+		}
+	}
+
+	original := user.DeepCopy()
+	err = r.reconcileUser(ctx, objectStore, user)
+	if err != nil {
+		setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	user.Status.Phase = phaseFromConditions(user.Status.Conditions, err)
+	user.Status.ObservedGeneration = user.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, user, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectStoreUser %q: %w", user.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectStoreUser", req.NamespacedName.String(), "Phase", user.Status.Phase)
+	return reconcile.Result{RequeueAfter: userResyncInterval}, nil
+}
+
+// reconcileUser converges the user in the ObjectStore against the spec, the ObjectStore watch
+// triggers a new reconcile once the ObjectStore becomes ready
+func (r *ObjectStoreUserReconciler) reconcileUser(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, user *objectv1alpha1.ObjectStoreUser) error {
+	if objectv1alpha1.IsReservedUserID(user.Name) {
+		setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonUserReserved, fmt.Sprintf("user %q is reserved for the operator", user.Name))
+		return nil
+	}
+	if objectStore == nil {
+		setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotFound, fmt.Sprintf("ObjectStore %q not found", user.Spec.ObjectStoreName))
+		return nil
+	}
+	if !meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionReady) {
+		setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonObjectStoreNotReady, fmt.Sprintf("waiting for ObjectStore %q to be ready", objectStore.Name))
+		return nil
+	}
+
+	adminOps, err := adminClient(ctx, r.Client, objectStore)
+	if err != nil {
+		return err
+	}
+
+	info, err := r.getOrCreateUser(ctx, adminOps, objectStore, user)
+	if err != nil || info == nil {
+		return err
+	}
+
+	err = r.updateUser(ctx, adminOps, user, info)
+	if err != nil {
+		return err
+	}
+
+	err = r.reconcileCapabilities(ctx, adminOps, user, info)
+	if err != nil {
+		return err
+	}
+
+	err = r.reconcileQuota(ctx, adminOps, user, info)
+	if err != nil {
+		return err
+	}
+
+	user.Status.Usage, err = userUsage(ctx, adminOps, info)
+	if err != nil {
+		return err
+	}
+
+	if len(info.Keys) == 0 {
+		return fmt.Errorf("user %q has no S3 key", info.UserID)
+	}
+	secretName, err := r.reconcileCredentialsSecret(ctx, objectStore, user, info.Keys[0])
+	if err != nil {
+		return err
+	}
+	user.Status.SecretName = secretName
+
+	setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonUserReconciled, fmt.Sprintf("credentials are available in secret %q", secretName))
+	return nil
+}
+
+// getOrCreateUser returns the user of the ObjectStoreUser and creates it when it does not exist. It
+// returns nil when the user exists but was neither created by the operator nor adopted, so that an
+// ObjectStoreUser cannot take over the keys of a user it does not own.
+func (r *ObjectStoreUserReconciler) getOrCreateUser(ctx context.Context, adminOps *admin.Client, objectStore *objectv1alpha1.ObjectStore, user *objectv1alpha1.ObjectStoreUser) (*admin.User, error) {
+	info, err := adminOps.GetUser(ctx, user.Name)
+	if err == nil {
+		if !managesUser(user) {
+			setStatusCondition(&user.Status.Conditions, user.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonUserExists,
+				fmt.Sprintf("user %q already exists in ObjectStore %q, set the %s=true annotation to manage it", user.Name, objectStore.Name, adoptUserAnnotation))
+			return nil, nil
+		}
+		return info, nil
+	}
+	if !admin.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get user %q: %w", user.Name, err)
+	}
+
+	if user.Annotations[createdUserAnnotation] != "true" {
+		metav1.SetMetaDataAnnotation(&user.ObjectMeta, createdUserAnnotation, "true")
+		err = r.Client.Update(ctx, user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record the creation of user %q: %w", user.Name, err)
+		}
+	}
+
+	info, err = adminOps.CreateUser(ctx, user.Name, displayName(user), maxBuckets(user))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user %q: %w", user.Name, err)
+	}
+	r.Logger.Info("successfully created user", "uid", info.UserID)
+
+	return info, nil
+}
+
+// managesUser returns whether the ObjectStoreUser manages its user in the ObjectStore, because the
+// operator created it, it was adopted, or it was published before the ownership was recorded
+func managesUser(user *objectv1alpha1.ObjectStoreUser) bool {
+	if objectv1alpha1.IsReservedUserID(user.Name) {
+		return false
+	}
+	return user.Annotations[createdUserAnnotation] == "true" || user.Annotations[adoptUserAnnotation] == "true" || user.Status.SecretName != ""
+}
+
+// updateUser updates the display name and the maximum number of buckets if they changed
+func (r *ObjectStoreUserReconciler) updateUser(ctx context.Context, adminOps *admin.Client, user *objectv1alpha1.ObjectStoreUser, info *admin.User) error {
+	name, buckets := "", -1
+	if info.DisplayName != displayName(user) {
+		name = displayName(user)
+	}
+	if user.Spec.MaxBuckets != nil && info.MaxBuckets != *user.Spec.MaxBuckets {
+		buckets = *user.Spec.MaxBuckets
+	}
+	if name == "" && buckets < 0 {
+		return nil
+	}
+
+	err := adminOps.ModifyUser(ctx, info.UserID, name, buckets)
+	if err != nil {
+		return fmt.Errorf("failed to modify user %q: %w", info.UserID, err)
+	}
+
+	return nil
+}
+
+// reconcileCapabilities replaces the capabilities of the user when they differ from the spec
+func (r *ObjectStoreUserReconciler) reconcileCapabilities(ctx context.Context, adminOps *admin.Client, user *objectv1alpha1.ObjectStoreUser, info *admin.User) error {
+	current := map[string]string{}
+	for _, capability := range info.Caps {
+		current[capability.Type] = normalizeCapability(capability.Perm)
+	}
+	desired := desiredCapabilities(user.Spec.Capabilities)
+	if capabilitiesString(current) == capabilitiesString(desired) {
+		return nil
+	}
+
+	if len(current) > 0 {
+		err := adminOps.RemoveCapabilities(ctx, info.UserID, capabilitiesString(current))
+		if err != nil {
+			return fmt.Errorf("failed to remove capabilities of user %q: %w", info.UserID, err)
+		}
+	}
+	if len(desired) > 0 {
+		err := adminOps.AddCapabilities(ctx, info.UserID, capabilitiesString(desired))
+		if err != nil {
+			return fmt.Errorf("failed to add capabilities to user %q: %w", info.UserID, err)
+		}
+	}
+	r.Logger.Info("successfully updated user capabilities", "uid", info.UserID, "caps", capabilitiesString(desired))
+
+	return nil
+}
+
+// reconcileQuota sets and enables the user quota, or disables it when the spec has none
+func (r *ObjectStoreUserReconciler) reconcileQuota(ctx context.Context, adminOps *admin.Client, user *objectv1alpha1.ObjectStoreUser, info *admin.User) error {
+	desired := admin.Quota{MaxSize: -1, MaxObjects: -1}
+	if user.Spec.Quotas != nil {
+		if user.Spec.Quotas.MaxSize != nil {
+			desired.MaxSize = user.Spec.Quotas.MaxSize.Value()
+		}
+		if user.Spec.Quotas.MaxObjects != nil {
+			desired.MaxObjects = *user.Spec.Quotas.MaxObjects
+		}
+	}
+	desired.Enabled = desired.MaxSize >= 0 || desired.MaxObjects >= 0
+
+	current, err := adminOps.GetUserQuota(ctx, info.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to get quota of user %q: %w", info.UserID, err)
+	}
+	if !desired.Enabled && !current.Enabled {
+		return nil
+	}
+	if *current == desired {
+		return nil
+	}
+
+	err = adminOps.SetUserQuota(ctx, info.UserID, desired)
+	if err != nil {
+		return fmt.Errorf("failed to set quota of user %q: %w", info.UserID, err)
+	}
+	r.Logger.Info("successfully updated user quota", "uid", info.UserID, "enabled", desired.Enabled, "maxSize", desired.MaxSize, "maxObjects", desired.MaxObjects)
+
+	return nil
+}
+
+// userUsage returns the space used by the user and its number of buckets
+func userUsage(ctx context.Context, adminOps *admin.Client, info *admin.User) (*objectv1alpha1.ObjectUserUsage, error) {
+	buckets, err := adminOps.ListBuckets(ctx, info.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list buckets of user %q: %w", info.UserID, err)
+	}
+
+	usage := &objectv1alpha1.ObjectUserUsage{Buckets: int32(len(buckets))}
+	if info.Stats != nil {
+		usage.Size = info.Stats.Size
+		usage.Objects = info.Stats.NumObjects
+	}
+
+	return usage, nil
+}
+
+// reconcileCredentialsSecret writes the S3 credentials of the user in a Secret owned by the user
+func (r *ObjectStoreUserReconciler) reconcileCredentialsSecret(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, user *objectv1alpha1.ObjectStoreUser, key admin.UserKey) (string, error) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      userSecretName(user),
+			Namespace: user.Namespace,
+			Labels:    getLabels(objectStore.Name),
+		},
+	}
+
+	err := controllerutil.SetControllerReference(user, secret, r.Scheme)
+	if err != nil {
+		return "", fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+	}
+
+	mutateFunc := func() error {
+		secret.Type = v1.SecretTypeOpaque
+		secret.Data = map[string][]byte{
+			"AWS_ACCESS_KEY_ID":     []byte(key.AccessKey),
+			"AWS_SECRET_ACCESS_KEY": []byte(key.SecretKey),
+			"AWS_ENDPOINT_URL":      []byte(objectStore.Status.Endpoint),
+		}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, mutateFunc)
+	if err != nil {
+		return "", fmt.Errorf("failed to create or update user secret %q: %w", secret.Name, err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("user credentials secret", "Secret", secret.Name, "opResult", opResult)
+	}
+
+	return secret.Name, nil
+}
+
+func (r *ObjectStoreUserReconciler) deleteUser(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, user *objectv1alpha1.ObjectStoreUser) error {
+	adminOps, err := adminClient(ctx, r.Client, objectStore)
+	if err != nil {
+		return err
+	}
+
+	err = adminOps.RemoveUser(ctx, user.Name)
+	if err != nil && !admin.IsNotFound(err) {
+		return fmt.Errorf("failed to delete user %q: %w", user.Name, err)
+	}
+	r.Logger.Info("successfully deleted user", "uid", user.Name)
+
+	return nil
+}
+
+// userSecretName returns the name of the Secret holding the credentials of the user
+func userSecretName(user *objectv1alpha1.ObjectStoreUser) string {
+	return fmt.Sprintf("%s-user-%s", appName, user.Name)
+}
+
+// maxBuckets returns the maximum number of buckets of the spec, -1 leaves the default of the gateway
+func maxBuckets(user *objectv1alpha1.ObjectStoreUser) int {
+	if user.Spec.MaxBuckets != nil {
+		return *user.Spec.MaxBuckets
+	}
+	return -1
+}
+
+func displayName(user *objectv1alpha1.ObjectStoreUser) string {
+	if user.Spec.DisplayName != "" {
+		return user.Spec.DisplayName
+	}
+	return user.Name
+}
+
+// desiredCapabilities returns the capabilities of the spec indexed by type
+func desiredCapabilities(capabilities *objectv1alpha1.ObjectUserCapabilities) map[string]string {
+	desired := map[string]string{}
+	if capabilities == nil {
+		return desired
+	}
+
+	for capabilityType, perm := range map[string]string{
+		"users":    capabilities.Users,
+		"buckets":  capabilities.Buckets,
+		"metadata": capabilities.Metadata,
+		"usage":    capabilities.Usage,
+		"zone":     capabilities.Zone,
+	} {
+		if perm != "" {
+			desired[capabilityType] = normalizeCapability(perm)
+		}
+	}
+
+	return desired
+}
+
+// normalizeCapability converts a permission to the form reported by the gateway
+func normalizeCapability(perm string) string {
+	if strings.ReplaceAll(perm, " ", "") == "read,write" {
+		return "*"
+	}
+	return perm
+}
+
+// capabilitiesString returns the capabilities in the format of the gateway, e.g. "buckets=*;users=read"
+func capabilitiesString(capabilities map[string]string) string {
+	types := make([]string, 0, len(capabilities))
+	for capabilityType := range capabilities {
+		types = append(types, capabilityType)
+	}
+	sort.Strings(types)
+
+	caps := make([]string, 0, len(types))
+	for _, capabilityType := range types {
+		caps = append(caps, capabilityType+"="+capabilities[capabilityType])
+	}
+
+	return strings.Join(caps, ";")
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"github.com/redhat-et/rgw-standalone-operator/pkg/admin"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// adminRequest is a request received by the fake admin ops API, with its query minus the format
+type adminRequest struct {
+	method string
+	path   string
+	query  string
+}
+
+// newFakeAdminOps returns a client of a fake admin ops API answering the requests of a path with the
+// body of responses, or not found for a GET without response, and the requests it received
+func newFakeAdminOps(t *testing.T, responses map[string]string) (*admin.Client, *[]adminRequest) {
+	t.Helper()
+	requests := []adminRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		query.Del("format")
+		requests = append(requests, adminRequest{method: r.Method, path: r.URL.Path, query: query.Encode()})
+		response, ok := responses[r.URL.Path]
+		if !ok && r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"Code":"NoSuchUser"}`))
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return admin.NewClient(server.URL, "access", "secret", nil), &requests
+}
+
+func TestReconcileCapabilities(t *testing.T) {
+	tests := []struct {
+		name         string
+		current      []admin.Capability
+		capabilities *objectv1alpha1.ObjectUserCapabilities
+		want         []adminRequest
+	}{
+		{
+			name:         "unchanged",
+			current:      []admin.Capability{{Type: "buckets", Perm: "*"}, {Type: "users", Perm: "read"}},
+			capabilities: &objectv1alpha1.ObjectUserCapabilities{Buckets: "read, write", Users: "read"},
+			want:         []adminRequest{},
+		},
+		{
+			name:         "replaced",
+			current:      []admin.Capability{{Type: "buckets", Perm: "*"}},
+			capabilities: &objectv1alpha1.ObjectUserCapabilities{Buckets: "read", Usage: "read"},
+			want: []adminRequest{
+				{method: http.MethodDelete, path: "/admin/user", query: "caps=&uid=u&user-caps=buckets%3D%2A"},
+				{method: http.MethodPut, path: "/admin/user", query: "caps=&uid=u&user-caps=buckets%3Dread%3Busage%3Dread"},
+			},
+		},
+		{
+			name:    "granted",
+			current: nil,
+			capabilities: &objectv1alpha1.ObjectUserCapabilities{
+				Users: "read,write",
+			},
+			want: []adminRequest{
+				{method: http.MethodPut, path: "/admin/user", query: "caps=&uid=u&user-caps=users%3D%2A"},
+			},
+		},
+		{
+			name:    "revoked",
+			current: []admin.Capability{{Type: "metadata", Perm: "read"}},
+			want: []adminRequest{
+				{method: http.MethodDelete, path: "/admin/user", query: "caps=&uid=u&user-caps=metadata%3Dread"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adminOps, requests := newFakeAdminOps(t, nil)
+			r := &ObjectStoreUserReconciler{Logger: logr.Discard()}
+			user := &objectv1alpha1.ObjectStoreUser{Spec: objectv1alpha1.ObjectStoreUserSpec{Capabilities: test.capabilities}}
+
+			err := r.reconcileCapabilities(context.Background(), adminOps, user, &admin.User{UserID: "u", Caps: test.current})
+			if err != nil {
+				t.Fatalf("reconcileCapabilities() = %v", err)
+			}
+			if !reflect.DeepEqual(*requests, test.want) {
+				t.Errorf("requests %+v, want %+v", *requests, test.want)
+			}
+		})
+	}
+}
+
+func TestReconcileQuota(t *testing.T) {
+	maxSize := resource.MustParse("1Gi")
+	maxObjects := int64(100)
+	getQuota := adminRequest{method: http.MethodGet, path: "/admin/user", query: "quota=&quota-type=user&uid=u"}
+
+	tests := []struct {
+		name    string
+		quotas  *objectv1alpha1.ObjectUserQuotaSpec
+		current string
+		want    []adminRequest
+	}{
+		{
+			name:    "no quota",
+			current: `{"enabled":false,"max_size":-1,"max_objects":-1}`,
+			want:    []adminRequest{getQuota},
+		},
+		{
+			name:    "quota set",
+			quotas:  &objectv1alpha1.ObjectUserQuotaSpec{MaxSize: &maxSize},
+			current: `{"enabled":false,"max_size":-1,"max_objects":-1}`,
+			want: []adminRequest{
+				getQuota,
+				{method: http.MethodPut, path: "/admin/user", query: "enabled=true&max-objects=-1&max-size=1073741824&quota=&quota-type=user&uid=u"},
+			},
+		},
+		{
+			name:    "quota unchanged",
+			quotas:  &objectv1alpha1.ObjectUserQuotaSpec{MaxObjects: &maxObjects},
+			current: `{"enabled":true,"max_size":-1,"max_objects":100}`,
+			want:    []adminRequest{getQuota},
+		},
+		{
+			name:    "quota removed",
+			current: `{"enabled":true,"max_size":1024,"max_objects":-1}`,
+			want: []adminRequest{
+				getQuota,
+				{method: http.MethodPut, path: "/admin/user", query: "enabled=false&max-objects=-1&max-size=-1&quota=&quota-type=user&uid=u"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adminOps, requests := newFakeAdminOps(t, map[string]string{"/admin/user": test.current})
+			r := &ObjectStoreUserReconciler{Logger: logr.Discard()}
+			user := &objectv1alpha1.ObjectStoreUser{Spec: objectv1alpha1.ObjectStoreUserSpec{Quotas: test.quotas}}
+
+			err := r.reconcileQuota(context.Background(), adminOps, user, &admin.User{UserID: "u"})
+			if err != nil {
+				t.Fatalf("reconcileQuota() = %v", err)
+			}
+			if !reflect.DeepEqual(*requests, test.want) {
+				t.Errorf("requests %+v, want %+v", *requests, test.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ten := 10
+
+	tests := []struct {
+		name string
+		spec objectv1alpha1.ObjectStoreUserSpec
+		info admin.User
+		want []adminRequest
+	}{
+		{
+			name: "unchanged",
+			spec: objectv1alpha1.ObjectStoreUserSpec{MaxBuckets: &ten},
+			info: admin.User{UserID: "u", DisplayName: "u", MaxBuckets: 10},
+			want: []adminRequest{},
+		},
+		{
+			name: "max buckets left to the gateway",
+			info: admin.User{UserID: "u", DisplayName: "u", MaxBuckets: 1000},
+			want: []adminRequest{},
+		},
+		{
+			name: "display name and max buckets changed",
+			spec: objectv1alpha1.ObjectStoreUserSpec{DisplayName: "User", MaxBuckets: &ten},
+			info: admin.User{UserID: "u", DisplayName: "u", MaxBuckets: 1000},
+			want: []adminRequest{{method: http.MethodPost, path: "/admin/user", query: "display-name=User&max-buckets=10&uid=u"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			adminOps, requests := newFakeAdminOps(t, nil)
+			r := &ObjectStoreUserReconciler{Logger: logr.Discard()}
+			user := &objectv1alpha1.ObjectStoreUser{Spec: test.spec}
+			user.Name = "u"
+
+			err := r.updateUser(context.Background(), adminOps, user, &test.info)
+			if err != nil {
+				t.Fatalf("updateUser() = %v", err)
+			}
+			if !reflect.DeepEqual(*requests, test.want) {
+				t.Errorf("requests %+v, want %+v", *requests, test.want)
+			}
+		})
+	}
+}
+
+func TestGetOrCreateUser(t *testing.T) {
+	existing := `{"user_id":"u","display_name":"u","keys":[{"user":"u","access_key":"a","secret_key":"s"}]}`
+	getUser := adminRequest{method: http.MethodGet, path: "/admin/user", query: "stats=true&uid=u"}
+
+	tests := []struct {
+		name        string
+		existing    bool
+		annotations map[string]string
+		secretName  string
+		wantUser    bool
+		wantCreated bool
+		wantReason  string
+	}{
+		{
+			name:        "created",
+			wantUser:    true,
+			wantCreated: true,
+		},
+		{
+			name:       "existing user not managed",
+			existing:   true,
+			wantReason: reasonUserExists,
+		},
+		{
+			name:        "existing user adopted",
+			existing:    true,
+			annotations: map[string]string{adoptUserAnnotation: "true"},
+			wantUser:    true,
+		},
+		{
+			name:        "existing user created by the operator",
+			existing:    true,
+			annotations: map[string]string{createdUserAnnotation: "true"},
+			wantUser:    true,
+		},
+		{
+			name:       "existing user published before the ownership was recorded",
+			existing:   true,
+			secretName: "rgw-user-u",
+			wantUser:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := map[string]string{}
+			if test.existing {
+				responses["/admin/user"] = existing
+			}
+			adminOps, requests := newFakeAdminOps(t, responses)
+			user := &objectv1alpha1.ObjectStoreUser{ObjectMeta: metav1.ObjectMeta{Name: "u", Namespace: "rgw", Annotations: test.annotations}}
+			user.Status.SecretName = test.secretName
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(user).Build()
+			r := &ObjectStoreUserReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+			info, err := r.getOrCreateUser(context.Background(), adminOps, &objectv1alpha1.ObjectStore{ObjectMeta: metav1.ObjectMeta{Name: "store"}}, user)
+			if err != nil {
+				t.Fatalf("getOrCreateUser() = %v", err)
+			}
+			if (info != nil) != test.wantUser {
+				t.Errorf("getOrCreateUser() = %+v, want user %v", info, test.wantUser)
+			}
+			if test.wantReason != "" {
+				condition := meta.FindStatusCondition(user.Status.Conditions, objectv1alpha1.ConditionReady)
+				if condition == nil || condition.Reason != test.wantReason {
+					t.Errorf("Ready condition %+v, want reason %s", condition, test.wantReason)
+				}
+			}
+
+			created := len(*requests) > 1 && (*requests)[1].method == http.MethodPut
+			if created != test.wantCreated || (*requests)[0] != getUser {
+				t.Errorf("requests %+v, want user created %v", *requests, test.wantCreated)
+			}
+			if test.wantCreated {
+				stored := &objectv1alpha1.ObjectStoreUser{}
+				if err := c.Get(context.Background(), client.ObjectKeyFromObject(user), stored); err != nil {
+					t.Fatalf("failed to get ObjectStoreUser: %v", err)
+				}
+				if stored.Annotations[createdUserAnnotation] != "true" {
+					t.Errorf("creation of the user was not recorded: %v", stored.Annotations)
+				}
+			}
+		})
+	}
+}
+
+func TestManagesUser(t *testing.T) {
+	reserved := &objectv1alpha1.ObjectStoreUser{ObjectMeta: metav1.ObjectMeta{
+		Name:        objectv1alpha1.OperatorUserID,
+		Annotations: map[string]string{adoptUserAnnotation: "true", createdUserAnnotation: "true"},
+	}}
+	reserved.Status.SecretName = userSecretName(reserved)
+	if managesUser(reserved) {
+		t.Errorf("managesUser() of the operator user = true")
+	}
+}
+
+func TestUserUsage(t *testing.T) {
+	adminOps, _ := newFakeAdminOps(t, map[string]string{"/admin/bucket": `["b1","b2"]`})
+
+	usage, err := userUsage(context.Background(), adminOps, &admin.User{UserID: "u", Stats: &admin.UserStats{Size: 10, NumObjects: 3}})
+	if err != nil {
+		t.Fatalf("userUsage() = %v", err)
+	}
+	want := &objectv1alpha1.ObjectUserUsage{Buckets: 2, Size: 10, Objects: 3}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("userUsage() = %+v, want %+v", usage, want)
+	}
+}
+
+func TestRadosgwAdminError(t *testing.T) {
+	if got := subcommand([]string{"user"}); got != "user" {
+		t.Errorf("subcommand() = %q, want %q", got, "user")
+	}
+	if got := subcommand([]string{"user", "info", "--uid=u"}); got != "user info" {
+		t.Errorf("subcommand() = %q, want %q", got, "user info")
+	}
+
+	stderr := "2022-06-01 debug line\ncould not fetch user info: no user info saved\n"
+	if got := lastLine(stderr); got != "could not fetch user info: no user info saved" {
+		t.Errorf("lastLine() = %q", got)
+	}
+	if got := lastLine(""); got != "" {
+		t.Errorf("lastLine() of no output = %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ObjectZoneReconciler reconciles a ObjectZone object
+type ObjectZoneReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzones,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzones/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzonegroups;objectstores,verbs=get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectZoneReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectZone{}).
+		// The zone is configured by the ObjectStore serving it
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectStore{}}, handler.EnqueueRequestsFromMapFunc(zoneForObjectStore)).
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectZoneGroup{}}, handler.EnqueueRequestsFromMapFunc(r.zonesForZoneGroup)).
+		Complete(r)
+}
+
+// zoneForObjectStore maps an ObjectStore to the ObjectZone it serves
+func zoneForObjectStore(object client.Object) []reconcile.Request {
+	objectStore := object.(*objectv1alpha1.ObjectStore)
+	if !objectStore.Spec.HasZoneRef() {
+		return nil
+	}
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: objectStore.Namespace, Name: objectStore.Spec.Multisite.ZoneRef}},
+	}
+}
+
+// zonesForZoneGroup maps an ObjectZoneGroup to its ObjectZones
+func (r *ObjectZoneReconciler) zonesForZoneGroup(object client.Object) []reconcile.Request {
+	zones := &objectv1alpha1.ObjectZoneList{}
+	err := r.Client.List(context.Background(), zones, client.InNamespace(object.GetNamespace()))
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectZones", "ObjectZoneGroup", object.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, zone := range zones.Items {
+		if zone.Spec.ZoneGroupRef == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&zone)})
+		}
+	}
+	return requests
+}
+
+// Reconcile reports the ObjectStore serving the zone and whether it has configured the zone
+func (r *ObjectZoneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectZone", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	zone := &objectv1alpha1.ObjectZone{}
+	err := r.Client.Get(ctx, req.NamespacedName, zone)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectZone resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectZone: %w", err)
+	}
+
+	original := zone.DeepCopy()
+	err = r.reconcileZone(ctx, zone)
+	if err != nil {
+		setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	zone.Status.Phase = phaseFromConditions(zone.Status.Conditions, err)
+	zone.Status.ObservedGeneration = zone.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, zone, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectZone %q: %w", zone.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectZone", req.NamespacedName.String(), "Phase", zone.Status.Phase)
+	return reconcile.Result{}, nil
+}
+
+func (r *ObjectZoneReconciler) reconcileZone(ctx context.Context, zone *objectv1alpha1.ObjectZone) error {
+	zoneGroup := &objectv1alpha1.ObjectZoneGroup{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: zone.Namespace, Name: zone.Spec.ZoneGroupRef}, zoneGroup)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ObjectZoneGroup %q: %w", zone.Spec.ZoneGroupRef, err)
+		}
+		zone.Status.Realm = ""
+		setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonTopologyInvalid,
+			fmt.Sprintf("ObjectZoneGroup %q not found", zone.Spec.ZoneGroupRef))
+		return nil
+	}
+	zone.Status.Realm = zoneGroup.Spec.RealmRef
+
+	objectStores := &objectv1alpha1.ObjectStoreList{}
+	err = r.Client.List(ctx, objectStores, client.InNamespace(zone.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to list ObjectStores: %w", err)
+	}
+	serving := []objectv1alpha1.ObjectStore{}
+	for _, objectStore := range objectStores.Items {
+		if objectStore.Spec.HasZoneRef() && objectStore.Spec.Multisite.ZoneRef == zone.Name {
+			serving = append(serving, objectStore)
+		}
+	}
+
+	switch len(serving) {
+	case 0:
+		zone.Status.ObjectStore = ""
+		zone.Status.Endpoints = nil
+		setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonProgressing,
+			"waiting for an ObjectStore to serve the zone")
+		return nil
+	case 1:
+	default:
+		names := []string{}
+		for _, objectStore := range serving {
+			names = append(names, objectStore.Name)
+		}
+		sort.Strings(names)
+		setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonTopologyInvalid,
+			fmt.Sprintf("a zone is served by a single ObjectStore, found %s", strings.Join(names, ", ")))
+		return nil
+	}
+
+	objectStore := serving[0]
+	zone.Status.ObjectStore = objectStore.Name
+	zone.Status.Endpoints = objectStore.Status.ExternalEndpoints
+
+	// The master zone of the realm bootstraps it, the other zones join it
+	configured := meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionRealmBootstrapped) ||
+		meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionMultisiteConfigured)
+	if !configured {
+		setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonProgressing,
+			fmt.Sprintf("waiting for ObjectStore %q to configure the zone", objectStore.Name))
+		return nil
+	}
+
+	setStatusCondition(&zone.Status.Conditions, zone.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonTopologyReady,
+		fmt.Sprintf("zone is served by ObjectStore %q", objectStore.Name))
+	return nil
+}
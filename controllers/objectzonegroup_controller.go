@@ -0,0 +1,166 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ObjectZoneGroupReconciler reconciles a ObjectZoneGroup object
+type ObjectZoneGroupReconciler struct {
+	client.Client
+	*runtime.Scheme
+	logr.Logger
+}
+
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzonegroups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectzonegroups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=object.rgw-standalone,resources=objectrealms;objectzones,verbs=get;list;watch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ObjectZoneGroupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&objectv1alpha1.ObjectZoneGroup{}).
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectZone{}}, handler.EnqueueRequestsFromMapFunc(zoneGroupForZone)).
+		Watches(&source.Kind{Type: &objectv1alpha1.ObjectRealm{}}, handler.EnqueueRequestsFromMapFunc(r.zoneGroupsForRealm)).
+		Complete(r)
+}
+
+// zoneGroupForZone maps an ObjectZone to its ObjectZoneGroup
+func zoneGroupForZone(object client.Object) []reconcile.Request {
+	zone := object.(*objectv1alpha1.ObjectZone)
+	return []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: zone.Namespace, Name: zone.Spec.ZoneGroupRef}},
+	}
+}
+
+// zoneGroupsForRealm maps an ObjectRealm to its ObjectZoneGroups
+func (r *ObjectZoneGroupReconciler) zoneGroupsForRealm(object client.Object) []reconcile.Request {
+	zoneGroups := &objectv1alpha1.ObjectZoneGroupList{}
+	err := r.Client.List(context.Background(), zoneGroups, client.InNamespace(object.GetNamespace()))
+	if err != nil {
+		r.Logger.Error(err, "failed to list ObjectZoneGroups", "ObjectRealm", object.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, zoneGroup := range zoneGroups.Items {
+		if zoneGroup.Spec.RealmRef == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&zoneGroup)})
+		}
+	}
+	return requests
+}
+
+// Reconcile reports the zones of the zonegroup and whether it belongs to a realm and has a master
+// zone
+func (r *ObjectZoneGroupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Logger = ctrl.Log.WithValues("ObjectZoneGroup", req.NamespacedName.String())
+	r.Logger.Info("reconciling")
+
+	zoneGroup := &objectv1alpha1.ObjectZoneGroup{}
+	err := r.Client.Get(ctx, req.NamespacedName, zoneGroup)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			r.Logger.Info("ObjectZoneGroup resource not found. Ignoring since object must be deleted.")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("failed to get ObjectZoneGroup: %w", err)
+	}
+
+	original := zoneGroup.DeepCopy()
+	err = r.reconcileZoneGroup(ctx, zoneGroup)
+	if err != nil {
+		setStatusCondition(&zoneGroup.Status.Conditions, zoneGroup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, err.Error())
+	}
+	zoneGroup.Status.Phase = phaseFromConditions(zoneGroup.Status.Conditions, err)
+	zoneGroup.Status.ObservedGeneration = zoneGroup.Generation
+
+	// Always report what we have observed, even if the reconcile failed
+	statusErr := r.Client.Status().Patch(ctx, zoneGroup, client.MergeFrom(original))
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if statusErr != nil {
+		return reconcile.Result{}, fmt.Errorf("failed to update status of ObjectZoneGroup %q: %w", zoneGroup.Name, statusErr)
+	}
+
+	r.Logger.Info("successfully reconciled", "ObjectZoneGroup", req.NamespacedName.String(), "Phase", zoneGroup.Status.Phase)
+	return reconcile.Result{}, nil
+}
+
+func (r *ObjectZoneGroupReconciler) reconcileZoneGroup(ctx context.Context, zoneGroup *objectv1alpha1.ObjectZoneGroup) error {
+	zones := &objectv1alpha1.ObjectZoneList{}
+	err := r.Client.List(ctx, zones, client.InNamespace(zoneGroup.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to list ObjectZones: %w", err)
+	}
+
+	names := []string{}
+	masters := []string{}
+	for _, zone := range zones.Items {
+		if zone.Spec.ZoneGroupRef != zoneGroup.Name {
+			continue
+		}
+		names = append(names, zone.Name)
+		if zone.Spec.Master {
+			masters = append(masters, zone.Name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(masters)
+	zoneGroup.Status.Zones = names
+	zoneGroup.Status.MasterZone = strings.Join(masters, ",")
+
+	realm := &objectv1alpha1.ObjectRealm{}
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: zoneGroup.Namespace, Name: zoneGroup.Spec.RealmRef}, realm)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ObjectRealm %q: %w", zoneGroup.Spec.RealmRef, err)
+		}
+		setStatusCondition(&zoneGroup.Status.Conditions, zoneGroup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonTopologyInvalid,
+			fmt.Sprintf("ObjectRealm %q not found", zoneGroup.Spec.RealmRef))
+		return nil
+	}
+
+	// The zones of other clusters are not described here, e.g. the master zone of the realm seen
+	// from an edge cluster, so a zonegroup without master zone is valid
+	if len(masters) > 1 {
+		setStatusCondition(&zoneGroup.Status.Conditions, zoneGroup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonTopologyInvalid,
+			fmt.Sprintf("a zonegroup has a single master zone, found %s", strings.Join(masters, ", ")))
+		return nil
+	}
+
+	setStatusCondition(&zoneGroup.Status.Conditions, zoneGroup.Generation, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonTopologyReady,
+		fmt.Sprintf("zonegroup of realm %q with %d zone(s)", realm.Name, len(names)))
+	return nil
+}
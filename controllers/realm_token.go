@@ -0,0 +1,304 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// allNamespaces matches every namespace on either side of a --realm-token-namespaces pair
+	allNamespaces = "*"
+
+	// realmTokenBundleKey is the key of the Secret manifest in a bundle export
+	realmTokenBundleKey = "realm-token.yaml"
+)
+
+var (
+	// realmTokenExportLabel and realmTokenExportNamespaceLabel are set on the exported secrets to find
+	// them in any namespace, the ObjectStore can only own the ones of its namespace
+	realmTokenExportLabel          = fmt.Sprintf("%s/realm-token-of", objectv1alpha1.GroupVersion.Group)
+	realmTokenExportNamespaceLabel = fmt.Sprintf("%s/realm-token-of-namespace", objectv1alpha1.GroupVersion.Group)
+
+	// realmTokenAnnotation marks a secret created outside of the operator as a realm token the
+	// ObjectStores of other namespaces may read, the exports are recognized by their labels
+	realmTokenAnnotation = fmt.Sprintf("%s/realm-token", objectv1alpha1.GroupVersion.Group)
+)
+
+// RealmTokenNamespacePair allows the realm token of the source namespace to be read or exported by
+// the ObjectStores of the destination namespace
+type RealmTokenNamespacePair struct {
+	Source      string
+	Destination string
+}
+
+// ParseRealmTokenNamespaces parses the comma separated "source:destination" pairs of the
+// --realm-token-namespaces flag, "*" matches every namespace on either side
+func ParseRealmTokenNamespaces(value string) ([]RealmTokenNamespacePair, error) {
+	pairs := []RealmTokenNamespacePair{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid realm token namespaces %q, expected source:destination", entry)
+		}
+		pairs = append(pairs, RealmTokenNamespacePair{Source: strings.TrimSpace(parts[0]), Destination: strings.TrimSpace(parts[1])})
+	}
+	return pairs, nil
+}
+
+// realmTokenBundleTemplate is the manifest of the Secret written in a bundle export, it is applied
+// in the namespace of the secondary site
+const realmTokenBundleTemplate = `apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+type: Opaque
+data:
+  token: %s
+`
+
+// isRealmTokenNamespaceAllowed returns whether a realm token may go from the source namespace to
+// the destination namespace, a token is always allowed within its namespace
+func (r *ObjectStoreReconciler) isRealmTokenNamespaceAllowed(source, destination string) bool {
+	if source == destination {
+		return true
+	}
+	for _, allowed := range r.RealmTokenNamespaces {
+		if (allowed.Source == allNamespaces || allowed.Source == source) &&
+			(allowed.Destination == allNamespaces || allowed.Destination == destination) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRealmTokenSource returns whether the secret may be read as a realm token from another
+// namespace, only the exports of an ObjectStore and the secrets annotated as realm tokens are
+func isRealmTokenSource(secret *v1.Secret) bool {
+	return secret.Labels[realmTokenExportLabel] != "" || secret.Annotations[realmTokenAnnotation] == "true"
+}
+
+// realmTokenSourceKey returns the realm token secret a secondary site joins the realm with
+func realmTokenSourceKey(objectStore *objectv1alpha1.ObjectStore) client.ObjectKey {
+	namespace := objectStore.Spec.Multisite.RealmTokenSecretNamespace
+	if namespace == "" {
+		namespace = objectStore.Namespace
+	}
+	return client.ObjectKey{Namespace: namespace, Name: objectStore.Spec.Multisite.RealmTokenSecretName}
+}
+
+// localRealmTokenSecretName returns the realm token secret mounted by the zone job, a secret of
+// another namespace is copied next to the ObjectStore
+func localRealmTokenSecretName(objectStore *objectv1alpha1.ObjectStore) string {
+	if realmTokenSourceKey(objectStore).Namespace == objectStore.Namespace {
+		return objectStore.Spec.Multisite.RealmTokenSecretName
+	}
+	return fmt.Sprintf("%s-realm-token-copy", instanceName(objectStore.Name, objectStore.Namespace))
+}
+
+// getRealmTokenSecret returns the realm token secret of a secondary site. A secret of another
+// namespace must be allowed by the namespace pairs of the flag and be a realm token, so that the
+// operator cannot be used to copy any secret of a namespace into another.
+func (r *ObjectStoreReconciler) getRealmTokenSecret(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*v1.Secret, error) {
+	key := realmTokenSourceKey(objectStore)
+	if !r.isRealmTokenNamespaceAllowed(key.Namespace, objectStore.Namespace) {
+		return nil, fmt.Errorf("realm token secret %q: namespace %q to %q is not allowed by --realm-token-namespaces", key.Name, key.Namespace, objectStore.Namespace)
+	}
+
+	secret := &v1.Secret{}
+	err := r.Client.Get(ctx, key, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get realm token secret %q: %w", key.String(), err)
+	}
+
+	if secret.Namespace != objectStore.Namespace && !isRealmTokenSource(secret) {
+		return nil, fmt.Errorf("realm token secret %q is neither a realm token export nor annotated with %s=true", key.String(), realmTokenAnnotation)
+	}
+
+	return secret, nil
+}
+
+// copyRealmToken copies the realm token of another namespace next to the ObjectStore, so that the
+// zone job can mount it. The source must come from getRealmTokenSecret, it is checked again so
+// that only realm tokens are ever copied.
+func (r *ObjectStoreReconciler) copyRealmToken(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, source *v1.Secret) error {
+	if source.Namespace == objectStore.Namespace {
+		return nil
+	}
+	if !r.isRealmTokenNamespaceAllowed(source.Namespace, objectStore.Namespace) || !isRealmTokenSource(source) {
+		return fmt.Errorf("refusing to copy secret %q, it is not an allowed realm token", client.ObjectKeyFromObject(source).String())
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      localRealmTokenSecretName(objectStore),
+			Namespace: objectStore.Namespace,
+		},
+	}
+	err := controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+	}
+
+	mutateFunc := func() error {
+		secret.Labels = getLabels(objectStore.Name)
+		secret.Type = v1.SecretTypeOpaque
+		secret.Data = map[string][]byte{"token": source.Data["token"]}
+		return nil
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to copy realm token to secret %q: %w", secret.Name, err)
+	}
+	r.Logger.Info("realm token copy", "Secret", secret.Name, "Source", client.ObjectKeyFromObject(source).String(), "opResult", opResult)
+
+	return nil
+}
+
+// exportRealmToken publishes the realm token of a main or promoted site to the exports of the
+// spec, and deletes the exports that were removed from it. It returns the exported secrets and the
+// existing secrets that were left untouched because they are not exports of this ObjectStore.
+func (r *ObjectStoreReconciler) exportRealmToken(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) ([]string, []string, error) {
+	source := realmTokenSecretMeta(objectStore)
+	err := r.Client.Get(ctx, client.ObjectKeyFromObject(source), source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get realm token secret %q: %w", source.Name, err)
+	}
+	token := source.Data["token"]
+
+	exported := map[client.ObjectKey]struct{}{}
+	names := []string{}
+	conflicts := []string{}
+	for _, export := range objectStore.Spec.Multisite.RealmTokenExports {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      export.Name,
+				Namespace: export.Namespace,
+			},
+		}
+		if secret.Namespace == "" {
+			secret.Namespace = objectStore.Namespace
+		}
+		if !r.isRealmTokenNamespaceAllowed(objectStore.Namespace, secret.Namespace) {
+			return nil, nil, fmt.Errorf("realm token export %q: namespace %q to %q is not allowed by --realm-token-namespaces", secret.Name, objectStore.Namespace, secret.Namespace)
+		}
+
+		// A secret that is not an export of this ObjectStore is never overwritten
+		err = r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return nil, nil, fmt.Errorf("failed to get realm token export %q: %w", client.ObjectKeyFromObject(secret).String(), err)
+		}
+		if err == nil && !isRealmTokenExport(objectStore, secret) {
+			r.Logger.Info("realm token export conflicts with an existing secret", "Secret", client.ObjectKeyFromObject(secret).String())
+			conflicts = append(conflicts, client.ObjectKeyFromObject(secret).String())
+			continue
+		}
+
+		// Owner references cannot cross namespaces, the other exports are deleted with the ObjectStore
+		if secret.Namespace == objectStore.Namespace {
+			err = controllerutil.SetControllerReference(objectStore, secret, r.Scheme)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to set owner reference to secret %q: %w", secret.Name, err)
+			}
+		}
+
+		mutateFunc := func() error {
+			secret.Labels = realmTokenExportLabels(objectStore)
+			secret.Type = v1.SecretTypeOpaque
+			switch export.Format {
+			case objectv1alpha1.RealmTokenExportBundle:
+				bundle := fmt.Sprintf(realmTokenBundleTemplate, export.Name, base64.StdEncoding.EncodeToString(token))
+				secret.Data = map[string][]byte{realmTokenBundleKey: []byte(bundle)}
+			default:
+				secret.Data = map[string][]byte{"token": token}
+			}
+			return nil
+		}
+
+		opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, mutateFunc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to export realm token to secret %q: %w", client.ObjectKeyFromObject(secret).String(), err)
+		}
+		r.Logger.Info("realm token export", "Secret", client.ObjectKeyFromObject(secret).String(), "opResult", opResult)
+
+		exported[client.ObjectKeyFromObject(secret)] = struct{}{}
+		names = append(names, client.ObjectKeyFromObject(secret).String())
+	}
+
+	err = r.deleteRealmTokenExports(ctx, objectStore, exported)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return names, conflicts, nil
+}
+
+// deleteRealmTokenExports deletes the exported realm tokens of the ObjectStore in every namespace,
+// except the ones to keep
+func (r *ObjectStoreReconciler) deleteRealmTokenExports(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, keep map[client.ObjectKey]struct{}) error {
+	secrets := &v1.SecretList{}
+	err := r.Client.List(ctx, secrets, client.MatchingLabels(realmTokenExportLabels(objectStore)))
+	if err != nil {
+		return fmt.Errorf("failed to list realm token exports: %w", err)
+	}
+
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if _, ok := keep[client.ObjectKeyFromObject(secret)]; ok {
+			continue
+		}
+		err = r.Client.Delete(ctx, secret)
+		if err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete realm token export %q: %w", client.ObjectKeyFromObject(secret).String(), err)
+		}
+		r.Logger.Info("deleted realm token export", "Secret", client.ObjectKeyFromObject(secret).String())
+	}
+
+	return nil
+}
+
+// isRealmTokenExport returns whether the secret was exported by the ObjectStore
+func isRealmTokenExport(objectStore *objectv1alpha1.ObjectStore, secret *v1.Secret) bool {
+	for key, value := range realmTokenExportLabels(objectStore) {
+		if secret.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func realmTokenExportLabels(objectStore *objectv1alpha1.ObjectStore) map[string]string {
+	return map[string]string{
+		realmTokenExportLabel:          objectStore.Name,
+		realmTokenExportNamespaceLabel: objectStore.Namespace,
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestExportRealmToken(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "main", Namespace: "rgw", UID: "uid"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Multisite: &objectv1alpha1.MultisiteSpec{
+				IsMainSite: true,
+				RealmTokenExports: []objectv1alpha1.RealmTokenExport{
+					{Name: "token"},
+					{Name: "token", Namespace: "edge"},
+					{Name: "taken", Namespace: "edge"},
+				},
+			},
+		},
+	}
+	source := realmTokenSecretMeta(objectStore)
+	source.Data = map[string][]byte{"token": []byte("new")}
+	previous := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: "edge", Labels: realmTokenExportLabels(objectStore)},
+		Data:       map[string][]byte{"token": []byte("old")},
+	}
+	unrelated := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "taken", Namespace: "edge"},
+		Data:       map[string][]byte{"password": []byte("secret")},
+	}
+	stale := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "removed", Namespace: "edge", Labels: realmTokenExportLabels(objectStore)},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(source, previous, unrelated, stale).Build()
+	r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard(), RealmTokenNamespaces: []RealmTokenNamespacePair{{Source: "rgw", Destination: allNamespaces}}}
+
+	exported, conflicts, err := r.exportRealmToken(context.Background(), objectStore)
+	if err != nil {
+		t.Fatalf("exportRealmToken() = %v", err)
+	}
+	if want := []string{"rgw/token", "edge/token"}; !reflect.DeepEqual(exported, want) {
+		t.Errorf("exported %v, want %v", exported, want)
+	}
+	if want := []string{"edge/taken"}; !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts %v, want %v", conflicts, want)
+	}
+
+	for _, key := range []client.ObjectKey{{Namespace: "rgw", Name: "token"}, {Namespace: "edge", Name: "token"}} {
+		secret := &v1.Secret{}
+		if err := c.Get(context.Background(), key, secret); err != nil {
+			t.Fatalf("failed to get export %s: %v", key, err)
+		}
+		if string(secret.Data["token"]) != "new" {
+			t.Errorf("export %s has token %q, want %q", key, secret.Data["token"], "new")
+		}
+	}
+
+	secret := &v1.Secret{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(unrelated), secret); err != nil {
+		t.Fatalf("failed to get unrelated secret: %v", err)
+	}
+	if !reflect.DeepEqual(secret.Data, unrelated.Data) || len(secret.Labels) > 0 {
+		t.Errorf("unrelated secret was modified: %+v", secret)
+	}
+
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(stale), secret); err == nil {
+		t.Errorf("stale export %s was not deleted", client.ObjectKeyFromObject(stale))
+	}
+}
+
+func TestExportRealmTokenNamespaceNotAllowed(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "main", Namespace: "rgw"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Multisite: &objectv1alpha1.MultisiteSpec{
+				IsMainSite:        true,
+				RealmTokenExports: []objectv1alpha1.RealmTokenExport{{Name: "token", Namespace: "edge"}},
+			},
+		},
+	}
+	source := realmTokenSecretMeta(objectStore)
+	source.Data = map[string][]byte{"token": []byte("token")}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(source).Build()
+	r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+	if _, _, err := r.exportRealmToken(context.Background(), objectStore); err == nil {
+		t.Errorf("exportRealmToken() to a namespace that is not allowed succeeded")
+	}
+}
+
+func TestParseRealmTokenNamespaces(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []RealmTokenNamespacePair
+		wantErr bool
+	}{
+		{value: "", want: []RealmTokenNamespacePair{}},
+		{value: "rgw:edge, *:rgw-edge", want: []RealmTokenNamespacePair{{Source: "rgw", Destination: "edge"}, {Source: "*", Destination: "rgw-edge"}}},
+		{value: "edge", wantErr: true},
+		{value: "*", wantErr: true},
+		{value: "rgw:", wantErr: true},
+		{value: "rgw:edge:other", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := ParseRealmTokenNamespaces(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseRealmTokenNamespaces() = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ParseRealmTokenNamespaces() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetRealmTokenSecret(t *testing.T) {
+	exported := &objectv1alpha1.ObjectStore{ObjectMeta: metav1.ObjectMeta{Name: "main", Namespace: "rgw"}}
+	pairs := []RealmTokenNamespacePair{{Source: "rgw", Destination: "edge"}}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		labels      map[string]string
+		annotations map[string]string
+		pairs       []RealmTokenNamespacePair
+		wantErr     bool
+	}{
+		{name: "same namespace", namespace: "edge"},
+		{name: "export", namespace: "rgw", labels: realmTokenExportLabels(exported), pairs: pairs},
+		{name: "annotated", namespace: "rgw", annotations: map[string]string{realmTokenAnnotation: "true"}, pairs: pairs},
+		{name: "not a realm token", namespace: "rgw", pairs: pairs, wantErr: true},
+		{name: "namespace not allowed", namespace: "rgw", labels: realmTokenExportLabels(exported), wantErr: true},
+		{
+			name:      "pair in the other direction",
+			namespace: "rgw",
+			labels:    realmTokenExportLabels(exported),
+			pairs:     []RealmTokenNamespacePair{{Source: "edge", Destination: "rgw"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := &objectv1alpha1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "edge", UID: "uid"},
+				Spec: objectv1alpha1.ObjectStoreSpec{
+					Multisite: &objectv1alpha1.MultisiteSpec{
+						RealmTokenSecretName:      "token",
+						RealmTokenSecretNamespace: test.namespace,
+					},
+				},
+			}
+			source := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "token", Namespace: test.namespace, Labels: test.labels, Annotations: test.annotations},
+				Data:       map[string][]byte{"token": []byte("token")},
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(source).Build()
+			r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard(), RealmTokenNamespaces: test.pairs}
+
+			secret, err := r.getRealmTokenSecret(context.Background(), objectStore)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("getRealmTokenSecret() = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := r.copyRealmToken(context.Background(), objectStore, secret); err != nil {
+				t.Fatalf("copyRealmToken() = %v", err)
+			}
+		})
+	}
+}
+
+func TestCopyRealmTokenNotRealmToken(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "edge"}}
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "password", Namespace: "rgw"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(source).Build()
+	r := &ObjectStoreReconciler{
+		Client:               c,
+		Scheme:               c.Scheme(),
+		Logger:               logr.Discard(),
+		RealmTokenNamespaces: []RealmTokenNamespacePair{{Source: allNamespaces, Destination: allNamespaces}},
+	}
+
+	if err := r.copyRealmToken(context.Background(), objectStore, source); err == nil {
+		t.Errorf("copyRealmToken() of a secret that is not a realm token succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces the secrets in the logs and errors
+const redactedValue = "<redacted>"
+
+// secretFlags are the flags of the gateway commands whose value is a secret
+var secretFlags = []string{"realm-token", "secret-key", "access-key", "secret"}
+
+var (
+	// secretFlagPattern matches a secret flag and its value, either "--flag=value" or "--flag value"
+	secretFlagPattern = regexp.MustCompile(fmt.Sprintf(`(--(?:%s)(?:=|\s+))\S+`, strings.Join(secretFlags, "|")))
+
+	// secretLinePattern matches the realm token printed by `rgwam realm bootstrap`
+	secretLinePattern = regexp.MustCompile(`(?m)^(\s*Realm Token:\s*)\S+`)
+
+	// secretJSONPattern matches the keys printed by `radosgw-admin user info`
+	secretJSONPattern = regexp.MustCompile(`("(?:access_key|secret_key)"\s*:\s*)"[^"]*"`)
+)
+
+// redact masks the secrets in a command line or in the output of a command
+func redact(s string) string {
+	s = secretFlagPattern.ReplaceAllString(s, "${1}"+redactedValue)
+	s = secretLinePattern.ReplaceAllString(s, "${1}"+redactedValue)
+	return secretJSONPattern.ReplaceAllString(s, `${1}"`+redactedValue+`"`)
+}
+
+// redactCommand returns a copy of the command with the values of the secret flags masked, the
+// value of a flag may be its own argument
+func redactCommand(command []string) []string {
+	redacted := make([]string, len(command))
+	for i, arg := range command {
+		if i > 0 && isSecretFlag(command[i-1]) {
+			redacted[i] = redactedValue
+			continue
+		}
+		redacted[i] = redact(arg)
+	}
+	return redacted
+}
+
+func isSecretFlag(arg string) bool {
+	for _, flag := range secretFlags {
+		if arg == "--"+flag {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedError masks the secrets in the message of the error it wraps, the wrapped error is kept
+// so that its exit code can be read
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	kexec "k8s.io/utils/exec"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "realm token flag",
+			in:   "rgwam-sqlite zone create --zone=z1 --realm-token=c2VjcmV0 --endpoints=http://a",
+			want: "rgwam-sqlite zone create --zone=z1 --realm-token=<redacted> --endpoints=http://a",
+		},
+		{
+			name: "secret key flag",
+			in:   "radosgw-admin-sqlite user modify --uid=u --secret-key=abc123",
+			want: "radosgw-admin-sqlite user modify --uid=u --secret-key=<redacted>",
+		},
+		{
+			name: "access key flag",
+			in:   "radosgw-admin-sqlite user modify --uid=u --access-key=AKIA",
+			want: "radosgw-admin-sqlite user modify --uid=u --access-key=<redacted>",
+		},
+		{
+			name: "secret flag",
+			in:   "radosgw-admin-sqlite period update --commit --url=http://a --secret=abc123",
+			want: "radosgw-admin-sqlite period update --commit --url=http://a --secret=<redacted>",
+		},
+		{
+			name: "flag value as separate word",
+			in:   "radosgw-admin-sqlite user modify --secret-key abc123 --uid=u",
+			want: "radosgw-admin-sqlite user modify --secret-key <redacted> --uid=u",
+		},
+		{
+			name: "realm token line",
+			in:   "Realm Name: realm\nRealm Token: eyJyZWFsbSI6InJlYWxtIn0=\nZone: z1",
+			want: "Realm Name: realm\nRealm Token: <redacted>\nZone: z1",
+		},
+		{
+			name: "user keys",
+			in:   `{"keys": [{"user": "u", "access_key": "AKIA", "secret_key": "abc123"}]}`,
+			want: `{"keys": [{"user": "u", "access_key": "<redacted>", "secret_key": "<redacted>"}]}`,
+		},
+		{
+			name: "no secret",
+			in:   "radosgw-admin-sqlite user info --uid=u --secret-keys-are-not-flags",
+			want: "radosgw-admin-sqlite user info --uid=u --secret-keys-are-not-flags",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := redact(test.in); got != test.want {
+				t.Errorf("redact(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRedactCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		want    []string
+	}{
+		{
+			name:    "flags with values",
+			command: []string{"timeout", "15", "radosgw-admin-sqlite", "period", "update", "--access-key=AKIA", "--secret=abc123"},
+			want:    []string{"timeout", "15", "radosgw-admin-sqlite", "period", "update", "--access-key=<redacted>", "--secret=<redacted>"},
+		},
+		{
+			name:    "flag value as separate argument",
+			command: []string{"rgwam-sqlite", "zone", "create", "--realm-token", "c2VjcmV0", "--zone=z1"},
+			want:    []string{"rgwam-sqlite", "zone", "create", "--realm-token", "<redacted>", "--zone=z1"},
+		},
+		{
+			name:    "shell script",
+			command: []string{"sh", "-c", "radosgw-admin-sqlite user modify --uid=u --secret-key=abc123"},
+			want:    []string{"sh", "-c", "radosgw-admin-sqlite user modify --uid=u --secret-key=<redacted>"},
+		},
+		{
+			name:    "no secret",
+			command: []string{"radosgw-admin-sqlite", "user", "info", "--uid=u"},
+			want:    []string{"radosgw-admin-sqlite", "user", "info", "--uid=u"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := redactCommand(test.command)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("redactCommand(%q) = %q, want %q", test.command, got, test.want)
+			}
+			if test.command[len(test.command)-1] == redactedValue {
+				t.Errorf("redactCommand modified its argument")
+			}
+		})
+	}
+}
+
+func TestRedactedError(t *testing.T) {
+	exitErr := kexec.CodeExitError{Err: errors.New("command terminated with exit code 2: --secret-key=abc123"), Code: 2}
+	err := fmt.Errorf("failed to run command: %w", &redactedError{err: exitErr})
+
+	if strings.Contains(err.Error(), "abc123") {
+		t.Errorf("error %q leaks the secret", err.Error())
+	}
+	if !strings.Contains(err.Error(), "--secret-key=<redacted>") {
+		t.Errorf("error %q does not mask the secret flag", err.Error())
+	}
+
+	code, codeErr := extractExitCode(&redactedError{err: exitErr})
+	if codeErr != nil || code != 2 {
+		t.Errorf("extractExitCode() = %d, %v, want 2, nil", code, codeErr)
+	}
+}
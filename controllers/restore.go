@@ -0,0 +1,179 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	apps "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	reasonRestoreCompleted = "RestoreCompleted"
+	reasonRestoreFailed    = "RestoreFailed"
+)
+
+// reconcileRestore restores the data from the backup of the spec with a job, before the gateway is
+// deployed for the first time. It returns true once the gateway can start.
+func (r *ObjectStoreReconciler) reconcileRestore(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (bool, error) {
+	if objectStore.Spec.RestoreFrom == nil || meta.IsStatusConditionTrue(objectStore.Status.Conditions, objectv1alpha1.ConditionRestored) {
+		return true, nil
+	}
+
+	// Never overwrite the data of a gateway that has already run
+	if meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionRestored) == nil {
+		deployment := &apps.Deployment{}
+		err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: instanceName(objectStore.Name, objectStore.Namespace)}, deployment)
+		if err == nil {
+			r.Logger.Info("the gateway already ran, not restoring the backup")
+			return true, nil
+		}
+		if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get deployment %q: %w", deployment.Name, err)
+		}
+	}
+
+	target, key, err := r.restoreTarget(ctx, objectStore)
+	if err != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionRestored, metav1.ConditionFalse, reasonRestoreFailed, err.Error())
+		return false, err
+	}
+	if key == "" {
+		setCondition(objectStore, objectv1alpha1.ConditionRestored, metav1.ConditionFalse, reasonProgressing,
+			fmt.Sprintf("waiting for ObjectStoreBackup %q to have a backup", objectStore.Spec.RestoreFrom.BackupName))
+		return false, nil
+	}
+
+	job := restoreJobMeta(objectStore)
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(job), job)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get restore job %q: %w", job.Name, err)
+		}
+
+		err = r.createRestoreJob(ctx, objectStore, job, target, key)
+		if err != nil {
+			return false, err
+		}
+		setCondition(objectStore, objectv1alpha1.ConditionRestored, metav1.ConditionFalse, reasonProgressing, fmt.Sprintf("restoring backup %q", key))
+		return false, nil
+	}
+
+	completed, err := jobCompleted(job)
+	if err != nil {
+		// Delete the failed job so that it is created again on the next reconcile
+		deleteErr := r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if deleteErr != nil && !kerrors.IsNotFound(deleteErr) {
+			r.Logger.Error(deleteErr, "failed to delete failed restore job", "Job", job.Name)
+		}
+		setCondition(objectStore, objectv1alpha1.ConditionRestored, metav1.ConditionFalse, reasonRestoreFailed, err.Error())
+		return false, err
+	}
+	if !completed {
+		return false, nil
+	}
+
+	r.Logger.Info("successfully restored backup", "key", key)
+	objectStore.Status.RestoredFrom = key
+	setCondition(objectStore, objectv1alpha1.ConditionRestored, metav1.ConditionTrue, reasonRestoreCompleted, fmt.Sprintf("restored backup %q and checked its integrity", key))
+	return true, nil
+}
+
+// restoreTarget returns the storage holding the backup to restore and its key, the key is empty
+// while the ObjectStoreBackup has not made any backup
+func (r *ObjectStoreReconciler) restoreTarget(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*objectv1alpha1.BackupTarget, string, error) {
+	restore := objectStore.Spec.RestoreFrom
+	if restore.Target != nil {
+		return restore.Target, restore.Key, nil
+	}
+
+	backup := &objectv1alpha1.ObjectStoreBackup{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: restore.BackupName}, backup)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get ObjectStoreBackup %q: %w", restore.BackupName, err)
+	}
+	if restore.Key != "" {
+		return &backup.Spec.Target, restore.Key, nil
+	}
+
+	return &backup.Spec.Target, backup.Status.LastBackup, nil
+}
+
+func (r *ObjectStoreReconciler) createRestoreJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, job *batchv1.Job, target *objectv1alpha1.BackupTarget, key string) error {
+	backoffLimit := int32(2)
+	job.Spec = batchv1.JobSpec{
+		Template: v1.PodTemplateSpec{
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:    "restore",
+						Image:   gatewayImage(objectStore),
+						Command: []string{"python3", "-c", restoreScript},
+						Env:     s3TargetEnvVars(target, key),
+						VolumeMounts: []v1.VolumeMount{
+							daemonVolumeMountPVC(),
+							{Name: "staging", MountPath: backupStagingDirectory},
+						},
+					},
+				},
+				Volumes: []v1.Volume{
+					DaemonVolumesDataPVC(instanceName(objectStore.Name, objectStore.Namespace)),
+					{Name: "staging", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+				},
+				RestartPolicy: v1.RestartPolicyNever,
+				SecurityContext: &v1.PodSecurityContext{
+					RunAsUser:  &CephUID,
+					RunAsGroup: &cephGID,
+					FSGroup:    &CephUID,
+				},
+			},
+		},
+		BackoffLimit: &backoffLimit,
+	}
+	applyPodPlacement(objectStore, &job.Spec.Template.Spec)
+
+	err := controllerutil.SetControllerReference(objectStore, job, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to job %q: %w", job.Name, err)
+	}
+
+	err = r.Client.Create(ctx, job)
+	if err != nil {
+		return fmt.Errorf("failed to create restore job %q: %w", job.Name, err)
+	}
+	r.Logger.Info("created restore job", "Job", job.Name, "key", key)
+
+	return nil
+}
+
+func restoreJobMeta(objectStore *objectv1alpha1.ObjectStore) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-restore", instanceName(objectStore.Name, objectStore.Namespace)),
+			Namespace: objectStore.Namespace,
+		},
+	}
+}
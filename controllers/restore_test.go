@@ -0,0 +1,430 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	apps "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestScheme returns the scheme of the manager, with the types of the operator
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	if err := objectv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	return scheme
+}
+
+var testBackupTarget = objectv1alpha1.BackupTarget{
+	Endpoint:              "https://s3.example.com",
+	Bucket:                "backups",
+	CredentialsSecretName: "s3-credentials",
+}
+
+func newRestoreObjectStore(restoreFrom *objectv1alpha1.RestoreSource) *objectv1alpha1.ObjectStore {
+	return &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "rgw", UID: "uid"},
+		Spec:       objectv1alpha1.ObjectStoreSpec{Image: "quay.io/ceph/ceph:v17.2.3", RestoreFrom: restoreFrom},
+	}
+}
+
+func newRestoreReconciler(t *testing.T, objects ...client.Object) *ObjectStoreReconciler {
+	t.Helper()
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+	return &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+}
+
+func TestRestoreTarget(t *testing.T) {
+	otherTarget := testBackupTarget
+	otherTarget.Bucket = "other"
+	backup := &objectv1alpha1.ObjectStoreBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "rgw"},
+		Spec:       objectv1alpha1.ObjectStoreBackupSpec{Target: testBackupTarget},
+		Status:     objectv1alpha1.ObjectStoreBackupStatus{LastBackup: "rgw/store/nightly-27700000.tar.gz"},
+	}
+	pendingBackup := &objectv1alpha1.ObjectStoreBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "rgw"},
+		Spec:       objectv1alpha1.ObjectStoreBackupSpec{Target: testBackupTarget},
+	}
+
+	tests := []struct {
+		name       string
+		restore    *objectv1alpha1.RestoreSource
+		wantTarget *objectv1alpha1.BackupTarget
+		wantKey    string
+		wantErr    bool
+	}{
+		{
+			name:       "target and key",
+			restore:    &objectv1alpha1.RestoreSource{Target: &otherTarget, Key: "old.tar.gz"},
+			wantTarget: &otherTarget,
+			wantKey:    "old.tar.gz",
+		},
+		{
+			name:       "target takes precedence over the ObjectStoreBackup",
+			restore:    &objectv1alpha1.RestoreSource{BackupName: "nightly", Target: &otherTarget, Key: "old.tar.gz"},
+			wantTarget: &otherTarget,
+			wantKey:    "old.tar.gz",
+		},
+		{
+			name:       "last backup of the ObjectStoreBackup",
+			restore:    &objectv1alpha1.RestoreSource{BackupName: "nightly"},
+			wantTarget: &testBackupTarget,
+			wantKey:    "rgw/store/nightly-27700000.tar.gz",
+		},
+		{
+			name:       "key of the ObjectStoreBackup",
+			restore:    &objectv1alpha1.RestoreSource{BackupName: "nightly", Key: "rgw/store/nightly-27600000.tar.gz"},
+			wantTarget: &testBackupTarget,
+			wantKey:    "rgw/store/nightly-27600000.tar.gz",
+		},
+		{
+			name:       "ObjectStoreBackup without backup",
+			restore:    &objectv1alpha1.RestoreSource{BackupName: "pending"},
+			wantTarget: &testBackupTarget,
+			wantKey:    "",
+		},
+		{
+			name:    "missing ObjectStoreBackup",
+			restore: &objectv1alpha1.RestoreSource{BackupName: "missing"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRestoreReconciler(t, backup, pendingBackup)
+			target, key, err := r.restoreTarget(context.Background(), newRestoreObjectStore(test.restore))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("restoreTarget() error = %v, want error %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(target, test.wantTarget) || key != test.wantKey {
+				t.Errorf("restoreTarget() = %+v, %q, want %+v, %q", target, key, test.wantTarget, test.wantKey)
+			}
+		})
+	}
+}
+
+func TestReconcileRestore(t *testing.T) {
+	restoreFrom := &objectv1alpha1.RestoreSource{Target: &testBackupTarget, Key: "store.tar.gz"}
+	deployment := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: instanceName("store", "rgw"), Namespace: "rgw"}}
+	restoreJob := func(status batchv1.JobStatus) *batchv1.Job {
+		job := restoreJobMeta(newRestoreObjectStore(nil))
+		job.Status = status
+		return job
+	}
+	failed := batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Message: "BackoffLimitExceeded"}}}
+	withCondition := func(status metav1.ConditionStatus, reason string) []metav1.Condition {
+		return []metav1.Condition{{Type: objectv1alpha1.ConditionRestored, Status: status, Reason: reason}}
+	}
+
+	tests := []struct {
+		name          string
+		restoreFrom   *objectv1alpha1.RestoreSource
+		conditions    []metav1.Condition
+		objects       []client.Object
+		wantReady     bool
+		wantErr       bool
+		wantCondition *metav1.Condition
+		wantJob       bool
+		wantRestored  string
+	}{
+		{
+			name:      "nothing to restore",
+			wantReady: true,
+		},
+		{
+			name:        "already restored",
+			restoreFrom: restoreFrom,
+			conditions:  withCondition(metav1.ConditionTrue, reasonRestoreCompleted),
+			wantReady:   true,
+		},
+		{
+			name:        "gateway already ran",
+			restoreFrom: restoreFrom,
+			objects:     []client.Object{deployment},
+			wantReady:   true,
+		},
+		{
+			name:          "restore in progress is not mistaken for a gateway that ran",
+			restoreFrom:   restoreFrom,
+			conditions:    withCondition(metav1.ConditionFalse, reasonProgressing),
+			objects:       []client.Object{deployment, restoreJob(batchv1.JobStatus{Active: 1})},
+			wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonProgressing},
+			wantJob:       true,
+		},
+		{
+			name:          "waiting for a backup",
+			restoreFrom:   &objectv1alpha1.RestoreSource{BackupName: "pending"},
+			objects:       []client.Object{&objectv1alpha1.ObjectStoreBackup{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "rgw"}}},
+			wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonProgressing},
+		},
+		{
+			name:          "missing ObjectStoreBackup",
+			restoreFrom:   &objectv1alpha1.RestoreSource{BackupName: "missing"},
+			wantErr:       true,
+			wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonRestoreFailed},
+		},
+		{
+			name:          "job created",
+			restoreFrom:   restoreFrom,
+			wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonProgressing},
+			wantJob:       true,
+		},
+		{
+			name:         "job succeeded",
+			restoreFrom:  restoreFrom,
+			conditions:   withCondition(metav1.ConditionFalse, reasonProgressing),
+			objects:      []client.Object{restoreJob(batchv1.JobStatus{Succeeded: 1})},
+			wantReady:    true,
+			wantJob:      true,
+			wantRestored: "store.tar.gz",
+			wantCondition: &metav1.Condition{
+				Status: metav1.ConditionTrue,
+				Reason: reasonRestoreCompleted,
+			},
+		},
+		{
+			name:          "job failed",
+			restoreFrom:   restoreFrom,
+			conditions:    withCondition(metav1.ConditionFalse, reasonProgressing),
+			objects:       []client.Object{restoreJob(failed)},
+			wantErr:       true,
+			wantCondition: &metav1.Condition{Status: metav1.ConditionFalse, Reason: reasonRestoreFailed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRestoreReconciler(t, test.objects...)
+			objectStore := newRestoreObjectStore(test.restoreFrom)
+			objectStore.Status.Conditions = test.conditions
+
+			ready, err := r.reconcileRestore(context.Background(), objectStore)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("reconcileRestore() error = %v, want error %v", err, test.wantErr)
+			}
+			if ready != test.wantReady {
+				t.Errorf("reconcileRestore() = %v, want %v", ready, test.wantReady)
+			}
+			if objectStore.Status.RestoredFrom != test.wantRestored {
+				t.Errorf("restoredFrom = %q, want %q", objectStore.Status.RestoredFrom, test.wantRestored)
+			}
+
+			condition := meta.FindStatusCondition(objectStore.Status.Conditions, objectv1alpha1.ConditionRestored)
+			switch {
+			case test.wantCondition == nil && condition != nil && test.conditions == nil:
+				t.Errorf("condition %+v, want none", condition)
+			case test.wantCondition != nil && condition == nil:
+				t.Errorf("no condition, want %+v", test.wantCondition)
+			case test.wantCondition != nil && (condition.Status != test.wantCondition.Status || condition.Reason != test.wantCondition.Reason):
+				t.Errorf("condition %s/%s, want %s/%s", condition.Status, condition.Reason, test.wantCondition.Status, test.wantCondition.Reason)
+			}
+
+			job := restoreJobMeta(objectStore)
+			err = r.Client.Get(context.Background(), client.ObjectKeyFromObject(job), job)
+			if err != nil && !kerrors.IsNotFound(err) {
+				t.Fatalf("failed to get restore job: %v", err)
+			}
+			if exists := err == nil; exists != test.wantJob {
+				t.Errorf("restore job exists = %v, want %v", exists, test.wantJob)
+			}
+		})
+	}
+}
+
+func TestCreateRestoreJob(t *testing.T) {
+	r := newRestoreReconciler(t)
+	objectStore := newRestoreObjectStore(&objectv1alpha1.RestoreSource{Target: &testBackupTarget, Key: "store.tar.gz"})
+
+	_, err := r.reconcileRestore(context.Background(), objectStore)
+	if err != nil {
+		t.Fatalf("reconcileRestore() = %v", err)
+	}
+
+	job := restoreJobMeta(objectStore)
+	err = r.Client.Get(context.Background(), client.ObjectKeyFromObject(job), job)
+	if err != nil {
+		t.Fatalf("failed to get restore job: %v", err)
+	}
+	if !metav1.IsControlledBy(job, objectStore) {
+		t.Errorf("restore job is not controlled by the ObjectStore")
+	}
+	container := job.Spec.Template.Spec.Containers[0]
+	if container.Image != objectStore.Spec.Image {
+		t.Errorf("image %q, want %q", container.Image, objectStore.Spec.Image)
+	}
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	if env["S3_BUCKET"] != testBackupTarget.Bucket || env["S3_KEY"] != "store.tar.gz" || env["DATA_DIR"] != objectStoreDataDirectory {
+		t.Errorf("restore job environment %v does not point to the backup", env)
+	}
+}
+
+// archiveEntry is an entry of a test backup archive, a symlink when link is set
+type archiveEntry struct {
+	name    string
+	content string
+	link    string
+}
+
+func newArchive(t *testing.T, entries []archiveEntry) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if entry.link != "" {
+			header = &tar.Header{Name: entry.name, Mode: 0777, Linkname: entry.link, Typeflag: tar.TypeSymlink}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("failed to write archive: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.content)); err != nil {
+			t.Fatalf("failed to write archive: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestRestoreScript runs the restore script against a fake S3 target serving the archive
+func TestRestoreScript(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not available")
+	}
+
+	tests := []struct {
+		name      string
+		entries   []archiveEntry
+		wantErr   string
+		wantFiles map[string]string
+	}{
+		{
+			name:      "archive",
+			entries:   []archiveEntry{{name: "rgw.db.txt", content: "db"}, {name: "zone/config", content: "config"}},
+			wantFiles: map[string]string{"rgw.db.txt": "db", "zone/config": "config"},
+		},
+		{
+			name:    "parent directory",
+			entries: []archiveEntry{{name: "rgw.db.txt", content: "db"}, {name: "../escape", content: "evil"}},
+			wantErr: "refusing to restore unsafe archive entry ../escape",
+		},
+		{
+			name:    "absolute path",
+			entries: []archiveEntry{{name: "/tmp/escape", content: "evil"}},
+			wantErr: "refusing to restore unsafe archive entry /tmp/escape",
+		},
+		{
+			name:    "symlink",
+			entries: []archiveEntry{{name: "link", link: "/etc"}},
+			wantErr: "refusing to restore unsafe archive entry link",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := newArchive(t, test.entries)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/backups/rgw/store.tar.gz" || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				_, _ = w.Write(archive)
+			}))
+			defer server.Close()
+
+			dataDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dataDir, "previous"), []byte("previous"), 0600); err != nil {
+				t.Fatalf("failed to write data: %v", err)
+			}
+
+			cmd := exec.Command(python, "-c", restoreScript)
+			cmd.Env = append(os.Environ(),
+				"DATA_DIR="+dataDir,
+				"STAGING_DIR="+t.TempDir(),
+				"S3_ENDPOINT="+server.URL,
+				"S3_BUCKET=backups",
+				"S3_KEY=rgw/store.tar.gz",
+				"AWS_ACCESS_KEY_ID=access",
+				"AWS_SECRET_ACCESS_KEY=secret",
+			)
+			output, err := cmd.CombinedOutput()
+
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(string(output), test.wantErr) {
+					t.Fatalf("restore script = %v: %s, want %q", err, output, test.wantErr)
+				}
+				// Nothing is removed before every entry is checked
+				if _, err := os.Stat(filepath.Join(dataDir, "previous")); err != nil {
+					t.Errorf("data was changed by a refused archive: %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("restore script = %v: %s", err, output)
+			}
+			if _, err := os.Stat(filepath.Join(dataDir, "previous")); !os.IsNotExist(err) {
+				t.Errorf("previous data was not removed: %v", err)
+			}
+			for name, content := range test.wantFiles {
+				data, err := os.ReadFile(filepath.Join(dataDir, name))
+				if err != nil || string(data) != content {
+					t.Errorf("restored %s = %q, %v, want %q", name, data, err, content)
+				}
+			}
+		})
+	}
+}
@@ -20,27 +20,26 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"time"
+	"strings"
 
 	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
 
 	apps "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
-	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
-	"k8s.io/apimachinery/pkg/util/wait"
 	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
 	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 const (
-	rgwPortInternalPort      int32 = 7480
-	appName                        = "rgw"
-	podNameEnvVar                  = "POD_NAME"
-	objectStoreDataDirectory       = "/var/lib/ceph/radosgw/data"
+	rgwPortInternalPort       int32 = 7480
+	rgwSecurePortInternalPort int32 = 7443
+	appName                         = "rgw"
+	podNameEnvVar                   = "POD_NAME"
+	objectStoreLabel                = "object_store"
+	objectStoreDataDirectory        = "/var/lib/ceph/radosgw/data"
 	x
 )
 
@@ -49,7 +48,7 @@ var (
 	CephUID int64 = 167
 )
 
-func (r *ObjectStoreReconciler) createOrUpdateDeployment(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoint string) (controllerutil.OperationResult, error) {
+func (r *ObjectStoreReconciler) createOrUpdateDeployment(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoint, configHash string) (controllerutil.OperationResult, error) {
 	deploy := &apps.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      instanceName(objectStore.Name, objectStore.Namespace),
@@ -65,12 +64,14 @@ func (r *ObjectStoreReconciler) createOrUpdateDeployment(ctx context.Context, ob
 	}
 
 	mutateFunc := func() error {
-		pod, err := r.makeRGWPodSpec(objectStore, endpoint)
+		pod, err := r.makeRGWPodSpec(objectStore, endpoint, configHash)
 		if err != nil {
 			return err
 		}
 
+		replicas := gatewayReplicas(objectStore)
 		deploy.Spec = apps.DeploymentSpec{
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: getLabels(objectStore.Name),
 			},
@@ -83,7 +84,7 @@ func (r *ObjectStoreReconciler) createOrUpdateDeployment(ctx context.Context, ob
 	return controllerutil.CreateOrUpdate(ctx, r.Client, deploy, mutateFunc)
 }
 
-func (r *ObjectStoreReconciler) makeRGWPodSpec(objectStore *objectv1alpha1.ObjectStore, endpoint string) (v1.PodTemplateSpec, error) {
+func (r *ObjectStoreReconciler) makeRGWPodSpec(objectStore *objectv1alpha1.ObjectStore, endpoint, configHash string) (v1.PodTemplateSpec, error) {
 	rgwDaemonContainer := r.makeDaemonContainer(objectStore)
 	if reflect.DeepEqual(rgwDaemonContainer, v1.Container{}) {
 		return v1.PodTemplateSpec{}, fmt.Errorf("got empty container for RGW daemon")
@@ -92,7 +93,7 @@ func (r *ObjectStoreReconciler) makeRGWPodSpec(objectStore *objectv1alpha1.Objec
 		InitContainers: []v1.Container{
 			// We must chown the data directory since some csi drivers do not honour the FSGroup policy
 			// We need to make sure the object store data directory is owned by the ceph user
-			chownCephDataDirsInitContainer(objectStore.Spec.Image, []v1.VolumeMount{daemonVolumeMountPVC()}, podSecurityContext()),
+			chownCephDataDirsInitContainer(gatewayImage(objectStore), []v1.VolumeMount{daemonVolumeMountPVC()}, podSecurityContext()),
 		},
 		Containers:    []v1.Container{rgwDaemonContainer},
 		RestartPolicy: v1.RestartPolicyAlways,
@@ -107,10 +108,20 @@ func (r *ObjectStoreReconciler) makeRGWPodSpec(objectStore *objectv1alpha1.Objec
 		// ServiceAccountName: appName,
 	}
 
+	if objectStore.Spec.IsTLSEnabled() {
+		podSpec.Volumes = append(podSpec.Volumes, tlsVolume(objectStore))
+	}
+	if objectStore.Spec.ConfigMapRef != nil {
+		podSpec.Volumes = append(podSpec.Volumes, configVolume(objectStore))
+	}
+	applyPodPlacement(objectStore, &podSpec)
+
 	podTemplateSpec := v1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   instanceName(objectStore.Name, objectStore.Namespace),
 			Labels: getLabels(objectStore.Name),
+			// The gateway only reads its configuration when it starts
+			Annotations: map[string]string{configHashAnnotation: configHash},
 		},
 		Spec: podSpec,
 	}
@@ -127,7 +138,7 @@ func (r *ObjectStoreReconciler) makeDaemonContainer(objectStore *objectv1alpha1.
 	// start the rgw daemon in the foreground
 	container := v1.Container{
 		Name:  "rgw",
-		Image: objectStore.Spec.Image,
+		Image: gatewayImage(objectStore),
 		Command: []string{
 			"radosgw-sqlite",
 		},
@@ -136,18 +147,98 @@ func (r *ObjectStoreReconciler) makeDaemonContainer(objectStore *objectv1alpha1.
 			// Use a hash otherwise the socket name might be too long
 			newFlag("id", hash(ContainerEnvVarReference(podNameEnvVar))),
 			newFlag("host", ContainerEnvVarReference(podNameEnvVar)),
-			// TODO: remove me one day? - currently it's helpful to see the DB's initialization progress
-			newFlag("debug rgw", "15"),
-			// TODO: remove me once caching is fixed
-			newFlag("rgw cache enabled", "false"),
 		),
 		VolumeMounts: []v1.VolumeMount{daemonVolumeMountPVC()},
-		Env:          DaemonEnvVars(objectStore.Spec.Image),
+		Env:          DaemonEnvVars(gatewayImage(objectStore)),
+	}
+
+	container.Args = append(container.Args, daemonConfigFlags(objectStore)...)
+	// The last conf flag wins over the dummy config file of the default flags
+	if objectStore.Spec.ConfigMapRef != nil {
+		container.Args = append(container.Args, newFlag("conf", configFilePath()))
+		container.VolumeMounts = append(container.VolumeMounts, configVolumeMount())
+	}
+
+	if objectStore.Spec.IsTLSEnabled() {
+		container.Args = append(container.Args, newFlag("rgw frontends", rgwFrontends()))
+		container.VolumeMounts = append(container.VolumeMounts, tlsVolumeMount())
+	}
+	container.LivenessProbe = gatewayProbe(probeSpec(objectStore, livenessProbe), 10, 3)
+	container.ReadinessProbe = gatewayProbe(probeSpec(objectStore, readinessProbe), 10, 3)
+	// The first start initializes the database, allow it up to 10 minutes
+	container.StartupProbe = gatewayProbe(probeSpec(objectStore, startupProbe), 10, 60)
+
+	// The bucket name is read from the sub-domain of the host of virtual host style requests
+	if expose := objectStore.Spec.Gateway.Expose; expose != nil && expose.VirtualHostStyle {
+		container.Args = append(container.Args, newFlag("rgw dns name", expose.Host))
 	}
 
 	return container
 }
 
+type probeType int
+
+const (
+	livenessProbe probeType = iota
+	readinessProbe
+	startupProbe
+)
+
+// probeSpec returns the settings of a probe from the ObjectStore, nil when not set
+func probeSpec(objectStore *objectv1alpha1.ObjectStore, probe probeType) *objectv1alpha1.ProbeSpec {
+	probes := objectStore.Spec.Gateway.Probes
+	if probes == nil {
+		return nil
+	}
+	switch probe {
+	case livenessProbe:
+		return probes.Liveness
+	case readinessProbe:
+		return probes.Readiness
+	case startupProbe:
+		return probes.Startup
+	}
+	return nil
+}
+
+// gatewayProbe returns an http probe of the internal port of the gateway, which always serves http,
+// with the settings of the spec applied over the defaults
+func gatewayProbe(spec *objectv1alpha1.ProbeSpec, periodSeconds, failureThreshold int32) *v1.Probe {
+	probe := &v1.Probe{
+		ProbeHandler: v1.ProbeHandler{
+			HTTPGet: &v1.HTTPGetAction{
+				Path:   "/",
+				Port:   intstr.FromInt(int(rgwPortInternalPort)),
+				Scheme: v1.URISchemeHTTP,
+			},
+		},
+		SuccessThreshold: 1,
+		PeriodSeconds:    periodSeconds,
+		TimeoutSeconds:   5,
+		FailureThreshold: failureThreshold,
+	}
+	if spec == nil {
+		return probe
+	}
+	if spec.Disabled {
+		return nil
+	}
+	if spec.InitialDelaySeconds != 0 {
+		probe.InitialDelaySeconds = spec.InitialDelaySeconds
+	}
+	if spec.PeriodSeconds != 0 {
+		probe.PeriodSeconds = spec.PeriodSeconds
+	}
+	if spec.TimeoutSeconds != 0 {
+		probe.TimeoutSeconds = spec.TimeoutSeconds
+	}
+	if spec.FailureThreshold != 0 {
+		probe.FailureThreshold = spec.FailureThreshold
+	}
+
+	return probe
+}
+
 func (r *ObjectStoreReconciler) generateService(objectStore *objectv1alpha1.ObjectStore) *v1.Service {
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -160,60 +251,129 @@ func (r *ObjectStoreReconciler) generateService(objectStore *objectv1alpha1.Obje
 	return svc
 }
 
-func (r *ObjectStoreReconciler) reconcileService(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (string, error) {
+func (r *ObjectStoreReconciler) reconcileService(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*v1.Service, error) {
 	service := r.generateService(objectStore)
 
 	err := controllerutil.SetControllerReference(objectStore, service, r.Scheme)
 	if err != nil {
-		return "", fmt.Errorf("failed to set owner reference to service %q: %w", service.Name, err)
+		return nil, fmt.Errorf("failed to set owner reference to service %q: %w", service.Name, err)
 	}
 
-	port := int32(8080)
-	if objectStore.Spec.Gateway.Port != 0 {
-		port = objectStore.Spec.Gateway.Port
+	port := gatewayPort(objectStore)
+	securePort := gatewaySecurePort(objectStore)
+	serviceType := v1.ServiceTypeClusterIP
+	annotations := map[string]string{}
+	if objectStore.Spec.Gateway.Service != nil {
+		if objectStore.Spec.Gateway.Service.Type != "" {
+			serviceType = objectStore.Spec.Gateway.Service.Type
+		}
+		annotations = objectStore.Spec.Gateway.Service.Annotations
 	}
 
 	// Create mutate function to update the service
 	mutateFunc := func() error {
-		// If the cluster is not external we add the Selector
-		service.Spec = v1.ServiceSpec{
-			Selector: getLabels(objectStore.Name),
-		}
-
-		addPort(service, "http", port, rgwPortInternalPort)
+		// Only the fields we manage are set, the ones allocated by Kubernetes (e.g. the ClusterIP
+		// and the node ports) are preserved so that the service is not updated on every reconcile
+		service.Spec.Selector = getLabels(objectStore.Name)
+		service.Spec.Type = serviceType
+		service.Annotations = applyManagedAnnotations(service.Annotations, annotations)
+
+		existingPorts := service.Spec.Ports
+		service.Spec.Ports = nil
+		addPort(service, existingPorts, "http", port, rgwPortInternalPort)
+		addPort(service, existingPorts, "https", securePort, rgwSecurePortInternalPort)
 		return nil
 	}
 
 	// Create or update the service
 	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, mutateFunc)
 	if err != nil {
-		return "", fmt.Errorf("failed to create or update object store %q service %q: %w", objectStore.Name, opResult, err)
+		return nil, fmt.Errorf("failed to create or update object store %q service %q: %w", objectStore.Name, opResult, err)
 	}
-	r.Logger.Info("object store gateway service ", "opResult", opResult, "at", service.Spec.ClusterIP, "port", port)
+	r.Logger.Info("object store gateway service ", "opResult", opResult, "type", service.Spec.Type, "at", service.Spec.ClusterIP, "port", port)
 
-	return service.Spec.ClusterIP, nil
+	return service, nil
 }
 
-func addPort(service *v1.Service, name string, port, destPort int32) {
+// addPort adds a port to the service, the node port allocated to an existing port of the same
+// name is kept
+func addPort(service *v1.Service, existingPorts []v1.ServicePort, name string, port, destPort int32) {
 	if port == 0 || destPort == 0 {
 		return
 	}
-	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{
+
+	servicePort := v1.ServicePort{
 		Name:       name,
 		Port:       port,
 		TargetPort: intstr.FromInt(int(destPort)),
 		Protocol:   v1.ProtocolTCP,
-	})
+	}
+	if service.Spec.Type != v1.ServiceTypeClusterIP {
+		for _, existingPort := range existingPorts {
+			if existingPort.Name == name {
+				servicePort.NodePort = existingPort.NodePort
+			}
+		}
+	}
+	service.Spec.Ports = append(service.Spec.Ports, servicePort)
+}
+
+// advertisedEndpoints returns the endpoints the other sites reach the gateway at, it returns false
+// while the load balancer has no address yet
+func advertisedEndpoints(objectStore *objectv1alpha1.ObjectStore, service *v1.Service) ([]string, bool) {
+	if len(objectStore.Spec.Gateway.ExternalEndpoints) > 0 {
+		return objectStore.Spec.Gateway.ExternalEndpoints, true
+	}
+
+	if service.Spec.Type == v1.ServiceTypeLoadBalancer {
+		endpoints := []string{}
+		for _, ingress := range service.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if ingress.Hostname != "" {
+				host = ingress.Hostname
+			}
+			endpoints = append(endpoints, gatewayEndpoint(objectStore, host))
+		}
+		return endpoints, len(endpoints) > 0
+	}
+
+	return []string{gatewayEndpoint(objectStore, service.Spec.ClusterIP)}, true
 }
 
 func getLabels(name string) map[string]string {
 	return map[string]string{
-		"object_store": name,
+		objectStoreLabel: name,
 	}
 }
 
 func getLabelString(name string) string {
-	return fmt.Sprintf("object_store=%s", name)
+	return fmt.Sprintf("%s=%s", objectStoreLabel, name)
+}
+
+// applyPodPlacement applies the scheduling configuration, the pull secrets and the resources of the
+// ObjectStore to a pod spec, the resources are set on the containers that do not have any yet
+func applyPodPlacement(objectStore *objectv1alpha1.ObjectStore, podSpec *v1.PodSpec) {
+	podSpec.ImagePullSecrets = objectStore.Spec.ImagePullSecrets
+	for i := range podSpec.InitContainers {
+		if reflect.DeepEqual(podSpec.InitContainers[i].Resources, v1.ResourceRequirements{}) {
+			podSpec.InitContainers[i].Resources = objectStore.Spec.Gateway.Resources
+		}
+	}
+	for i := range podSpec.Containers {
+		if reflect.DeepEqual(podSpec.Containers[i].Resources, v1.ResourceRequirements{}) {
+			podSpec.Containers[i].Resources = objectStore.Spec.Gateway.Resources
+		}
+	}
+
+	placement := objectStore.Spec.Gateway.Placement
+	if placement == nil {
+		return
+	}
+	podSpec.NodeSelector = placement.NodeSelector
+	podSpec.Tolerations = placement.Tolerations
+	podSpec.Affinity = placement.Affinity
+	podSpec.TopologySpreadConstraints = placement.TopologySpreadConstraints
+	podSpec.PriorityClassName = placement.PriorityClassName
 }
 
 // chownCephDataDirsInitContainer returns an init container which `chown`s the given data
@@ -247,14 +407,40 @@ func chownCephDataDirsInitContainer(
 func createZoneContainer(objectStore *objectv1alpha1.ObjectStore, endpoint string) v1.Container {
 	return v1.Container{
 		Name:         "object-store-multisite-create-zone",
-		Image:        objectStore.Spec.Image,
+		Image:        gatewayImage(objectStore),
 		Command:      []string{"rgwam-sqlite"},
-		Args:         []string{"zone", "create", fmt.Sprintf("--zone=%s-%s", objectStore.Name, objectStore.Namespace), "--realm-token=$(REALM_TOKEN)", fmt.Sprintf("--endpoints=%s", endpoint)},
+		Args:         createZoneArgs(objectStore, endpoint),
 		VolumeMounts: []v1.VolumeMount{daemonVolumeMountPVC()},
-		Env:          append(DaemonEnvVars(objectStore.Spec.Image), realmTokenSecretEnv(objectStore.Spec.Multisite.RealmTokenSecretName)),
+		Env:          append(DaemonEnvVars(gatewayImage(objectStore)), realmTokenSecretEnv(localRealmTokenSecretName(objectStore))),
 	}
 }
 
+// bootstrapRealmArgs returns the arguments of `rgwam-sqlite realm bootstrap`, the zonegroup is only
+// named when it comes from an ObjectZoneGroup
+func bootstrapRealmArgs(objectStore *objectv1alpha1.ObjectStore, endpoints []string) []string {
+	args := []string{
+		"realm",
+		"bootstrap",
+		fmt.Sprintf("--realm=%s", realmName(objectStore)),
+		fmt.Sprintf("--zone=%s", zoneName(objectStore)),
+		fmt.Sprintf("--endpoints=%s", strings.Join(endpoints, ",")),
+	}
+	if zoneGroup := zoneGroupName(objectStore); zoneGroup != "" {
+		args = append(args, fmt.Sprintf("--zonegroup=%s", zoneGroup))
+	}
+	return args
+}
+
+// createZoneArgs returns the arguments of `rgwam-sqlite zone create`, the zone joins the master
+// zonegroup unless its ObjectZone belongs to another one
+func createZoneArgs(objectStore *objectv1alpha1.ObjectStore, endpoint string) []string {
+	args := []string{"zone", "create", fmt.Sprintf("--zone=%s", zoneName(objectStore)), "--realm-token=$(REALM_TOKEN)", fmt.Sprintf("--endpoints=%s", endpoint)}
+	if zoneGroup := zoneGroupName(objectStore); zoneGroup != "" {
+		args = append(args, fmt.Sprintf("--zonegroup=%s", zoneGroup))
+	}
+	return args
+}
+
 // podSecurityContextPrivileged returns a privileged PodSecurityContext.
 func podSecurityContext() *v1.SecurityContext {
 	var root int64 = 0
@@ -265,102 +451,145 @@ func podSecurityContext() *v1.SecurityContext {
 	}
 }
 
-func (r *ObjectStoreReconciler) waitForLabeledPodsToRunWithRetries(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, retries int) (v1.Pod, error) {
-	const retryInterval = 30
+// getReadyPod returns one of the gateway pods if all of them are ready, it does not wait for
+// them to be ready
+func (r *ObjectStoreReconciler) getReadyPod(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (v1.Pod, bool, error) {
 	labels := getLabels(objectStore.Name)
 	opts := []controllerclient.ListOption{
 		controllerclient.InNamespace(objectStore.Namespace),
 		controllerclient.MatchingLabels(labels),
 	}
 	podList := &v1.PodList{}
-	for i := 0; i < retries; i++ {
-		err := r.Client.List(ctx, podList, opts...)
-		lastStatus := ""
-		running := 0
-		if err == nil && len(podList.Items) > 0 {
-			for _, pod := range podList.Items {
-				if pod.Status.Phase == "Running" {
-					running++
-				}
-				lastStatus = string(pod.Status.Phase)
-			}
-			if running == len(podList.Items) {
-				r.Logger.Info("all pod(s) running", "Pods", len(podList.Items), "label", labels)
-				return podList.Items[0], nil
-			}
+	err := r.Client.List(ctx, podList, opts...)
+	if err != nil {
+		return v1.Pod{}, false, fmt.Errorf("failed to list pods with label %v: %w", labels, err)
+	}
+
+	lastStatus := ""
+	ready := 0
+	pods := []v1.Pod{}
+	for _, pod := range podList.Items {
+		// A pod being deleted is about to be replaced, e.g. after the realm bootstrap
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		pods = append(pods, pod)
+		if isPodReady(&pod) {
+			ready++
 		}
-		r.Logger.Info("waiting for", "pod(s) with label", labels, "status", lastStatus, "running", running, "numPod", len(podList.Items), "Error", err)
-		time.Sleep(retryInterval * time.Second)
+		lastStatus = string(pod.Status.Phase)
+	}
+	if len(pods) > 0 && ready == len(pods) {
+		r.Logger.Info("all pod(s) ready", "Pods", len(pods), "label", labels)
+		return pods[0], true, nil
 	}
 
-	return v1.Pod{}, fmt.Errorf("giving up waiting for pod with label %v to be running", labels)
+	r.Logger.Info("waiting for", "pod(s) with label", labels, "status", lastStatus, "ready", ready, "numPod", len(pods))
+	return v1.Pod{}, false, nil
 }
 
-func (r *ObjectStoreReconciler) waitForJobCompletion(ctx context.Context, job *batchv1.Job, timeout time.Duration) error {
-	r.Logger.Info("waiting for job to complete...", "job", job.Name)
-	return wait.Poll(5*time.Second, timeout, func() (bool, error) {
-		err := r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, job)
-		if err != nil {
-			return false, fmt.Errorf("failed to get job %s. %v", job.Name, err)
+// isPodReady returns whether the pod is running and passes its readiness probe
+func isPodReady(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
 		}
+	}
+	return false
+}
 
-		// if the job is still running, allow it to continue to completion
-		if job.Status.Active > 0 {
-			r.Logger.Info("job is still running", "Status", job.Status)
-			return false, nil
-		}
-		if job.Status.Failed > 0 {
+// jobCompleted returns true if the job succeeded and an error if it failed
+func jobCompleted(job *batchv1.Job) (bool, error) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == v1.ConditionTrue {
 			// TODO: show logs in the op
 			// We need to use client-go for this not the controller-runtime client...
-			return false, fmt.Errorf("job %s failed", job.Name)
+			return false, fmt.Errorf("job %s failed: %s", job.Name, condition.Message)
 		}
-		if job.Status.Succeeded > 0 {
-			return true, nil
-		}
-		r.Logger.Info("job is still initializing")
-		return false, nil
-	})
+	}
+
+	return job.Status.Succeeded > 0, nil
 }
 
 func multisiteJobMeta(objectStore *objectv1alpha1.ObjectStore) *batchv1.Job {
 	return &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "object-store-multisite-zone-job",
+			Name:      fmt.Sprintf("%s-multisite-zone", instanceName(objectStore.Name, objectStore.Namespace)),
 			Namespace: objectStore.Namespace,
 		},
 	}
 }
 
+// realmTokenSecretMeta returns the secret holding the realm token generated by a main site, a
+// secret created under the legacy name is kept
+func realmTokenSecretMeta(objectStore *objectv1alpha1.ObjectStore) *v1.Secret {
+	name := fmt.Sprintf("%s-realm-token", instanceName(objectStore.Name, objectStore.Namespace))
+	if objectStore.Status.RealmTokenSecretName == legacyRealmTokenSecretName {
+		name = legacyRealmTokenSecretName
+	}
+	if objectStore.Spec.HasZoneRef() {
+		name = realmTokenSecretName(realmName(objectStore))
+	}
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: objectStore.Namespace,
+		},
+	}
+}
+
+// zoneJobBackoffLimit is how many times the multisite zone job is retried before it fails
+const zoneJobBackoffLimit = 3
+
+// zoneJobHashAnnotation is set on the multisite zone job with the hash of its inputs
+var zoneJobHashAnnotation = fmt.Sprintf("%s/zone-job-hash", objectv1alpha1.GroupVersion.Group)
+
+// zoneJobHash returns the hash of the inputs of the multisite zone job, a failed job is only
+// created again once it changes
+func zoneJobHash(objectStore *objectv1alpha1.ObjectStore, endpoint, realmToken string) string {
+	return hash(strings.Join(append([]string{gatewayImage(objectStore), realmToken}, createZoneArgs(objectStore, endpoint)...), " "))
+}
+
 // Create multisite zone job
-func (r *ObjectStoreReconciler) createMultisiteZoneJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoint string) error {
+func (r *ObjectStoreReconciler) createMultisiteZoneJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, endpoint, jobHash string) error {
 	job := multisiteJobMeta(objectStore)
-	backoffLimit := int32(600)
+	job.Annotations = map[string]string{zoneJobHashAnnotation: jobHash}
+	// A zone that cannot be created usually fails the same way every time, e.g. with an unreachable
+	// master, the failed pods are kept for their logs
+	backoffLimit := int32(zoneJobBackoffLimit)
 	job.Spec = batchv1.JobSpec{
 		Template: v1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
 				Labels: map[string]string{
-					"app": "object-store-multisite-zone-job",
+					"app": job.Name,
 				},
 			},
 			Spec: v1.PodSpec{
 				Containers: []v1.Container{
 					{
 						Name:         "object-store-multisite-zone-job",
-						Image:        objectStore.Spec.Image,
+						Image:        gatewayImage(objectStore),
 						Command:      []string{"rgwam-sqlite"},
-						Args:         []string{"zone", "create", fmt.Sprintf("--zone=%s-%s", objectStore.Name, objectStore.Namespace), "--realm-token=$(REALM_TOKEN)", fmt.Sprintf("--endpoints=%s", endpoint)},
+						Args:         createZoneArgs(objectStore, endpoint),
 						VolumeMounts: []v1.VolumeMount{daemonVolumeMountPVC()},
-						Env:          append(DaemonEnvVars(objectStore.Spec.Image), realmTokenSecretEnv(objectStore.Spec.Multisite.RealmTokenSecretName)),
+						Env:          append(DaemonEnvVars(gatewayImage(objectStore)), realmTokenSecretEnv(localRealmTokenSecretName(objectStore))),
+						// The end of the output explains the failure in the ObjectStore condition
+						TerminationMessagePolicy: v1.TerminationMessageFallbackToLogsOnError,
 					},
 				},
 				Volumes: []v1.Volume{
 					DaemonVolumesDataPVC(instanceName(objectStore.Name, objectStore.Namespace)),
 				},
-				RestartPolicy: v1.RestartPolicyOnFailure,
+				RestartPolicy: v1.RestartPolicyNever,
 			},
 		},
 		BackoffLimit: &backoffLimit,
 	}
+	// The job mounts the PVC of the gateway, so it must be able to run on the same nodes
+	applyPodPlacement(objectStore, &job.Spec.Template.Spec)
 
 	// Set ObjectStore instance as the owner and controller of the Job.
 	err := controllerutil.SetControllerReference(objectStore, job, r.Scheme)
@@ -370,15 +599,6 @@ func (r *ObjectStoreReconciler) createMultisiteZoneJob(ctx context.Context, obje
 
 	err = r.Client.Create(ctx, job)
 	if err != nil {
-		if kerrors.IsAlreadyExists(err) {
-			// TODO: delete it and create it again?
-			if job.Status.Failed > 0 {
-				// Recreate it
-			} else if job.Status.Succeeded > 0 {
-				r.Logger.Info("Multisite Zone Job", job.Name, "already exists")
-				return nil
-			}
-		}
 		return fmt.Errorf("failed to create multisite zone job: %w", err)
 	}
 
@@ -0,0 +1,110 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestApplyManagedAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		current map[string]string
+		desired map[string]string
+		want    map[string]string
+	}{
+		{
+			name:    "added",
+			desired: map[string]string{"b": "2", "a": "1"},
+			want:    map[string]string{"a": "1", "b": "2", managedAnnotationsAnnotation: "a,b"},
+		},
+		{
+			name:    "removed from the spec",
+			current: map[string]string{"a": "1", "b": "2", "other": "kept", managedAnnotationsAnnotation: "a,b"},
+			desired: map[string]string{"a": "3"},
+			want:    map[string]string{"a": "3", "other": "kept", managedAnnotationsAnnotation: "a"},
+		},
+		{
+			name:    "all removed",
+			current: map[string]string{"a": "1", "other": "kept", managedAnnotationsAnnotation: "a"},
+			want:    map[string]string{"other": "kept"},
+		},
+		{
+			name:    "not managed before",
+			current: map[string]string{"other": "kept"},
+			desired: map[string]string{"other": "taken"},
+			want:    map[string]string{"other": "taken", managedAnnotationsAnnotation: "other"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := applyManagedAnnotations(test.current, test.desired); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("applyManagedAnnotations() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReconcileServiceAnnotations(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "rgw", UID: "uid"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Gateway: objectv1alpha1.GatewaySpec{
+				Port: 8080,
+				Service: &objectv1alpha1.GatewayServiceSpec{
+					Type:        v1.ServiceTypeLoadBalancer,
+					Annotations: map[string]string{"lb/pool": "public", "lb/shared": "true"},
+				},
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()
+	r := &ObjectStoreReconciler{Client: c, Scheme: c.Scheme(), Logger: logr.Discard()}
+
+	service, err := r.reconcileService(context.Background(), objectStore)
+	if err != nil {
+		t.Fatalf("reconcileService() = %v", err)
+	}
+	if service.Annotations["lb/pool"] != "public" || service.Annotations["lb/shared"] != "true" {
+		t.Fatalf("annotations %v, want the ones of the spec", service.Annotations)
+	}
+
+	// An annotation set by another controller survives the removal of the ones of the spec
+	metav1.SetMetaDataAnnotation(&service.ObjectMeta, "other/annotation", "kept")
+	if err := c.Update(context.Background(), service); err != nil {
+		t.Fatalf("failed to update service: %v", err)
+	}
+	delete(objectStore.Spec.Gateway.Service.Annotations, "lb/shared")
+
+	service, err = r.reconcileService(context.Background(), objectStore)
+	if err != nil {
+		t.Fatalf("reconcileService() = %v", err)
+	}
+	want := map[string]string{"lb/pool": "public", "other/annotation": "kept", managedAnnotationsAnnotation: "lb/pool"}
+	if !reflect.DeepEqual(service.Annotations, want) {
+		t.Errorf("annotations %v, want %v", service.Annotations, want)
+	}
+}
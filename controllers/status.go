@@ -0,0 +1,131 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons used in the ObjectStore conditions
+const (
+	reasonReconciled               = "Reconciled"
+	reasonReconcileFailed          = "ReconcileFailed"
+	reasonProgressing              = "Progressing"
+	reasonPVCBound                 = "Bound"
+	reasonPVCPending               = "Pending"
+	reasonPVCResized               = "Resized"
+	reasonPVCResizing              = "Resizing"
+	reasonFileSystemResizePending  = "FileSystemResizePending"
+	reasonExpansionNotSupported    = "ExpansionNotSupported"
+	reasonServiceCreated           = "ServiceCreated"
+	reasonPodsReady                = "PodsReady"
+	reasonZoneCreated              = "ZoneCreated"
+	reasonZoneJobFailed            = "ZoneJobFailed"
+	reasonRealmBootstrapped        = "RealmBootstrapped"
+	reasonAdminUserCreated         = "AdminUserCreated"
+	reasonZonePromoted             = "ZonePromoted"
+	reasonRealmTokenExported       = "RealmTokenExported"
+	reasonRealmTokenExportConflict = "RealmTokenExportConflict"
+	reasonSyncing                  = "Syncing"
+	reasonSyncBehind               = "SyncBehind"
+	reasonSyncFailed               = "SyncFailed"
+	reasonSyncStatusUnknown        = "SyncStatusUnknown"
+	reasonConditionsNotReady       = "ConditionsNotReady"
+	reasonDeleting                 = "Deleting"
+)
+
+// readinessConditions are the conditions that must all be true for the ObjectStore to be Ready.
+// Conditions that do not apply to the ObjectStore (e.g. multisite ones) are simply not set.
+var readinessConditions = []string{
+	objectv1alpha1.ConditionPVCBound,
+	objectv1alpha1.ConditionServiceReady,
+	objectv1alpha1.ConditionDeploymentAvailable,
+	objectv1alpha1.ConditionMultisiteConfigured,
+	objectv1alpha1.ConditionRealmBootstrapped,
+	objectv1alpha1.ConditionPromoted,
+	objectv1alpha1.ConditionRealmTokenExported,
+	objectv1alpha1.ConditionTLSReady,
+	objectv1alpha1.ConditionRestored,
+	objectv1alpha1.ConditionAdminUserReady,
+}
+
+// setCondition adds or updates the condition on the ObjectStore status
+func setCondition(objectStore *objectv1alpha1.ObjectStore, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	setStatusCondition(&objectStore.Status.Conditions, objectStore.Generation, conditionType, status, reason, message)
+}
+
+// setStatusCondition adds or updates a condition in the given list, it is shared by all the kinds
+func setStatusCondition(conditions *[]metav1.Condition, generation int64, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// computeReadiness sets the Ready condition and the phase from the other conditions and the
+// result of the reconcile
+func computeReadiness(objectStore *objectv1alpha1.ObjectStore, reconcileErr error) {
+	if reconcileErr != nil {
+		setCondition(objectStore, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonReconcileFailed, reconcileErr.Error())
+		objectStore.Status.Phase = objectv1alpha1.PhaseFailed
+		return
+	}
+
+	for _, conditionType := range readinessConditions {
+		condition := meta.FindStatusCondition(objectStore.Status.Conditions, conditionType)
+		if condition != nil && condition.Status != metav1.ConditionTrue {
+			setCondition(objectStore, objectv1alpha1.ConditionReady, metav1.ConditionFalse, reasonConditionsNotReady, fmt.Sprintf("condition %q is %s: %s", conditionType, condition.Status, condition.Message))
+			objectStore.Status.Phase = objectv1alpha1.PhaseProgressing
+			return
+		}
+	}
+
+	setCondition(objectStore, objectv1alpha1.ConditionReady, metav1.ConditionTrue, reasonReconciled, "object store is ready")
+	objectStore.Status.Phase = objectv1alpha1.PhaseReady
+}
+
+// phaseFromConditions returns the phase matching the Ready condition
+func phaseFromConditions(conditions []metav1.Condition, reconcileErr error) string {
+	if reconcileErr != nil {
+		return objectv1alpha1.PhaseFailed
+	}
+	if meta.IsStatusConditionTrue(conditions, objectv1alpha1.ConditionReady) {
+		return objectv1alpha1.PhaseReady
+	}
+	return objectv1alpha1.PhaseProgressing
+}
+
+// updateStatus persists the ObjectStore status through the status subresource
+func (r *ObjectStoreReconciler) updateStatus(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, original *objectv1alpha1.ObjectStore) error {
+	objectStore.Status.ObservedGeneration = objectStore.Generation
+
+	err := r.Client.Status().Patch(ctx, objectStore, client.MergeFrom(original))
+	if err != nil {
+		return fmt.Errorf("failed to update status of ObjectStore %q: %w", objectStore.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// syncStatusInterval is how often the sync status of a multisite zone is collected
+	syncStatusInterval = time.Minute
+
+	// syncLagThreshold is the age of the oldest change not yet applied above which the sync is
+	// reported unhealthy, changes are normally applied within seconds
+	syncLagThreshold = 15 * time.Minute
+)
+
+var (
+	syncSourcePattern       = regexp.MustCompile(`^data sync source: (\S+)(?: \((.+)\))?`)
+	syncShardsBehindPattern = regexp.MustCompile(`is behind on (\d+) shards?`)
+	syncOldestChangePattern = regexp.MustCompile(`oldest incremental change not applied: (\S+)`)
+
+	// syncTimeLayouts are the formats of the times printed by `radosgw-admin sync status`
+	syncTimeLayouts = []string{"2006-01-02T15:04:05.999999999-0700", time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z"}
+)
+
+// syncErrorShard is a shard of the output of `radosgw-admin sync error list`
+type syncErrorShard struct {
+	ShardID int               `json:"shard_id"`
+	Entries []json.RawMessage `json:"entries"`
+}
+
+// reconcileSyncStatus collects the replication state of the zone into the status, the SyncHealthy
+// condition and the sync metrics. The sync status is only available from radosgw-admin, the admin
+// ops API has no equivalent. A failure to collect it is reported in the condition and does not fail
+// the reconcile. The status is collected at most once per interval, since its update triggers a
+// reconcile, and it returns when the next collection is due.
+func (r *ObjectStoreReconciler) reconcileSyncStatus(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) time.Duration {
+	now := time.Now()
+	if wait := syncStatusWait(objectStore, now); wait > 0 {
+		return wait
+	}
+
+	output, err := radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "sync", "status")
+	if err != nil {
+		r.Logger.Info("failed to collect sync status", "error", err.Error())
+		setCondition(objectStore, objectv1alpha1.ConditionSyncHealthy, metav1.ConditionUnknown, reasonSyncStatusUnknown, err.Error())
+		recordSyncMetrics(objectStore, nil, false, now)
+		return syncStatusInterval
+	}
+	status := parseSyncStatus(output)
+	status.CheckTime = metav1.NewTime(now)
+
+	// The error log keeps the past failures until it is trimmed, it is reported but does not make
+	// the sync unhealthy
+	output, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "sync", "error", "list")
+	if err != nil {
+		r.Logger.Info("failed to list sync errors", "error", err.Error())
+	} else {
+		count, err := countSyncErrors(output)
+		if err != nil {
+			r.Logger.Info("failed to parse sync errors", "error", err.Error())
+		}
+		status.ErrorCount = count
+	}
+
+	healthy, reason, message := syncHealth(status, now)
+	conditionStatus := metav1.ConditionTrue
+	if !healthy {
+		conditionStatus = metav1.ConditionFalse
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionSyncHealthy, conditionStatus, reason, message)
+
+	// The series of the previous status are deleted before it is replaced
+	recordSyncMetrics(objectStore, status, healthy, now)
+	objectStore.Status.Multisite = status
+
+	return syncStatusInterval
+}
+
+// syncStatusWait returns how long until the sync status of the zone is due, it is due when it was
+// never collected or when it was collected at least an interval ago
+func syncStatusWait(objectStore *objectv1alpha1.ObjectStore, now time.Time) time.Duration {
+	if objectStore.Status.Multisite == nil || objectStore.Status.Multisite.CheckTime.IsZero() {
+		return 0
+	}
+	wait := objectStore.Status.Multisite.CheckTime.Add(syncStatusInterval).Sub(now)
+	if wait > syncStatusInterval {
+		// The check time is in the future, the clock moved back
+		return 0
+	}
+	return wait
+}
+
+// parseSyncStatus reads the output of `radosgw-admin sync status`, e.g.
+//
+//	metadata sync syncing
+//	              full sync: 0/64 shards
+//	              metadata is caught up with master
+//	    data sync source: 7c1d4b2e-... (zone-a)
+//	                      syncing
+//	                      data is behind on 2 shards
+//	                      oldest incremental change not applied: 2022-09-20T09:58:01.123456+0000 [12]
+func parseSyncStatus(output string) *objectv1alpha1.MultisiteStatus {
+	status := &objectv1alpha1.MultisiteStatus{}
+
+	// current is the section the lines apply to, the first line of a data source is its state
+	current := &status.Metadata
+	expectState := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "metadata sync") {
+			current = &status.Metadata
+			current.State = strings.TrimSpace(strings.TrimPrefix(line, "metadata sync"))
+			expectState = false
+			continue
+		}
+		if match := syncSourcePattern.FindStringSubmatch(line); match != nil {
+			source := objectv1alpha1.DataSyncStatus{SourceZone: match[1]}
+			if match[2] != "" {
+				source.SourceZone = match[2]
+			}
+			status.DataSources = append(status.DataSources, source)
+			current = &status.DataSources[len(status.DataSources)-1].SyncStatus
+			expectState = true
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "failed") || strings.HasPrefix(line, "ERROR"):
+			if current.Error == "" {
+				current.Error = line
+			}
+		case strings.Contains(line, "is caught up with"):
+			current.CaughtUp = true
+		case syncShardsBehindPattern.MatchString(line):
+			shards, _ := strconv.ParseInt(syncShardsBehindPattern.FindStringSubmatch(line)[1], 10, 32)
+			current.ShardsBehind = int32(shards)
+		case syncOldestChangePattern.MatchString(line):
+			if oldest, ok := parseSyncTime(syncOldestChangePattern.FindStringSubmatch(line)[1]); ok {
+				current.OldestChange = &oldest
+			}
+		case expectState:
+			current.State = line
+		}
+		expectState = false
+	}
+
+	return status
+}
+
+func parseSyncTime(value string) (metav1.Time, bool) {
+	for _, layout := range syncTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return metav1.NewTime(t), true
+		}
+	}
+	return metav1.Time{}, false
+}
+
+// countSyncErrors returns the number of entries of `radosgw-admin sync error list`
+func countSyncErrors(output string) (int32, error) {
+	shards := []syncErrorShard{}
+	err := json.Unmarshal([]byte(output), &shards)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse sync error list: %w", err)
+	}
+
+	count := 0
+	for _, shard := range shards {
+		count += len(shard.Entries)
+	}
+	return int32(count), nil
+}
+
+// syncHealth returns whether the zone replicates with the other zones of the realm, and the reason
+// and message of the SyncHealthy condition. Being behind is expected while changes are replicated,
+// the sync is only unhealthy once the oldest change waits for more than syncLagThreshold.
+func syncHealth(status *objectv1alpha1.MultisiteStatus, now time.Time) (bool, string, string) {
+	type section struct {
+		name  string
+		state objectv1alpha1.SyncStatus
+	}
+	sections := []section{{name: "metadata sync", state: status.Metadata}}
+	for _, source := range status.DataSources {
+		sections = append(sections, section{name: fmt.Sprintf("data sync from zone %q", source.SourceZone), state: source.SyncStatus})
+	}
+
+	var shardsBehind int32
+	for _, s := range sections {
+		if s.state.Error != "" {
+			return false, reasonSyncFailed, fmt.Sprintf("%s failed: %s", s.name, s.state.Error)
+		}
+		if s.state.OldestChange != nil && now.Sub(s.state.OldestChange.Time) > syncLagThreshold {
+			lag := now.Sub(s.state.OldestChange.Time).Round(time.Second)
+			return false, reasonSyncBehind, fmt.Sprintf("%s is %s behind on %d shards", s.name, lag, s.state.ShardsBehind)
+		}
+		shardsBehind += s.state.ShardsBehind
+	}
+
+	if shardsBehind > 0 {
+		return true, reasonSyncing, fmt.Sprintf("replicating changes on %d shards", shardsBehind)
+	}
+	return true, reasonSyncing, fmt.Sprintf("caught up with %d source zone(s)", len(status.DataSources))
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	masterSyncStatus = `          realm 4a4e5e48-2b41-4c86-9d2b-a4b2f0a1d0b4 (realm-a)
+      zonegroup 5e9c7a8a-0f0c-4bb5-8a43-3c1c1e1f6d2a (zonegroup-a)
+           zone 7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10 (zone-a)
+   current time 2022-09-20T10:00:00Z
+zonegroup features enabled: resharding
+  metadata sync no sync (zone is master)
+      data sync source: 9f2e6a0c-1b7d-4e8a-a0f5-5d3b2c1e4f67 (zone-b)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is caught up with source
+`
+
+	secondarySyncStatus = `          realm 4a4e5e48-2b41-4c86-9d2b-a4b2f0a1d0b4 (realm-a)
+      zonegroup 5e9c7a8a-0f0c-4bb5-8a43-3c1c1e1f6d2a (zonegroup-a)
+           zone 9f2e6a0c-1b7d-4e8a-a0f5-5d3b2c1e4f67 (zone-b)
+   current time 2022-09-20T10:00:00Z
+zonegroup features enabled: resharding
+  metadata sync syncing
+                full sync: 0/64 shards
+                incremental sync: 64/64 shards
+                metadata is caught up with master
+      data sync source: 7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10 (zone-a)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is caught up with source
+`
+
+	behindSyncStatus = `          realm 4a4e5e48-2b41-4c86-9d2b-a4b2f0a1d0b4 (realm-a)
+      zonegroup 5e9c7a8a-0f0c-4bb5-8a43-3c1c1e1f6d2a (zonegroup-a)
+           zone 9f2e6a0c-1b7d-4e8a-a0f5-5d3b2c1e4f67 (zone-b)
+  metadata sync syncing
+                full sync: 0/64 shards
+                incremental sync: 64/64 shards
+                metadata is behind on 1 shard
+                behind shards: [31]
+                oldest incremental change not applied: 2022-09-20T09:58:01.123456+0000 [31]
+      data sync source: 7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10 (zone-a)
+                        syncing
+                        full sync: 0/128 shards
+                        incremental sync: 128/128 shards
+                        data is behind on 2 shards
+                        behind shards: [12,87]
+                        oldest incremental change not applied: 2022-09-20T09:40:01.000000+0000 [12]
+`
+
+	errorSyncStatus = `          realm 4a4e5e48-2b41-4c86-9d2b-a4b2f0a1d0b4 (realm-a)
+      zonegroup 5e9c7a8a-0f0c-4bb5-8a43-3c1c1e1f6d2a (zonegroup-a)
+           zone 9f2e6a0c-1b7d-4e8a-a0f5-5d3b2c1e4f67 (zone-b)
+  metadata sync syncing
+                full sync: 0/64 shards
+                failed to fetch master sync status: (5) Input/output error
+      data sync source: 7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10 (zone-a)
+                        failed to retrieve sync info: (5) Input/output error
+`
+)
+
+// syncTime returns the time of the sync status output in UTC, to compare it with reflect.DeepEqual
+func syncTime(value string) *metav1.Time {
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return &metav1.Time{Time: t.UTC()}
+}
+
+func TestParseSyncStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   *objectv1alpha1.MultisiteStatus
+	}{
+		{
+			name:   "master",
+			output: masterSyncStatus,
+			want: &objectv1alpha1.MultisiteStatus{
+				Metadata: objectv1alpha1.SyncStatus{State: "no sync (zone is master)"},
+				DataSources: []objectv1alpha1.DataSyncStatus{
+					{SourceZone: "zone-b", SyncStatus: objectv1alpha1.SyncStatus{State: "syncing", CaughtUp: true}},
+				},
+			},
+		},
+		{
+			name:   "secondary",
+			output: secondarySyncStatus,
+			want: &objectv1alpha1.MultisiteStatus{
+				Metadata: objectv1alpha1.SyncStatus{State: "syncing", CaughtUp: true},
+				DataSources: []objectv1alpha1.DataSyncStatus{
+					{SourceZone: "zone-a", SyncStatus: objectv1alpha1.SyncStatus{State: "syncing", CaughtUp: true}},
+				},
+			},
+		},
+		{
+			name:   "behind",
+			output: behindSyncStatus,
+			want: &objectv1alpha1.MultisiteStatus{
+				Metadata: objectv1alpha1.SyncStatus{
+					State:        "syncing",
+					ShardsBehind: 1,
+					OldestChange: syncTime("2022-09-20T09:58:01.123456Z"),
+				},
+				DataSources: []objectv1alpha1.DataSyncStatus{
+					{SourceZone: "zone-a", SyncStatus: objectv1alpha1.SyncStatus{
+						State:        "syncing",
+						ShardsBehind: 2,
+						OldestChange: syncTime("2022-09-20T09:40:01Z"),
+					}},
+				},
+			},
+		},
+		{
+			name:   "error",
+			output: errorSyncStatus,
+			want: &objectv1alpha1.MultisiteStatus{
+				Metadata: objectv1alpha1.SyncStatus{State: "syncing", Error: "failed to fetch master sync status: (5) Input/output error"},
+				DataSources: []objectv1alpha1.DataSyncStatus{
+					{SourceZone: "zone-a", SyncStatus: objectv1alpha1.SyncStatus{Error: "failed to retrieve sync info: (5) Input/output error"}},
+				},
+			},
+		},
+		{
+			name:   "source without name",
+			output: "  metadata sync syncing\n      data sync source: 7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10\n                        syncing\n",
+			want: &objectv1alpha1.MultisiteStatus{
+				Metadata: objectv1alpha1.SyncStatus{State: "syncing"},
+				DataSources: []objectv1alpha1.DataSyncStatus{
+					{SourceZone: "7c1d4b2e-9a51-4d0c-bb8e-0d4c6f3e2a10", SyncStatus: objectv1alpha1.SyncStatus{State: "syncing"}},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseSyncStatus(test.output)
+			for _, s := range append([]*objectv1alpha1.SyncStatus{&got.Metadata}, dataSyncStatuses(got)...) {
+				if s.OldestChange != nil {
+					s.OldestChange.Time = s.OldestChange.Time.UTC()
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSyncStatus() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func dataSyncStatuses(status *objectv1alpha1.MultisiteStatus) []*objectv1alpha1.SyncStatus {
+	statuses := []*objectv1alpha1.SyncStatus{}
+	for i := range status.DataSources {
+		statuses = append(statuses, &status.DataSources[i].SyncStatus)
+	}
+	return statuses
+}
+
+func TestSyncHealth(t *testing.T) {
+	now := time.Date(2022, 9, 20, 10, 0, 0, 0, time.UTC)
+	behind := func(age time.Duration) *objectv1alpha1.MultisiteStatus {
+		oldest := metav1.NewTime(now.Add(-age))
+		return &objectv1alpha1.MultisiteStatus{
+			Metadata: objectv1alpha1.SyncStatus{State: "syncing", CaughtUp: true},
+			DataSources: []objectv1alpha1.DataSyncStatus{
+				{SourceZone: "zone-a", SyncStatus: objectv1alpha1.SyncStatus{State: "syncing", ShardsBehind: 2, OldestChange: &oldest}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		status      *objectv1alpha1.MultisiteStatus
+		wantHealthy bool
+		wantReason  string
+		wantMessage string
+	}{
+		{
+			name:        "master",
+			status:      parseSyncStatus(masterSyncStatus),
+			wantHealthy: true,
+			wantReason:  reasonSyncing,
+			wantMessage: "caught up with 1 source zone(s)",
+		},
+		{
+			name:        "behind under the lag threshold",
+			status:      behind(syncLagThreshold - time.Minute),
+			wantHealthy: true,
+			wantReason:  reasonSyncing,
+			wantMessage: "replicating changes on 2 shards",
+		},
+		{
+			name:        "behind over the lag threshold",
+			status:      behind(syncLagThreshold + time.Minute),
+			wantHealthy: false,
+			wantReason:  reasonSyncBehind,
+			wantMessage: `data sync from zone "zone-a" is 16m0s behind on 2 shards`,
+		},
+		{
+			name:        "metadata under and data over the lag threshold",
+			status:      parseSyncStatus(behindSyncStatus),
+			wantHealthy: false,
+			wantReason:  reasonSyncBehind,
+			wantMessage: `data sync from zone "zone-a" is 19m59s behind on 2 shards`,
+		},
+		{
+			name:        "error",
+			status:      parseSyncStatus(errorSyncStatus),
+			wantHealthy: false,
+			wantReason:  reasonSyncFailed,
+			wantMessage: "metadata sync failed: failed to fetch master sync status: (5) Input/output error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			healthy, reason, message := syncHealth(test.status, now)
+			if healthy != test.wantHealthy || reason != test.wantReason || message != test.wantMessage {
+				t.Errorf("syncHealth() = %v, %q, %q, want %v, %q, %q", healthy, reason, message, test.wantHealthy, test.wantReason, test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestSyncStatusWait(t *testing.T) {
+	now := time.Date(2022, 9, 20, 10, 0, 0, 0, time.UTC)
+	checked := func(ago time.Duration) *objectv1alpha1.MultisiteStatus {
+		return &objectv1alpha1.MultisiteStatus{CheckTime: metav1.NewTime(now.Add(-ago))}
+	}
+
+	tests := []struct {
+		name   string
+		status *objectv1alpha1.MultisiteStatus
+		want   time.Duration
+	}{
+		{name: "never collected"},
+		{name: "never checked", status: &objectv1alpha1.MultisiteStatus{}},
+		{name: "checked recently", status: checked(20 * time.Second), want: syncStatusInterval - 20*time.Second},
+		{name: "checked an interval ago", status: checked(syncStatusInterval)},
+		{name: "checked long ago", status: checked(time.Hour), want: -time.Hour + syncStatusInterval},
+		{name: "checked in the future", status: checked(-time.Hour)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objectStore := &objectv1alpha1.ObjectStore{}
+			objectStore.Status.Multisite = test.status
+			if got := syncStatusWait(objectStore, now); got != test.want {
+				t.Errorf("syncStatusWait() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReconcileSyncStatusRecentCheck(t *testing.T) {
+	objectStore := &objectv1alpha1.ObjectStore{}
+	status := &objectv1alpha1.MultisiteStatus{CheckTime: metav1.NewTime(time.Now())}
+	objectStore.Status.Multisite = status
+
+	// No command can run without an executor, a collection would panic
+	r := &ObjectStoreReconciler{Logger: logr.Discard()}
+	wait := r.reconcileSyncStatus(context.Background(), objectStore)
+	if wait <= 0 || wait > syncStatusInterval {
+		t.Errorf("reconcileSyncStatus() = %v, want the time left of the interval", wait)
+	}
+	if objectStore.Status.Multisite != status || len(objectStore.Status.Conditions) > 0 {
+		t.Errorf("status was modified: %+v", objectStore.Status)
+	}
+}
+
+func TestCountSyncErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    int32
+		wantErr bool
+	}{
+		{
+			name:   "no error",
+			output: `[{"shard_id":0,"entries":[]},{"shard_id":1,"entries":[]}]`,
+			want:   0,
+		},
+		{
+			name: "errors",
+			output: `[{"shard_id":0,"entries":[{"id":"1_1663667881.123456_5.1","section":"data","name":"bucket:7c1d4b2e.4137.1",` +
+				`"timestamp":"2022-09-20T09:58:01.123456Z","info":{"source_zone":"7c1d4b2e","error_code":5,"message":"failed to sync bucket instance: (5) Input/output error"}}]},` +
+				`{"shard_id":1,"entries":[{"id":"1_1663667882.1_6.1"},{"id":"1_1663667883.1_7.1"}]}]`,
+			want: 3,
+		},
+		{
+			name:    "invalid output",
+			output:  "ERROR: failed to list sync errors",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := countSyncErrors(test.output)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("countSyncErrors() error = %v, want error %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("countSyncErrors() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
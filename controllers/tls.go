@@ -0,0 +1,231 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// tlsCertificateDirectory is where the certificate of the gateway is mounted
+	tlsCertificateDirectory = "/etc/ceph/private"
+	tlsVolumeName           = "rgw-tls"
+
+	reasonCertificateAvailable = "CertificateAvailable"
+	reasonCertificatePending   = "CertificatePending"
+)
+
+// certificateGVK is the cert-manager Certificate, it is handled as unstructured so that
+// cert-manager is only needed when it is used
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// reconcileTLS requests the certificate from cert-manager if needed and checks that its Secret
+// exists, it returns false while the certificate is not available since the gateway cannot start
+// without it
+func (r *ObjectStoreReconciler) reconcileTLS(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, serviceIP string, endpoints []string) (bool, error) {
+	if !objectStore.Spec.IsTLSEnabled() {
+		return true, nil
+	}
+
+	if objectStore.Spec.Gateway.TLS.IssuerRef != nil {
+		err := r.reconcileCertificate(ctx, objectStore, serviceIP, endpoints)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	secret := &v1.Secret{}
+	err := r.Client.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: tlsSecretName(objectStore)}, secret)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			setCondition(objectStore, objectv1alpha1.ConditionTLSReady, metav1.ConditionFalse, reasonCertificatePending, fmt.Sprintf("waiting for TLS secret %q", tlsSecretName(objectStore)))
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get TLS secret %q: %w", tlsSecretName(objectStore), err)
+	}
+	if len(secret.Data[v1.TLSCertKey]) == 0 || len(secret.Data[v1.TLSPrivateKeyKey]) == 0 {
+		setCondition(objectStore, objectv1alpha1.ConditionTLSReady, metav1.ConditionFalse, reasonCertificatePending, fmt.Sprintf("TLS secret %q has no %s or %s", secret.Name, v1.TLSCertKey, v1.TLSPrivateKeyKey))
+		return false, nil
+	}
+
+	setCondition(objectStore, objectv1alpha1.ConditionTLSReady, metav1.ConditionTrue, reasonCertificateAvailable, fmt.Sprintf("certificate is available in secret %q", secret.Name))
+	return true, nil
+}
+
+// reconcileCertificate creates the cert-manager Certificate of the gateway service, it is valid for
+// the names of the service and the hosts of the advertised endpoints
+func (r *ObjectStoreReconciler) reconcileCertificate(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, serviceIP string, endpoints []string) error {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetName(instanceName(objectStore.Name, objectStore.Namespace))
+	certificate.SetNamespace(objectStore.Namespace)
+
+	err := controllerutil.SetControllerReference(objectStore, certificate, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to certificate %q: %w", certificate.GetName(), err)
+	}
+
+	tlsSpec := objectStore.Spec.Gateway.TLS
+	serviceName := instanceName(objectStore.Name, objectStore.Namespace)
+	dnsNames := []interface{}{
+		serviceName,
+		fmt.Sprintf("%s.%s", serviceName, objectStore.Namespace),
+		serviceHost(objectStore),
+		fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, objectStore.Namespace),
+	}
+	for _, name := range tlsSpec.DNSNames {
+		dnsNames = append(dnsNames, name)
+	}
+	ipAddresses := []interface{}{}
+	if serviceIP != "" {
+		ipAddresses = append(ipAddresses, serviceIP)
+	}
+	for _, endpoint := range endpoints {
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil || endpointURL.Hostname() == "" || endpointURL.Hostname() == serviceIP {
+			continue
+		}
+		if net.ParseIP(endpointURL.Hostname()) != nil {
+			ipAddresses = append(ipAddresses, endpointURL.Hostname())
+		} else {
+			dnsNames = append(dnsNames, endpointURL.Hostname())
+		}
+	}
+
+	issuerKind := tlsSpec.IssuerRef.Kind
+	if issuerKind == "" {
+		issuerKind = "Issuer"
+	}
+	issuerGroup := tlsSpec.IssuerRef.Group
+	if issuerGroup == "" {
+		issuerGroup = certificateGVK.Group
+	}
+
+	mutateFunc := func() error {
+		spec := map[string]interface{}{
+			"secretName": tlsSecretName(objectStore),
+			"dnsNames":   dnsNames,
+			"issuerRef": map[string]interface{}{
+				"name":  tlsSpec.IssuerRef.Name,
+				"kind":  issuerKind,
+				"group": issuerGroup,
+			},
+		}
+		if len(ipAddresses) > 0 {
+			spec["ipAddresses"] = ipAddresses
+		}
+		return unstructured.SetNestedField(certificate.Object, spec, "spec")
+	}
+
+	opResult, err := controllerutil.CreateOrUpdate(ctx, r.Client, certificate, mutateFunc)
+	if err != nil {
+		return fmt.Errorf("failed to create or update certificate %q: %w", certificate.GetName(), err)
+	}
+	if opResult != controllerutil.OperationResultNone {
+		r.Logger.Info("gateway certificate", "Certificate", certificate.GetName(), "opResult", opResult)
+	}
+
+	return nil
+}
+
+// tlsSecretName returns the name of the Secret holding the certificate of the gateway
+func tlsSecretName(objectStore *objectv1alpha1.ObjectStore) string {
+	if objectStore.Spec.Gateway.TLS.SecretName != "" {
+		return objectStore.Spec.Gateway.TLS.SecretName
+	}
+	return fmt.Sprintf("%s-tls", instanceName(objectStore.Name, objectStore.Namespace))
+}
+
+// rgwFrontends returns the beast frontend configuration serving both http and https
+func rgwFrontends() string {
+	return fmt.Sprintf("beast port=%d ssl_port=%d ssl_certificate=%s/%s ssl_private_key=%s/%s",
+		rgwPortInternalPort,
+		rgwSecurePortInternalPort,
+		tlsCertificateDirectory, v1.TLSCertKey,
+		tlsCertificateDirectory, v1.TLSPrivateKeyKey,
+	)
+}
+
+func tlsVolume(objectStore *objectv1alpha1.ObjectStore) v1.Volume {
+	mode := int32(0440)
+	return v1.Volume{
+		Name: tlsVolumeName,
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{
+				SecretName: tlsSecretName(objectStore),
+				Items: []v1.KeyToPath{
+					{Key: v1.TLSCertKey, Path: v1.TLSCertKey},
+					{Key: v1.TLSPrivateKeyKey, Path: v1.TLSPrivateKeyKey},
+				},
+				DefaultMode: &mode,
+			},
+		},
+	}
+}
+
+func tlsVolumeMount() v1.VolumeMount {
+	return v1.VolumeMount{
+		Name:      tlsVolumeName,
+		MountPath: tlsCertificateDirectory,
+		ReadOnly:  true,
+	}
+}
+
+// gatewayHTTPClient returns an HTTP client for the endpoint of the ObjectStore. When TLS is
+// enabled, the CA of the certificate Secret is trusted on top of the system ones.
+func gatewayHTTPClient(ctx context.Context, c client.Client, objectStore *objectv1alpha1.ObjectStore) (*http.Client, error) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	if !objectStore.Spec.IsTLSEnabled() {
+		return httpClient, nil
+	}
+
+	secret := &v1.Secret{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: objectStore.Namespace, Name: tlsSecretName(objectStore)}, secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get TLS secret %q: %w", tlsSecretName(objectStore), err)
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if ca := secret.Data["ca.crt"]; len(ca) > 0 {
+		rootCAs.AppendCertsFromPEM(ca)
+	}
+	httpClient.Transport = &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+	}
+
+	return httpClient, nil
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// zoneTopology is an ObjectZone with its ObjectZoneGroup and ObjectRealm
+type zoneTopology struct {
+	zone      *objectv1alpha1.ObjectZone
+	zoneGroup *objectv1alpha1.ObjectZoneGroup
+	realm     *objectv1alpha1.ObjectRealm
+}
+
+// isRealmMaster returns whether the zone is the master zone of the master zonegroup, which
+// bootstraps the realm
+func (t *zoneTopology) isRealmMaster() bool {
+	return t.zone.Spec.Master && t.zoneGroup.Spec.Master
+}
+
+// zonegroupInfo is the part of the output of `radosgw-admin zonegroup get` read by the operator
+type zonegroupInfo struct {
+	MasterZone string `json:"master_zone"`
+	Zones      []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"zones"`
+}
+
+// getZoneTopology returns the ObjectZone, its ObjectZoneGroup and its ObjectRealm, they all live in
+// the namespace of the ObjectStore
+func getZoneTopology(ctx context.Context, c client.Client, namespace, zoneRef string) (*zoneTopology, error) {
+	zone := &objectv1alpha1.ObjectZone{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: zoneRef}, zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ObjectZone %q: %w", zoneRef, err)
+	}
+
+	zoneGroup := &objectv1alpha1.ObjectZoneGroup{}
+	err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: zone.Spec.ZoneGroupRef}, zoneGroup)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ObjectZoneGroup %q of zone %q: %w", zone.Spec.ZoneGroupRef, zone.Name, err)
+	}
+
+	realm := &objectv1alpha1.ObjectRealm{}
+	err = c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: zoneGroup.Spec.RealmRef}, realm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ObjectRealm %q of zonegroup %q: %w", zoneGroup.Spec.RealmRef, zoneGroup.Name, err)
+	}
+
+	return &zoneTopology{zone: zone, zoneGroup: zoneGroup, realm: realm}, nil
+}
+
+// resolveZone reads the topology of an ObjectStore referencing an ObjectZone. The names are kept in
+// the status, and the role of the site is set in the multisite spec in memory only, so that the
+// rest of the reconcile handles it like a main or secondary site. It returns nil when the ObjectStore
+// does not reference an ObjectZone.
+func (r *ObjectStoreReconciler) resolveZone(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (*zoneTopology, error) {
+	if !objectStore.Spec.HasZoneRef() {
+		return nil, nil
+	}
+
+	topology, err := getZoneTopology(ctx, r.Client, objectStore.Namespace, objectStore.Spec.Multisite.ZoneRef)
+	if err != nil {
+		return nil, err
+	}
+
+	objectStore.Status.Realm = topology.realm.Name
+	objectStore.Status.ZoneGroup = topology.zoneGroup.Name
+	objectStore.Status.Zone = topology.zone.Name
+	if topology.isRealmMaster() {
+		objectStore.Spec.Multisite.IsMainSite = true
+	} else {
+		objectStore.Spec.Multisite.RealmTokenSecretName = realmTokenSecretName(topology.realm.Name)
+	}
+
+	return topology, nil
+}
+
+// reconcileZoneGroups creates the zonegroups of the realm other than the master one, it runs on the
+// master zone of the realm since the period can only be changed there
+func (r *ObjectStoreReconciler) reconcileZoneGroups(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	zoneGroups := &objectv1alpha1.ObjectZoneGroupList{}
+	err := r.Client.List(ctx, zoneGroups, client.InNamespace(objectStore.Namespace))
+	if err != nil {
+		return fmt.Errorf("failed to list ObjectZoneGroups: %w", err)
+	}
+
+	created := false
+	for _, zoneGroup := range zoneGroups.Items {
+		if zoneGroup.Spec.RealmRef != realmName(objectStore) || zoneGroup.Spec.Master {
+			continue
+		}
+
+		_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "zonegroup", "get", fmt.Sprintf("--rgw-zonegroup=%s", zoneGroup.Name))
+		if err == nil {
+			continue
+		}
+		if !isAdminNotFound(err) {
+			return err
+		}
+
+		_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "zonegroup", "create",
+			fmt.Sprintf("--rgw-zonegroup=%s", zoneGroup.Name),
+			fmt.Sprintf("--rgw-realm=%s", realmName(objectStore)),
+		)
+		if err != nil {
+			return err
+		}
+		r.Logger.Info("created zonegroup", "ZoneGroup", zoneGroup.Name, "Realm", realmName(objectStore))
+		created = true
+	}
+
+	// The new zonegroups are only visible to the other zones once the period is committed
+	if created {
+		_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "period", "update", "--commit")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileZoneGroupMaster makes the zone of a secondary site the master of its zonegroup when its
+// ObjectZone is. The period commit is forwarded to the master zone of the realm.
+func (r *ObjectStoreReconciler) reconcileZoneGroupMaster(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, topology *zoneTopology) error {
+	if !topology.zone.Spec.Master || topology.zoneGroup.Spec.Master {
+		return nil
+	}
+
+	output, err := radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "zonegroup", "get", fmt.Sprintf("--rgw-zonegroup=%s", zoneGroupName(objectStore)))
+	if err != nil {
+		return err
+	}
+	info := &zonegroupInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		return fmt.Errorf("failed to parse zonegroup %q: %w", zoneGroupName(objectStore), err)
+	}
+	for _, zone := range info.Zones {
+		if zone.Name == zoneName(objectStore) && zone.ID == info.MasterZone {
+			return nil
+		}
+	}
+
+	tokenInfo, err := r.getRealmToken(ctx, objectStore)
+	if err != nil {
+		return err
+	}
+
+	_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "zone", "modify",
+		fmt.Sprintf("--rgw-zonegroup=%s", zoneGroupName(objectStore)),
+		fmt.Sprintf("--rgw-zone=%s", zoneName(objectStore)),
+		"--master",
+	)
+	if err != nil {
+		return err
+	}
+	_, err = radosgwAdmin(ctx, r.RemotePodCommandExecutor, objectStore, "period", "update", "--commit",
+		fmt.Sprintf("--url=%s", tokenInfo.Endpoint),
+		fmt.Sprintf("--access-key=%s", tokenInfo.AccessKey),
+		fmt.Sprintf("--secret=%s", tokenInfo.SecretKey),
+	)
+	if err != nil {
+		return err
+	}
+	r.Logger.Info("zone is the master of its zonegroup", "Zone", zoneName(objectStore), "ZoneGroup", zoneGroupName(objectStore))
+
+	return nil
+}
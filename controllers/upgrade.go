@@ -0,0 +1,355 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	controllerutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// upgradeDataDirectory is where the PVC is mounted in the upgrade jobs, the backup is kept next
+	// to the data so that it does not need a volume of its own
+	upgradeDataDirectory   = "/var/lib/ceph/radosgw/pvc"
+	upgradeBackupDirectory = ".upgrade-backup"
+
+	defaultUpgradeTimeout = 15 * time.Minute
+
+	reasonUpgradeInProgress = "UpgradeInProgress"
+	reasonUpgradeSucceeded  = "UpgradeSucceeded"
+	reasonUpgradeFailed     = "UpgradeFailed"
+	reasonDowngradeRefused  = "DowngradeRefused"
+)
+
+// The gateway is stopped while the scripts run, so that the copy of the SQLite database is consistent
+var (
+	upgradeBackupScript = fmt.Sprintf(`set -e
+rm -rf %[1]s/%[2]s
+mkdir %[1]s/%[2]s
+find %[1]s -mindepth 1 -maxdepth 1 ! -name %[2]s -exec cp -a {} %[1]s/%[2]s/ \;
+`, upgradeDataDirectory, upgradeBackupDirectory)
+
+	upgradeRestoreScript = fmt.Sprintf(`set -e
+test -d %[1]s/%[2]s
+find %[1]s -mindepth 1 -maxdepth 1 ! -name %[2]s -exec rm -rf {} +
+cp -a %[1]s/%[2]s/. %[1]s/
+rm -rf %[1]s/%[2]s
+`, upgradeDataDirectory, upgradeBackupDirectory)
+)
+
+// gatewayImage returns the image the gateway runs, the image of the spec is only deployed once the
+// data has been copied
+func gatewayImage(objectStore *objectv1alpha1.ObjectStore) string {
+	if upgrade := objectStore.Status.Upgrade; upgrade != nil {
+		if upgrade.Phase == objectv1alpha1.UpgradePhaseRollout {
+			return upgrade.ToImage
+		}
+		return upgrade.FromImage
+	}
+	if objectStore.Status.Image != "" {
+		return objectStore.Status.Image
+	}
+	return objectStore.Spec.Image
+}
+
+// gatewayReplicas returns the number of gateway pods, the gateway is stopped while its data is copied
+// or a snapshot of its volume is taken
+func gatewayReplicas(objectStore *objectv1alpha1.ObjectStore) int32 {
+	if quiescedBy(objectStore) != "" {
+		return 0
+	}
+	if upgrade := objectStore.Status.Upgrade; upgrade != nil && upgrade.Phase != objectv1alpha1.UpgradePhaseRollout {
+		return 0
+	}
+	return 1
+}
+
+// reconcileUpgrade moves the upgrade of the gateway forward when the image of the spec changes:
+// the gateway is stopped, its data copied, the migration command run, and the new image started.
+// The copy is restored and the previous image started again when any step fails. It returns true
+// while the gateway is stopped.
+func (r *ObjectStoreReconciler) reconcileUpgrade(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) (bool, error) {
+	status := &objectStore.Status
+	if status.Image == "" {
+		// First deployment, or created by a version of the operator that did not track the image
+		setRunningImage(objectStore, objectStore.Spec.Image)
+	}
+
+	if status.Upgrade == nil {
+		if objectStore.Spec.Image == status.Image || objectStore.Spec.Image == status.FailedImage {
+			if condition := meta.FindStatusCondition(status.Conditions, objectv1alpha1.ConditionUpgraded); condition != nil && condition.Reason == reasonDowngradeRefused {
+				meta.RemoveStatusCondition(&status.Conditions, objectv1alpha1.ConditionUpgraded)
+			}
+			return false, nil
+		}
+		// The webhook refuses downgrades but it may not be deployed
+		if objectv1alpha1.IsImageDowngrade(status.Image, objectStore.Spec.Image) {
+			setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionFalse, reasonDowngradeRefused,
+				fmt.Sprintf("downgrading from %q to %q is not supported, the gateway keeps running %q", status.Image, objectStore.Spec.Image, status.Image))
+			return false, nil
+		}
+		// The snapshot would capture the data in the middle of the copy
+		if snapshotName := quiescedBy(objectStore); snapshotName != "" {
+			r.Logger.Info("waiting for the snapshot to finish before upgrading", "ObjectStoreSnapshot", snapshotName)
+			return false, nil
+		}
+
+		r.Logger.Info("starting upgrade", "from", status.Image, "to", objectStore.Spec.Image)
+		status.FailedImage = ""
+		status.Upgrade = &objectv1alpha1.UpgradeStatus{
+			FromImage: status.Image,
+			ToImage:   objectStore.Spec.Image,
+		}
+		setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseBackup, "")
+	}
+
+	upgrade := status.Upgrade
+	switch upgrade.Phase {
+	case objectv1alpha1.UpgradePhaseBackup:
+		done, err := r.runUpgradeJob(ctx, objectStore, "backup", upgrade.FromImage, []string{"sh", "-c", upgradeBackupScript})
+		if err != nil {
+			// Nothing was changed yet, start the previous image again
+			r.Logger.Error(err, "failed to copy the data, cancelling upgrade", "to", upgrade.ToImage)
+			status.Upgrade = nil
+			status.FailedImage = upgrade.ToImage
+			setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionFalse, reasonUpgradeFailed,
+				fmt.Sprintf("upgrade to %q cancelled, failed to copy the data: %v", upgrade.ToImage, err))
+			return false, nil
+		}
+		if !done {
+			return true, nil
+		}
+		if objectStore.Spec.Upgrade != nil && len(objectStore.Spec.Upgrade.MigrationCommand) > 0 {
+			setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseMigrate, "")
+			return true, nil
+		}
+		setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseRollout, "")
+		return false, nil
+
+	case objectv1alpha1.UpgradePhaseMigrate:
+		if objectStore.Spec.Upgrade == nil || len(objectStore.Spec.Upgrade.MigrationCommand) == 0 {
+			setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseRollout, "")
+			return false, nil
+		}
+		done, err := r.runUpgradeJob(ctx, objectStore, "migrate", upgrade.ToImage, objectStore.Spec.Upgrade.MigrationCommand)
+		if err != nil {
+			setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseRollback, fmt.Sprintf("migration failed: %v", err))
+			return true, nil
+		}
+		if !done {
+			return true, nil
+		}
+		setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseRollout, "")
+		return false, nil
+
+	case objectv1alpha1.UpgradePhaseRollout:
+		if time.Since(upgrade.PhaseStartTime.Time) > upgradeTimeout(objectStore) {
+			setUpgradePhase(objectStore, objectv1alpha1.UpgradePhaseRollback,
+				fmt.Sprintf("gateway was not ready after %s", upgradeTimeout(objectStore)))
+			return true, nil
+		}
+		return false, nil
+
+	case objectv1alpha1.UpgradePhaseRollback:
+		done, err := r.runUpgradeJob(ctx, objectStore, "rollback", upgrade.FromImage, []string{"sh", "-c", upgradeRestoreScript})
+		if err != nil {
+			// Starting the previous image on data that may have been migrated could corrupt it further
+			setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionFalse, reasonUpgradeFailed,
+				fmt.Sprintf("upgrade to %q failed (%s) and the data could not be restored from %s: %v", upgrade.ToImage, upgrade.Reason, upgradeBackupDirectory, err))
+			return true, err
+		}
+		if !done {
+			return true, nil
+		}
+
+		r.Logger.Info("upgrade rolled back", "from", upgrade.ToImage, "to", upgrade.FromImage, "reason", upgrade.Reason)
+		status.Upgrade = nil
+		status.FailedImage = upgrade.ToImage
+		setRunningImage(objectStore, upgrade.FromImage)
+		setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionFalse, reasonUpgradeFailed,
+			fmt.Sprintf("upgrade to %q failed and was rolled back to %q: %s", upgrade.ToImage, upgrade.FromImage, upgrade.Reason))
+		return false, nil
+	}
+
+	return false, nil
+}
+
+// completeUpgrade records the new image once the gateway is ready and removes the copy of the data,
+// it would otherwise double the usage of the PVC and end up in its snapshots
+func (r *ObjectStoreReconciler) completeUpgrade(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) {
+	upgrade := objectStore.Status.Upgrade
+	if upgrade == nil || upgrade.Phase != objectv1alpha1.UpgradePhaseRollout {
+		return
+	}
+
+	r.Logger.Info("upgrade succeeded", "from", upgrade.FromImage, "to", upgrade.ToImage)
+	objectStore.Status.Upgrade = nil
+	setRunningImage(objectStore, upgrade.ToImage)
+	message := fmt.Sprintf("upgraded from %q to %q", upgrade.FromImage, upgrade.ToImage)
+
+	// The gateway already runs the new image, a copy that cannot be removed is only reported
+	err := r.removeUpgradeBackup(ctx, objectStore)
+	if err != nil {
+		r.Logger.Error(err, "failed to remove the copy of the data made for the upgrade")
+		message = fmt.Sprintf("%s, the copy of the data in %s could not be removed: %v", message, upgradeBackupDirectory, err)
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionTrue, reasonUpgradeSucceeded, message)
+}
+
+// removeUpgradeBackup removes the copy of the data from the volume of the running gateway
+func (r *ObjectStoreReconciler) removeUpgradeBackup(ctx context.Context, objectStore *objectv1alpha1.ObjectStore) error {
+	_, stderr, err := r.RemotePodCommandExecutor.ExecCommandInContainerWithFullOutputWithTimeout(
+		ctx,
+		getLabelString(objectStore.Name),
+		"rgw",
+		objectStore.Namespace,
+		"rm", "-rf", fmt.Sprintf("%s/%s", objectStoreDataDirectory, upgradeBackupDirectory),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove %s: %s: %w", upgradeBackupDirectory, lastLine(stderr), err)
+	}
+
+	r.Logger.Info("removed the copy of the data made for the upgrade", "directory", upgradeBackupDirectory)
+	return nil
+}
+
+// runUpgradeJob runs a step of the upgrade on the PVC once the gateway pods are gone, it returns
+// true once the job succeeded and deletes it
+func (r *ObjectStoreReconciler) runUpgradeJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, step, image string, command []string) (bool, error) {
+	// The PVC may be ReadWriteOnce and the database must not change during the copy
+	pods := &v1.PodList{}
+	err := r.Client.List(ctx, pods, client.InNamespace(objectStore.Namespace), client.MatchingLabels(getLabels(objectStore.Name)))
+	if err != nil {
+		return false, fmt.Errorf("failed to list gateway pods: %w", err)
+	}
+	if len(pods.Items) > 0 {
+		r.Logger.Info("waiting for the gateway to stop before upgrade step", "step", step, "Pods", len(pods.Items))
+		return false, nil
+	}
+
+	job := upgradeJobMeta(objectStore, step)
+	err = r.Client.Get(ctx, client.ObjectKeyFromObject(job), job)
+	if err != nil {
+		if !kerrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get upgrade job %q: %w", job.Name, err)
+		}
+
+		err = r.createUpgradeJob(ctx, objectStore, job, image, command)
+		if err != nil {
+			return false, err
+		}
+		r.Logger.Info("created upgrade job", "Job", job.Name, "image", image)
+		return false, nil
+	}
+
+	completed, err := jobCompleted(job)
+	if err == nil && !completed {
+		return false, nil
+	}
+
+	deleteErr := r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	if deleteErr != nil && !kerrors.IsNotFound(deleteErr) {
+		return false, fmt.Errorf("failed to delete upgrade job %q: %w", job.Name, deleteErr)
+	}
+
+	return completed, err
+}
+
+func (r *ObjectStoreReconciler) createUpgradeJob(ctx context.Context, objectStore *objectv1alpha1.ObjectStore, job *batchv1.Job, image string, command []string) error {
+	backoffLimit := int32(2)
+	job.Spec = batchv1.JobSpec{
+		Template: v1.PodTemplateSpec{
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{
+						Name:    "upgrade",
+						Image:   image,
+						Command: command,
+						Env:     DaemonEnvVars(image),
+						VolumeMounts: []v1.VolumeMount{
+							daemonVolumeMountPVC(),
+							{Name: "ceph-daemon-data", MountPath: upgradeDataDirectory},
+						},
+						SecurityContext: podSecurityContext(),
+					},
+				},
+				Volumes:       []v1.Volume{DaemonVolumesDataPVC(instanceName(objectStore.Name, objectStore.Namespace))},
+				RestartPolicy: v1.RestartPolicyNever,
+			},
+		},
+		BackoffLimit: &backoffLimit,
+	}
+	applyPodPlacement(objectStore, &job.Spec.Template.Spec)
+
+	err := controllerutil.SetControllerReference(objectStore, job, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to set owner reference to job %q: %w", job.Name, err)
+	}
+
+	err = r.Client.Create(ctx, job)
+	if err != nil {
+		return fmt.Errorf("failed to create upgrade job %q: %w", job.Name, err)
+	}
+
+	return nil
+}
+
+func upgradeJobMeta(objectStore *objectv1alpha1.ObjectStore, step string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-upgrade-%s", instanceName(objectStore.Name, objectStore.Namespace), step),
+			Namespace: objectStore.Namespace,
+		},
+	}
+}
+
+func setUpgradePhase(objectStore *objectv1alpha1.ObjectStore, phase objectv1alpha1.UpgradePhase, reason string) {
+	upgrade := objectStore.Status.Upgrade
+	upgrade.Phase = phase
+	upgrade.PhaseStartTime = metav1.Now()
+	if reason != "" {
+		upgrade.Reason = reason
+	}
+	setCondition(objectStore, objectv1alpha1.ConditionUpgraded, metav1.ConditionFalse, reasonUpgradeInProgress,
+		fmt.Sprintf("upgrading from %q to %q: %s", upgrade.FromImage, upgrade.ToImage, phase))
+}
+
+// setRunningImage records the image of the gateway and its version
+func setRunningImage(objectStore *objectv1alpha1.ObjectStore, image string) {
+	objectStore.Status.Image = image
+	objectStore.Status.Version = ""
+	if imageVersion, err := objectv1alpha1.ImageVersion(image); err == nil {
+		objectStore.Status.Version = imageVersion.String()
+	}
+}
+
+func upgradeTimeout(objectStore *objectv1alpha1.ObjectStore) time.Duration {
+	if objectStore.Spec.Upgrade != nil && objectStore.Spec.Upgrade.TimeoutSeconds > 0 {
+		return time.Duration(objectStore.Spec.Upgrade.TimeoutSeconds) * time.Second
+	}
+	return defaultUpgradeTimeout
+}
@@ -20,9 +20,12 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -82,6 +85,86 @@ func instanceName(name, namespace string) string {
 	return fmt.Sprintf("%s-%s-%s", appName, name, namespace)
 }
 
+// realmName returns the name of the realm bootstrapped by a main site, the names of an ObjectZone
+// are resolved in the status
+func realmName(objectStore *v1alpha1.ObjectStore) string {
+	if objectStore.Spec.HasZoneRef() {
+		return objectStore.Status.Realm
+	}
+	return fmt.Sprintf("%s-%s", objectStore.Name, objectStore.Namespace)
+}
+
+// zoneGroupName returns the name of the zonegroup of the zone, it is empty for the default
+// zonegroup created by rgwam
+func zoneGroupName(objectStore *v1alpha1.ObjectStore) string {
+	if objectStore.Spec.HasZoneRef() {
+		return objectStore.Status.ZoneGroup
+	}
+	return ""
+}
+
+// zoneArgs returns the radosgw-admin flags selecting the zone of the ObjectStore in its realm, the
+// default zonegroup created by rgwam is selected without flag
+func zoneArgs(objectStore *v1alpha1.ObjectStore, realm string) []string {
+	args := []string{fmt.Sprintf("--rgw-realm=%s", realm)}
+	if zoneGroup := zoneGroupName(objectStore); zoneGroup != "" {
+		args = append(args, fmt.Sprintf("--rgw-zonegroup=%s", zoneGroup))
+	}
+	return append(args, fmt.Sprintf("--rgw-zone=%s", zoneName(objectStore)))
+}
+
+// zoneName returns the name of the zone served by the ObjectStore
+func zoneName(objectStore *v1alpha1.ObjectStore) string {
+	if objectStore.Spec.HasZoneRef() {
+		return objectStore.Status.Zone
+	}
+	return fmt.Sprintf("%s-%s", objectStore.Name, objectStore.Namespace)
+}
+
+// realmTokenSecretName returns the secret holding the token of a realm described by an ObjectRealm
+func realmTokenSecretName(realm string) string {
+	return fmt.Sprintf("%s-realm-token", realm)
+}
+
+// gatewayPort returns the port of the gateway service, the defaulting webhook may not be deployed
+func gatewayPort(objectStore *v1alpha1.ObjectStore) int32 {
+	if objectStore.Spec.Gateway.Port != 0 {
+		return objectStore.Spec.Gateway.Port
+	}
+	return v1alpha1.DefaultGatewayPort
+}
+
+// gatewaySecurePort returns the TLS port of the gateway service, 0 when TLS is disabled
+func gatewaySecurePort(objectStore *v1alpha1.ObjectStore) int32 {
+	if !objectStore.Spec.IsTLSEnabled() {
+		return 0
+	}
+	if objectStore.Spec.Gateway.SecurePort != 0 {
+		return objectStore.Spec.Gateway.SecurePort
+	}
+	return v1alpha1.DefaultGatewaySecurePort
+}
+
+// gatewayEndpoint returns the URL of the gateway at the given host, https is preferred when enabled
+func gatewayEndpoint(objectStore *v1alpha1.ObjectStore, host string) string {
+	if objectStore.Spec.IsTLSEnabled() {
+		return fmt.Sprintf("https://%s:%d", host, gatewaySecurePort(objectStore))
+	}
+	return fmt.Sprintf("http://%s:%d", host, gatewayPort(objectStore))
+}
+
+// serviceHost returns the DNS name of the gateway service inside the cluster, it is part of the
+// certificates requested from cert-manager
+func serviceHost(objectStore *v1alpha1.ObjectStore) string {
+	return fmt.Sprintf("%s.%s.svc", instanceName(objectStore.Name, objectStore.Namespace), objectStore.Namespace)
+}
+
+// serviceEndpoint returns the URL of the gateway used by the operator, the service name is used
+// rather than its ClusterIP so that certificates without IP SANs are accepted
+func serviceEndpoint(objectStore *v1alpha1.ObjectStore) string {
+	return gatewayEndpoint(objectStore, serviceHost(objectStore))
+}
+
 // newFlag returns the key-value pair in the format of a Ceph command line-compatible flag.
 func newFlag(key, value string) string {
 	// A flag is a normalized key with underscores replaced by dashes.
@@ -158,7 +241,50 @@ func isBase64Encoded(keyring string) bool {
 	return err == nil
 }
 
+// realmTokenInfo is the content of the token generated by `rgwam-sqlite realm bootstrap`, it is a
+// base64 encoded JSON document
+type realmTokenInfo struct {
+	RealmName string `json:"realm_name"`
+	RealmID   string `json:"realm_id"`
+	Endpoint  string `json:"endpoint"`
+	// AccessKey and SecretKey are the credentials of the realm's system user
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret"`
+}
+
+// decodeRealmToken decodes a realm token
+func decodeRealmToken(token string) (*realmTokenInfo, error) {
+	raw, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode realm token: %w", err)
+	}
+
+	decoded := &realmTokenInfo{}
+	err = json.Unmarshal(raw, decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal realm token: %w", err)
+	}
+
+	return decoded, nil
+}
+
+// encodeRealmToken encodes a realm token in the format of `rgwam-sqlite realm bootstrap`
+func encodeRealmToken(info *realmTokenInfo) (string, error) {
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal realm token: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
 func extractExitCode(err error) (int, error) {
+	// The errors of the executor mask the secrets of the command, the exit code is on the original
+	var redacted *redactedError
+	if errors.As(err, &redacted) {
+		err = redacted.err
+	}
+
 	switch errType := err.(type) {
 	case *exec.ExitError:
 		return errType.ExitCode(), nil
@@ -180,3 +306,36 @@ func extractExitCode(err error) (int, error) {
 		return -1, fmt.Errorf("error %#v is an unknown error type: %v", err, reflect.TypeOf(err))
 	}
 }
+
+// managedAnnotationsAnnotation lists the annotations set from the ObjectStore spec on an object, so
+// that they are removed from the object once removed from the spec
+var managedAnnotationsAnnotation = fmt.Sprintf("%s/managed-annotations", v1alpha1.GroupVersion.Group)
+
+// applyManagedAnnotations sets the annotations of the spec on the current annotations of an object
+// and removes the ones the previous reconcile set which are no longer in the spec, the annotations
+// added by others are kept
+func applyManagedAnnotations(current, desired map[string]string) map[string]string {
+	if current == nil {
+		current = map[string]string{}
+	}
+	if previous := current[managedAnnotationsAnnotation]; previous != "" {
+		for _, key := range strings.Split(previous, ",") {
+			if _, ok := desired[key]; !ok {
+				delete(current, key)
+			}
+		}
+	}
+	delete(current, managedAnnotationsAnnotation)
+
+	keys := []string{}
+	for key, value := range desired {
+		current[key] = value
+		keys = append(keys, key)
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		current[managedAnnotationsAnnotation] = strings.Join(keys, ",")
+	}
+
+	return current
+}
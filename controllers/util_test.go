@@ -0,0 +1,66 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	objectv1alpha1 "github.com/redhat-et/rgw-standalone-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestZoneArgs(t *testing.T) {
+	secondary := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "rgw"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Multisite: &objectv1alpha1.MultisiteSpec{RealmTokenSecretName: "token"},
+		},
+	}
+	zoned := &objectv1alpha1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "edge", Namespace: "rgw"},
+		Spec: objectv1alpha1.ObjectStoreSpec{
+			Multisite: &objectv1alpha1.MultisiteSpec{ZoneRef: "eu-west"},
+		},
+		Status: objectv1alpha1.ObjectStoreStatus{Realm: "fleet", ZoneGroup: "eu", Zone: "eu-west"},
+	}
+
+	tests := []struct {
+		name        string
+		objectStore *objectv1alpha1.ObjectStore
+		want        []string
+	}{
+		{
+			name:        "default zonegroup",
+			objectStore: secondary,
+			want:        []string{"--rgw-realm=fleet", "--rgw-zone=" + zoneName(secondary)},
+		},
+		{
+			name:        "zone of an ObjectZone",
+			objectStore: zoned,
+			want:        []string{"--rgw-realm=fleet", "--rgw-zonegroup=eu", "--rgw-zone=eu-west"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := zoneArgs(test.objectStore, "fleet"); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("zoneArgs() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -56,11 +56,15 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var realmTokenNamespaces string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&realmTokenNamespaces, "realm-token-namespaces", "",
+		"Comma separated list of source:destination namespace pairs a realm token may be read from or exported "+
+			"between, besides the namespace of each ObjectStore. \"*\" matches every namespace on either side.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -69,6 +73,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	realmTokenNamespacePairs, err := controllers.ParseRealmTokenNamespaces(realmTokenNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid --realm-token-namespaces flag")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -94,11 +104,86 @@ func main() {
 		Scheme:                   mgr.GetScheme(),
 		Logger:                   logger,
 		RemotePodCommandExecutor: controllers.NewExecutor(kubernetesClientSet, mgr.GetConfig(), logger),
+		RealmTokenNamespaces:     realmTokenNamespacePairs,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "failed to create controller", "controller", "ObjectStore")
 		os.Exit(1)
 	}
 
+	if err = (&controllers.ObjectStoreUserReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectStoreUser"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectStoreUser")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.BucketReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("Bucket"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "Bucket")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ObjectStoreBackupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectStoreBackup"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectStoreBackup")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ObjectStoreSnapshotReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectStoreSnapshot"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectStoreSnapshot")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ObjectRealmReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectRealm"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectRealm")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ObjectZoneGroupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectZoneGroup"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectZoneGroup")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ObjectZoneReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Logger: ctrl.Log.WithName("controllers").WithName("ObjectZone"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "failed to create controller", "controller", "ObjectZone")
+		os.Exit(1)
+	}
+
+	// The webhooks need the serving certificate, they are disabled when running outside the cluster
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&objectv1alpha1.ObjectStore{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "failed to create webhook", "webhook", "ObjectStore")
+			os.Exit(1)
+		}
+		if err = (&objectv1alpha1.ObjectStoreUser{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "failed to create webhook", "webhook", "ObjectStoreUser")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,252 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package admin is a minimal client of the admin ops API of the gateway, covering the users,
+// buckets, quotas and usage managed by the operator. The requests are signed like the S3 ones.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redhat-et/rgw-standalone-operator/pkg/s3"
+)
+
+// Client talks to the admin ops API of a gateway with the keys of a user holding admin capabilities
+type Client struct {
+	Endpoint   string
+	AccessKey  string
+	SecretKey  string
+	Region     string
+	HTTPClient *http.Client
+}
+
+// Error is an error returned by the admin ops API
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"Code"`
+	RequestID  string `json:"RequestId"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("admin ops error %d %s (request %s)", e.StatusCode, e.Code, e.RequestID)
+}
+
+// IsNotFound returns whether the error is a missing user, bucket or key
+func IsNotFound(err error) bool {
+	var adminErr *Error
+	return errors.As(err, &adminErr) && adminErr.StatusCode == http.StatusNotFound
+}
+
+// IsErrorCode returns whether the error is an admin ops error with the given code
+func IsErrorCode(err error, code string) bool {
+	var adminErr *Error
+	return errors.As(err, &adminErr) && adminErr.Code == code
+}
+
+// NewClient returns a client for the given endpoint, e.g. http://10.0.0.1:8080
+func NewClient(endpoint, accessKey, secretKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Region:     s3.DefaultRegion,
+		HTTPClient: httpClient,
+	}
+}
+
+// do sends a signed request on the admin resource, e.g. "user", and decodes the JSON response in out
+func (c *Client) do(ctx context.Context, method, resource string, query url.Values, out interface{}) error {
+	query.Set("format", "json")
+	url := c.Endpoint + "/admin/" + resource + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	s3.SignV4(req, nil, c.AccessKey, c.SecretKey, c.Region, "s3", time.Now())
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to %s /admin/%s: %w", method, resource, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response of %s /admin/%s: %w", method, resource, err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		adminErr := &Error{StatusCode: resp.StatusCode}
+		if len(data) > 0 {
+			_ = json.Unmarshal(data, adminErr)
+		}
+		if adminErr.Code == "" {
+			adminErr.Code = http.StatusText(resp.StatusCode)
+		}
+		return adminErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		return fmt.Errorf("failed to parse response of %s /admin/%s: %w", method, resource, err)
+	}
+
+	return nil
+}
+
+// GetUser returns the user with its keys, capabilities and storage stats
+func (c *Client) GetUser(ctx context.Context, uid string) (*User, error) {
+	user := &User{}
+	err := c.do(ctx, http.MethodGet, "user", url.Values{"uid": {uid}, "stats": {"true"}}, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateUser creates the user with a generated S3 key, maxBuckets is left to the gateway default
+// when negative
+func (c *Client) CreateUser(ctx context.Context, uid, displayName string, maxBuckets int) (*User, error) {
+	query := url.Values{"uid": {uid}, "display-name": {displayName}}
+	if maxBuckets >= 0 {
+		query.Set("max-buckets", strconv.Itoa(maxBuckets))
+	}
+
+	user := &User{}
+	err := c.do(ctx, http.MethodPut, "user", query, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ModifyUser updates the display name and the maximum number of buckets, empty or negative values
+// are left unchanged
+func (c *Client) ModifyUser(ctx context.Context, uid, displayName string, maxBuckets int) error {
+	query := url.Values{"uid": {uid}}
+	if displayName != "" {
+		query.Set("display-name", displayName)
+	}
+	if maxBuckets >= 0 {
+		query.Set("max-buckets", strconv.Itoa(maxBuckets))
+	}
+
+	return c.do(ctx, http.MethodPost, "user", query, nil)
+}
+
+// RemoveUser deletes the user, its buckets must be deleted first
+func (c *Client) RemoveUser(ctx context.Context, uid string) error {
+	return c.do(ctx, http.MethodDelete, "user", url.Values{"uid": {uid}}, nil)
+}
+
+// AddCapabilities grants the capabilities to the user, in the "buckets=*;users=read" format
+func (c *Client) AddCapabilities(ctx context.Context, uid, caps string) error {
+	return c.do(ctx, http.MethodPut, "user", url.Values{"caps": {""}, "uid": {uid}, "user-caps": {caps}}, nil)
+}
+
+// RemoveCapabilities revokes the capabilities from the user, in the "buckets=*;users=read" format
+func (c *Client) RemoveCapabilities(ctx context.Context, uid, caps string) error {
+	return c.do(ctx, http.MethodDelete, "user", url.Values{"caps": {""}, "uid": {uid}, "user-caps": {caps}}, nil)
+}
+
+// GetUserQuota returns the quota of the user
+func (c *Client) GetUserQuota(ctx context.Context, uid string) (*Quota, error) {
+	quota := &Quota{}
+	err := c.do(ctx, http.MethodGet, "user", url.Values{"quota": {""}, "uid": {uid}, "quota-type": {"user"}}, quota)
+	if err != nil {
+		return nil, err
+	}
+
+	return quota, nil
+}
+
+// SetUserQuota replaces the quota of the user, -1 is unlimited
+func (c *Client) SetUserQuota(ctx context.Context, uid string, quota Quota) error {
+	return c.do(ctx, http.MethodPut, "user", quotaQuery(uid, "user", quota), nil)
+}
+
+// ListBuckets returns the names of the buckets owned by the user
+func (c *Client) ListBuckets(ctx context.Context, uid string) ([]string, error) {
+	buckets := []string{}
+	err := c.do(ctx, http.MethodGet, "bucket", url.Values{"uid": {uid}}, &buckets)
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
+// GetBucket returns the owner and the stats of the bucket
+func (c *Client) GetBucket(ctx context.Context, bucket string) (*Bucket, error) {
+	info := &Bucket{}
+	err := c.do(ctx, http.MethodGet, "bucket", url.Values{"bucket": {bucket}, "stats": {"true"}}, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// SetBucketQuota replaces the quota of a bucket of the user, -1 is unlimited
+func (c *Client) SetBucketQuota(ctx context.Context, uid, bucket string, quota Quota) error {
+	query := quotaQuery(uid, "", quota)
+	query.Set("bucket", bucket)
+	return c.do(ctx, http.MethodPut, "bucket", query, nil)
+}
+
+// GetUsage returns the usage summary of the user, the usage log must be enabled on the gateway
+func (c *Client) GetUsage(ctx context.Context, uid string) (*Usage, error) {
+	usage := &Usage{}
+	err := c.do(ctx, http.MethodGet, "usage", url.Values{"uid": {uid}, "show-entries": {"false"}, "show-summary": {"true"}}, usage)
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// quotaQuery returns the query setting the quota, the quota type is only given for users
+func quotaQuery(uid, quotaType string, quota Quota) url.Values {
+	query := url.Values{
+		"quota":       {""},
+		"uid":         {uid},
+		"enabled":     {strconv.FormatBool(quota.Enabled)},
+		"max-size":    {strconv.FormatInt(quota.MaxSize, 10)},
+		"max-objects": {strconv.FormatInt(quota.MaxObjects, 10)},
+	}
+	if quotaType != "" {
+		query.Set("quota-type", quotaType)
+	}
+	return query
+}
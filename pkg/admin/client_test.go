@@ -0,0 +1,268 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client of a server answering every request with the status and body, and
+// the last request it received
+func newTestClient(t *testing.T, status int, body string) (*Client, **http.Request) {
+	t.Helper()
+	var last *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r.Clone(context.Background())
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL, "access", "secret", nil), &last
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantCode     string
+		wantNotFound bool
+	}{
+		{
+			name:         "missing user",
+			status:       http.StatusNotFound,
+			body:         `{"Code":"NoSuchUser","RequestId":"tx1","HostId":"h"}`,
+			wantCode:     "NoSuchUser",
+			wantNotFound: true,
+		},
+		{
+			name:     "existing user",
+			status:   http.StatusConflict,
+			body:     `{"Code":"UserAlreadyExists","RequestId":"tx2"}`,
+			wantCode: "UserAlreadyExists",
+		},
+		{
+			name:     "error without body",
+			status:   http.StatusForbidden,
+			wantCode: "Forbidden",
+		},
+		{
+			name:     "error with invalid body",
+			status:   http.StatusInternalServerError,
+			body:     "<html>oops",
+			wantCode: "Internal Server Error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newTestClient(t, test.status, test.body)
+			_, err := client.GetUser(context.Background(), "u")
+			if err == nil {
+				t.Fatalf("GetUser() succeeded, want an error")
+			}
+			if !IsErrorCode(err, test.wantCode) {
+				t.Errorf("error %v, want code %q", err, test.wantCode)
+			}
+			if IsNotFound(err) != test.wantNotFound {
+				t.Errorf("IsNotFound(%v) = %v, want %v", err, IsNotFound(err), test.wantNotFound)
+			}
+		})
+	}
+}
+
+func TestInvalidResponse(t *testing.T) {
+	client, _ := newTestClient(t, http.StatusOK, "not json")
+	_, err := client.GetUser(context.Background(), "u")
+	if err == nil || !strings.Contains(err.Error(), "failed to parse response of GET /admin/user") {
+		t.Errorf("GetUser() = %v, want a parse error", err)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	quota := Quota{Enabled: true, MaxSize: 1024, MaxObjects: -1}
+
+	tests := []struct {
+		name       string
+		call       func(c *Client) (interface{}, error)
+		body       string
+		wantMethod string
+		wantPath   string
+		wantQuery  url.Values
+		want       interface{}
+	}{
+		{
+			name:       "GetUser",
+			call:       func(c *Client) (interface{}, error) { return c.GetUser(context.Background(), "u") },
+			body:       `{"user_id":"u","display_name":"U","max_buckets":10,"keys":[{"user":"u","access_key":"a","secret_key":"s"}],"caps":[{"type":"buckets","perm":"*"}],"user_quota":{"enabled":true,"max_size":1024,"max_objects":-1},"stats":{"size":1,"size_actual":4096,"num_objects":1}}`,
+			wantMethod: http.MethodGet,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"uid": {"u"}, "stats": {"true"}},
+			want: &User{
+				UserID:      "u",
+				DisplayName: "U",
+				MaxBuckets:  10,
+				Keys:        []UserKey{{User: "u", AccessKey: "a", SecretKey: "s"}},
+				Caps:        []Capability{{Type: "buckets", Perm: "*"}},
+				UserQuota:   quota,
+				Stats:       &UserStats{Size: 1, SizeActual: 4096, NumObjects: 1},
+			},
+		},
+		{
+			name:       "CreateUser",
+			call:       func(c *Client) (interface{}, error) { return c.CreateUser(context.Background(), "u", "U", 5) },
+			body:       `{"user_id":"u","display_name":"U","max_buckets":5}`,
+			wantMethod: http.MethodPut,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"uid": {"u"}, "display-name": {"U"}, "max-buckets": {"5"}},
+			want:       &User{UserID: "u", DisplayName: "U", MaxBuckets: 5},
+		},
+		{
+			name:       "CreateUser with default max buckets",
+			call:       func(c *Client) (interface{}, error) { return c.CreateUser(context.Background(), "u", "U", -1) },
+			body:       `{"user_id":"u","display_name":"U","max_buckets":1000}`,
+			wantMethod: http.MethodPut,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"uid": {"u"}, "display-name": {"U"}},
+			want:       &User{UserID: "u", DisplayName: "U", MaxBuckets: 1000},
+		},
+		{
+			name:       "ModifyUser",
+			call:       func(c *Client) (interface{}, error) { return nil, c.ModifyUser(context.Background(), "u", "", 0) },
+			wantMethod: http.MethodPost,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"uid": {"u"}, "max-buckets": {"0"}},
+		},
+		{
+			name:       "RemoveUser",
+			call:       func(c *Client) (interface{}, error) { return nil, c.RemoveUser(context.Background(), "u") },
+			wantMethod: http.MethodDelete,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"uid": {"u"}},
+		},
+		{
+			name: "AddCapabilities",
+			call: func(c *Client) (interface{}, error) {
+				return nil, c.AddCapabilities(context.Background(), "u", "buckets=*;users=read")
+			},
+			body:       `[{"type":"buckets","perm":"*"}]`,
+			wantMethod: http.MethodPut,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"caps": {""}, "uid": {"u"}, "user-caps": {"buckets=*;users=read"}},
+		},
+		{
+			name: "RemoveCapabilities",
+			call: func(c *Client) (interface{}, error) {
+				return nil, c.RemoveCapabilities(context.Background(), "u", "users=read")
+			},
+			wantMethod: http.MethodDelete,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"caps": {""}, "uid": {"u"}, "user-caps": {"users=read"}},
+		},
+		{
+			name:       "GetUserQuota",
+			call:       func(c *Client) (interface{}, error) { return c.GetUserQuota(context.Background(), "u") },
+			body:       `{"enabled":true,"check_on_raw":false,"max_size":1024,"max_size_kb":1,"max_objects":-1}`,
+			wantMethod: http.MethodGet,
+			wantPath:   "/admin/user",
+			wantQuery:  url.Values{"quota": {""}, "uid": {"u"}, "quota-type": {"user"}},
+			want:       &quota,
+		},
+		{
+			name:       "SetUserQuota",
+			call:       func(c *Client) (interface{}, error) { return nil, c.SetUserQuota(context.Background(), "u", quota) },
+			wantMethod: http.MethodPut,
+			wantPath:   "/admin/user",
+			wantQuery: url.Values{"quota": {""}, "uid": {"u"}, "quota-type": {"user"}, "enabled": {"true"},
+				"max-size": {"1024"}, "max-objects": {"-1"}},
+		},
+		{
+			name:       "ListBuckets",
+			call:       func(c *Client) (interface{}, error) { return c.ListBuckets(context.Background(), "u") },
+			body:       `["b1","b2"]`,
+			wantMethod: http.MethodGet,
+			wantPath:   "/admin/bucket",
+			wantQuery:  url.Values{"uid": {"u"}},
+			want:       []string{"b1", "b2"},
+		},
+		{
+			name:       "GetBucket",
+			call:       func(c *Client) (interface{}, error) { return c.GetBucket(context.Background(), "b") },
+			body:       `{"bucket":"b","owner":"u","usage":{"rgw.main":{"size":10,"size_actual":4096,"num_objects":2}}}`,
+			wantMethod: http.MethodGet,
+			wantPath:   "/admin/bucket",
+			wantQuery:  url.Values{"bucket": {"b"}, "stats": {"true"}},
+			want: &Bucket{Bucket: "b", Owner: "u", Usage: map[string]BucketUsage{
+				"rgw.main": {Size: 10, SizeActual: 4096, NumObjects: 2},
+			}},
+		},
+		{
+			name: "SetBucketQuota",
+			call: func(c *Client) (interface{}, error) {
+				return nil, c.SetBucketQuota(context.Background(), "u", "b", quota)
+			},
+			wantMethod: http.MethodPut,
+			wantPath:   "/admin/bucket",
+			wantQuery: url.Values{"quota": {""}, "uid": {"u"}, "bucket": {"b"}, "enabled": {"true"},
+				"max-size": {"1024"}, "max-objects": {"-1"}},
+		},
+		{
+			name:       "GetUsage",
+			call:       func(c *Client) (interface{}, error) { return c.GetUsage(context.Background(), "u") },
+			body:       `{"summary":[{"user":"u","categories":[],"total":{"bytes_sent":1,"bytes_received":2,"ops":3,"successful_ops":2}}]}`,
+			wantMethod: http.MethodGet,
+			wantPath:   "/admin/usage",
+			wantQuery:  url.Values{"uid": {"u"}, "show-entries": {"false"}, "show-summary": {"true"}},
+			want: &Usage{Summary: []UsageSummary{
+				{User: "u", Total: UsageTotal{BytesSent: 1, BytesReceived: 2, Ops: 3, SuccessfulOps: 2}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, last := newTestClient(t, http.StatusOK, test.body)
+			got, err := test.call(client)
+			if err != nil {
+				t.Fatalf("%s() = %v", test.name, err)
+			}
+
+			req := *last
+			if req.Method != test.wantMethod || req.URL.Path != test.wantPath {
+				t.Errorf("request %s %s, want %s %s", req.Method, req.URL.Path, test.wantMethod, test.wantPath)
+			}
+			test.wantQuery.Set("format", "json")
+			if query := req.URL.Query(); !reflect.DeepEqual(query, test.wantQuery) {
+				t.Errorf("query %v, want %v", query, test.wantQuery)
+			}
+			if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") {
+				t.Errorf("Authorization %q is not a SigV4 signature of the access key", auth)
+			}
+
+			if test.want != nil && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("%s() = %+v, want %+v", test.name, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+// User is a user of the gateway, the same document is printed by `radosgw-admin user info`
+type User struct {
+	UserID      string       `json:"user_id"`
+	DisplayName string       `json:"display_name"`
+	MaxBuckets  int          `json:"max_buckets"`
+	Keys        []UserKey    `json:"keys"`
+	Caps        []Capability `json:"caps"`
+	UserQuota   Quota        `json:"user_quota"`
+	Stats       *UserStats   `json:"stats,omitempty"`
+}
+
+// UserKey is an S3 key of a user
+type UserKey struct {
+	User      string `json:"user"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// Capability is an admin capability of a user, e.g. type "buckets" with perm "*"
+type Capability struct {
+	Type string `json:"type"`
+	Perm string `json:"perm"`
+}
+
+// Quota is a user or bucket quota, -1 is unlimited
+type Quota struct {
+	Enabled    bool  `json:"enabled"`
+	MaxSize    int64 `json:"max_size"`
+	MaxObjects int64 `json:"max_objects"`
+}
+
+// UserStats is the space used by all the buckets of a user
+type UserStats struct {
+	Size       int64 `json:"size"`
+	SizeActual int64 `json:"size_actual"`
+	NumObjects int64 `json:"num_objects"`
+}
+
+// Bucket is the information of a bucket
+type Bucket struct {
+	Bucket string                 `json:"bucket"`
+	Owner  string                 `json:"owner"`
+	Usage  map[string]BucketUsage `json:"usage"`
+}
+
+// BucketUsage is the space used by a category of objects of a bucket, e.g. "rgw.main"
+type BucketUsage struct {
+	Size       int64 `json:"size"`
+	SizeActual int64 `json:"size_actual"`
+	NumObjects int64 `json:"num_objects"`
+}
+
+// Usage is the usage log summary of a user
+type Usage struct {
+	Summary []UsageSummary `json:"summary"`
+}
+
+// UsageSummary is the number of operations and bytes transferred by a user
+type UsageSummary struct {
+	User  string     `json:"user"`
+	Total UsageTotal `json:"total"`
+}
+
+// UsageTotal are the totals of the usage log
+type UsageTotal struct {
+	BytesSent     int64 `json:"bytes_sent"`
+	BytesReceived int64 `json:"bytes_received"`
+	Ops           int64 `json:"ops"`
+	SuccessfulOps int64 `json:"successful_ops"`
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package s3 is a minimal S3 client covering the bucket configuration operations managed by the
+// operator. It avoids pulling a full SDK for the handful of calls we make.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultRegion is used to sign the requests, the gateway does not enforce it
+const DefaultRegion = "us-east-1"
+
+// Client talks to the S3 API of a gateway with path-style addressing
+type Client struct {
+	Endpoint   string
+	AccessKey  string
+	SecretKey  string
+	Region     string
+	HTTPClient *http.Client
+}
+
+// Error is an error returned by the S3 API
+type Error struct {
+	StatusCode int    `xml:"-"`
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// IsNotFound returns whether the error is a missing bucket or bucket configuration
+func IsNotFound(err error) bool {
+	var s3Err *Error
+	return errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound
+}
+
+// IsErrorCode returns whether the error is an S3 error with the given code
+func IsErrorCode(err error, code string) bool {
+	var s3Err *Error
+	return errors.As(err, &s3Err) && s3Err.Code == code
+}
+
+// NewClient returns a client for the given endpoint, e.g. http://10.0.0.1:8080
+func NewClient(endpoint, accessKey, secretKey string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Client{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		AccessKey:  accessKey,
+		SecretKey:  secretKey,
+		Region:     DefaultRegion,
+		HTTPClient: httpClient,
+	}
+}
+
+// do sends a signed request on the bucket, subresource is the query string, e.g. "versioning"
+func (c *Client) do(ctx context.Context, method, bucket, subresource string, headers map[string]string, body []byte) ([]byte, error) {
+	url := c.Endpoint + "/" + bucket
+	if subresource != "" {
+		url += "?" + subresource
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if len(body) > 0 {
+		// Required by the configuration operations taking a payload
+		sum := md5.Sum(body)
+		req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		// The configurations are XML documents, except the JSON policy
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/xml")
+		}
+	}
+	SignV4(req, body, c.AccessKey, c.SecretKey, c.Region, "s3", time.Now())
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response of %s %s: %w", method, url, err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		s3Err := &Error{StatusCode: resp.StatusCode}
+		if len(data) > 0 {
+			_ = xml.Unmarshal(data, s3Err)
+		}
+		if s3Err.Code == "" {
+			s3Err.Code = http.StatusText(resp.StatusCode)
+		}
+		return nil, s3Err
+	}
+
+	return data, nil
+}
+
+// get fetches a bucket configuration, a missing configuration is reported as not found
+func (c *Client) get(ctx context.Context, bucket, subresource string, out interface{}) error {
+	data, err := c.do(ctx, http.MethodGet, bucket, subresource, nil, nil)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	err = xml.Unmarshal(data, out)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s of bucket %q: %w", subresource, bucket, err)
+	}
+
+	return nil
+}
+
+// put replaces a bucket configuration
+func (c *Client) put(ctx context.Context, bucket, subresource string, in interface{}) error {
+	body, err := xml.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s of bucket %q: %w", subresource, bucket, err)
+	}
+
+	_, err = c.do(ctx, http.MethodPut, bucket, subresource, nil, body)
+	return err
+}
+
+// BucketExists returns whether the bucket exists and is accessible
+func (c *Client) BucketExists(ctx context.Context, bucket string) (bool, error) {
+	_, err := c.do(ctx, http.MethodHead, bucket, "", nil, nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// CreateBucket creates the bucket, object lock can only be enabled at creation
+func (c *Client) CreateBucket(ctx context.Context, bucket string, objectLock bool) error {
+	headers := map[string]string{}
+	if objectLock {
+		headers["X-Amz-Bucket-Object-Lock-Enabled"] = "true"
+	}
+
+	_, err := c.do(ctx, http.MethodPut, bucket, "", headers, nil)
+	if err != nil && !IsErrorCode(err, "BucketAlreadyOwnedByYou") {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBucket deletes the bucket, it must be empty
+func (c *Client) DeleteBucket(ctx context.Context, bucket string) error {
+	_, err := c.do(ctx, http.MethodDelete, bucket, "", nil, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// GetBucketVersioning returns the versioning status, empty if it was never enabled
+func (c *Client) GetBucketVersioning(ctx context.Context, bucket string) (string, error) {
+	config := &VersioningConfiguration{}
+	err := c.get(ctx, bucket, "versioning", config)
+	return config.Status, err
+}
+
+// PutBucketVersioning sets the versioning status, Enabled or Suspended
+func (c *Client) PutBucketVersioning(ctx context.Context, bucket, status string) error {
+	return c.put(ctx, bucket, "versioning", &VersioningConfiguration{Status: status})
+}
+
+// GetBucketPolicy returns the JSON policy of the bucket, empty if there is none
+func (c *Client) GetBucketPolicy(ctx context.Context, bucket string) (string, error) {
+	data, err := c.do(ctx, http.MethodGet, bucket, "policy", nil, nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// PutBucketPolicy replaces the JSON policy of the bucket
+func (c *Client) PutBucketPolicy(ctx context.Context, bucket, policy string) error {
+	_, err := c.do(ctx, http.MethodPut, bucket, "policy", map[string]string{"Content-Type": "application/json"}, []byte(policy))
+	return err
+}
+
+// DeleteBucketPolicy removes the policy of the bucket
+func (c *Client) DeleteBucketPolicy(ctx context.Context, bucket string) error {
+	_, err := c.do(ctx, http.MethodDelete, bucket, "policy", nil, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// GetBucketCors returns the CORS rules of the bucket
+func (c *Client) GetBucketCors(ctx context.Context, bucket string) ([]CORSRule, error) {
+	config := &CORSConfiguration{}
+	err := c.get(ctx, bucket, "cors", config)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return config.Rules, nil
+}
+
+// PutBucketCors replaces the CORS rules of the bucket, no rule deletes the configuration
+func (c *Client) PutBucketCors(ctx context.Context, bucket string, rules []CORSRule) error {
+	if len(rules) == 0 {
+		_, err := c.do(ctx, http.MethodDelete, bucket, "cors", nil, nil)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	return c.put(ctx, bucket, "cors", &CORSConfiguration{Rules: rules})
+}
+
+// GetBucketLifecycle returns the lifecycle rules of the bucket
+func (c *Client) GetBucketLifecycle(ctx context.Context, bucket string) ([]LifecycleRule, error) {
+	config := &LifecycleConfiguration{}
+	err := c.get(ctx, bucket, "lifecycle", config)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return config.Rules, nil
+}
+
+// PutBucketLifecycle replaces the lifecycle rules of the bucket, no rule deletes the configuration
+func (c *Client) PutBucketLifecycle(ctx context.Context, bucket string, rules []LifecycleRule) error {
+	if len(rules) == 0 {
+		_, err := c.do(ctx, http.MethodDelete, bucket, "lifecycle", nil, nil)
+		if err != nil && !IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	return c.put(ctx, bucket, "lifecycle", &LifecycleConfiguration{Rules: rules})
+}
+
+// GetObjectLockConfiguration returns the object lock configuration, nil if object lock is disabled
+func (c *Client) GetObjectLockConfiguration(ctx context.Context, bucket string) (*ObjectLockConfiguration, error) {
+	config := &ObjectLockConfiguration{}
+	err := c.get(ctx, bucket, "object-lock", config)
+	if err != nil {
+		if IsNotFound(err) || IsErrorCode(err, "InvalidRequest") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// PutObjectLockConfiguration replaces the default retention of a bucket created with object lock
+func (c *Client) PutObjectLockConfiguration(ctx context.Context, bucket string, config *ObjectLockConfiguration) error {
+	return c.put(ctx, bucket, "object-lock", config)
+}
+
+// ListObjects returns every object of the bucket whose key starts with the prefix
+func (c *Client) ListObjects(ctx context.Context, bucket, prefix string) ([]Object, error) {
+	objects := []Object{}
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		result := &ListBucketResult{}
+		err := c.get(ctx, bucket, query.Encode(), result)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, result.Contents...)
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// DeleteObject deletes the object, a missing object is not an error
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, objectPath(bucket, key), "", nil, nil)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// objectPath returns the escaped path of an object, the slashes of the key are kept
+func objectPath(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return bucket + "/" + strings.Join(segments, "/")
+}
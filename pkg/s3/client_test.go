@@ -0,0 +1,343 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request is a request received by the test server
+type request struct {
+	method   string
+	path     string
+	rawPath  string
+	query    string
+	header   http.Header
+	body     string
+	signedBy string
+}
+
+// response is the answer of the test server to a request
+type response struct {
+	status int
+	body   string
+}
+
+// newTestClient returns a client of a server answering the responses in order and recording the
+// requests it received
+func newTestClient(t *testing.T, responses ...response) (*Client, *[]request) {
+	t.Helper()
+	requests := []request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, request{
+			method:   r.Method,
+			path:     r.URL.Path,
+			rawPath:  r.URL.EscapedPath(),
+			query:    r.URL.RawQuery,
+			header:   r.Header.Clone(),
+			body:     string(body),
+			signedBy: r.Header.Get("Authorization"),
+		})
+		if len(requests) > len(responses) {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		resp := responses[len(requests)-1]
+		if resp.status == 0 {
+			resp.status = http.StatusOK
+		}
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL+"/", "access", "secret", nil), &requests
+}
+
+const noSuchBucket = `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>NoSuchBucket</Code><Message>The specified bucket does not exist</Message><BucketName>b</BucketName></Error>`
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     response
+		wantCode     string
+		wantMessage  string
+		wantNotFound bool
+	}{
+		{
+			name:         "xml error",
+			response:     response{status: http.StatusNotFound, body: noSuchBucket},
+			wantCode:     "NoSuchBucket",
+			wantMessage:  "The specified bucket does not exist",
+			wantNotFound: true,
+		},
+		{
+			name:     "error without body",
+			response: response{status: http.StatusForbidden},
+			wantCode: "Forbidden",
+		},
+		{
+			name:     "error with invalid body",
+			response: response{status: http.StatusInternalServerError, body: "<html>oops"},
+			wantCode: "Internal Server Error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newTestClient(t, test.response)
+			_, err := client.GetBucketVersioning(context.Background(), "b")
+			if err == nil {
+				t.Fatalf("GetBucketVersioning() succeeded, want an error")
+			}
+			if !IsErrorCode(err, test.wantCode) {
+				t.Errorf("error %v, want code %q", err, test.wantCode)
+			}
+			if IsNotFound(err) != test.wantNotFound {
+				t.Errorf("IsNotFound(%v) = %v, want %v", err, IsNotFound(err), test.wantNotFound)
+			}
+			if test.wantMessage != "" && !strings.Contains(err.Error(), test.wantMessage) {
+				t.Errorf("error %q, want message %q", err.Error(), test.wantMessage)
+			}
+		})
+	}
+}
+
+func TestBucketExists(t *testing.T) {
+	client, requests := newTestClient(t, response{}, response{status: http.StatusNotFound})
+
+	exists, err := client.BucketExists(context.Background(), "b")
+	if err != nil || !exists {
+		t.Errorf("BucketExists() = %v, %v, want true, nil", exists, err)
+	}
+	exists, err = client.BucketExists(context.Background(), "b")
+	if err != nil || exists {
+		t.Errorf("BucketExists() = %v, %v, want false, nil", exists, err)
+	}
+
+	got := (*requests)[0]
+	if got.method != http.MethodHead || got.path != "/b" {
+		t.Errorf("request %s %s, want HEAD /b", got.method, got.path)
+	}
+	if !strings.HasPrefix(got.signedBy, "AWS4-HMAC-SHA256 Credential=access/") || !strings.Contains(got.signedBy, "/us-east-1/s3/aws4_request") {
+		t.Errorf("Authorization %q is not a SigV4 signature of the access key", got.signedBy)
+	}
+}
+
+func TestCreateBucket(t *testing.T) {
+	client, requests := newTestClient(t,
+		response{},
+		response{status: http.StatusConflict, body: `<Error><Code>BucketAlreadyOwnedByYou</Code></Error>`},
+		response{status: http.StatusConflict, body: `<Error><Code>BucketAlreadyExists</Code></Error>`},
+	)
+
+	err := client.CreateBucket(context.Background(), "b", true)
+	if err != nil {
+		t.Errorf("CreateBucket() = %v", err)
+	}
+	if got := (*requests)[0].header.Get("X-Amz-Bucket-Object-Lock-Enabled"); got != "true" {
+		t.Errorf("X-Amz-Bucket-Object-Lock-Enabled = %q, want true", got)
+	}
+
+	err = client.CreateBucket(context.Background(), "b", false)
+	if err != nil {
+		t.Errorf("CreateBucket() of an owned bucket = %v, want nil", err)
+	}
+	if got := (*requests)[1].header.Get("X-Amz-Bucket-Object-Lock-Enabled"); got != "" {
+		t.Errorf("X-Amz-Bucket-Object-Lock-Enabled = %q, want none", got)
+	}
+
+	err = client.CreateBucket(context.Background(), "b", false)
+	if !IsErrorCode(err, "BucketAlreadyExists") {
+		t.Errorf("CreateBucket() of a bucket of another user = %v, want BucketAlreadyExists", err)
+	}
+}
+
+func TestBucketVersioning(t *testing.T) {
+	client, requests := newTestClient(t,
+		response{body: `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Status>Enabled</Status></VersioningConfiguration>`},
+		response{},
+		response{},
+	)
+
+	status, err := client.GetBucketVersioning(context.Background(), "b")
+	if err != nil || status != "Enabled" {
+		t.Errorf("GetBucketVersioning() = %q, %v, want Enabled, nil", status, err)
+	}
+	if got := (*requests)[0]; got.method != http.MethodGet || got.query != "versioning" {
+		t.Errorf("request %s ?%s, want GET ?versioning", got.method, got.query)
+	}
+
+	// A bucket whose versioning was never configured answers an empty body
+	status, err = client.GetBucketVersioning(context.Background(), "b")
+	if err != nil || status != "" {
+		t.Errorf("GetBucketVersioning() = %q, %v, want empty, nil", status, err)
+	}
+
+	err = client.PutBucketVersioning(context.Background(), "b", "Suspended")
+	if err != nil {
+		t.Fatalf("PutBucketVersioning() = %v", err)
+	}
+	put := (*requests)[2]
+	wantBody := `<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`
+	if put.method != http.MethodPut || put.body != wantBody {
+		t.Errorf("request %s %q, want PUT %q", put.method, put.body, wantBody)
+	}
+	sum := md5.Sum([]byte(wantBody))
+	if got := put.header.Get("Content-MD5"); got != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("Content-MD5 = %q, want the digest of the body", got)
+	}
+	if got := put.header.Get("Content-Type"); got != "application/xml" {
+		t.Errorf("Content-Type = %q, want application/xml", got)
+	}
+}
+
+func TestBucketCors(t *testing.T) {
+	client, requests := newTestClient(t,
+		response{body: `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>GET</AllowedMethod><AllowedMethod>PUT</AllowedMethod><MaxAgeSeconds>60</MaxAgeSeconds></CORSRule></CORSConfiguration>`},
+		response{status: http.StatusNotFound, body: `<Error><Code>NoSuchCORSConfiguration</Code></Error>`},
+		response{status: http.StatusNotFound},
+	)
+
+	rules, err := client.GetBucketCors(context.Background(), "b")
+	want := []CORSRule{{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET", "PUT"}, MaxAgeSeconds: 60}}
+	if err != nil || !reflect.DeepEqual(rules, want) {
+		t.Errorf("GetBucketCors() = %+v, %v, want %+v, nil", rules, err, want)
+	}
+
+	rules, err = client.GetBucketCors(context.Background(), "b")
+	if err != nil || rules != nil {
+		t.Errorf("GetBucketCors() without configuration = %+v, %v, want nil, nil", rules, err)
+	}
+
+	// No rule deletes the configuration, a missing one is not an error
+	err = client.PutBucketCors(context.Background(), "b", nil)
+	if err != nil {
+		t.Errorf("PutBucketCors() = %v", err)
+	}
+	if got := (*requests)[2]; got.method != http.MethodDelete || got.query != "cors" {
+		t.Errorf("request %s ?%s, want DELETE ?cors", got.method, got.query)
+	}
+}
+
+func TestBucketPolicy(t *testing.T) {
+	policy := `{"Version":"2012-10-17","Statement":[]}`
+	client, requests := newTestClient(t,
+		response{body: policy},
+		response{status: http.StatusNotFound, body: `<Error><Code>NoSuchBucketPolicy</Code></Error>`},
+		response{},
+	)
+
+	got, err := client.GetBucketPolicy(context.Background(), "b")
+	if err != nil || got != policy {
+		t.Errorf("GetBucketPolicy() = %q, %v, want %q, nil", got, err, policy)
+	}
+	got, err = client.GetBucketPolicy(context.Background(), "b")
+	if err != nil || got != "" {
+		t.Errorf("GetBucketPolicy() without policy = %q, %v, want empty, nil", got, err)
+	}
+
+	err = client.PutBucketPolicy(context.Background(), "b", policy)
+	if err != nil {
+		t.Fatalf("PutBucketPolicy() = %v", err)
+	}
+	if got := (*requests)[2]; got.method != http.MethodPut || got.query != "policy" || got.body != policy {
+		t.Errorf("request %s ?%s %q, want PUT ?policy with the policy", got.method, got.query, got.body)
+	}
+	if got := (*requests)[2].header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestGetObjectLockConfiguration(t *testing.T) {
+	client, _ := newTestClient(t,
+		response{body: `<ObjectLockConfiguration><ObjectLockEnabled>Enabled</ObjectLockEnabled><Rule><DefaultRetention><Mode>GOVERNANCE</Mode><Days>3</Days></DefaultRetention></Rule></ObjectLockConfiguration>`},
+		response{status: http.StatusBadRequest, body: `<Error><Code>InvalidRequest</Code></Error>`},
+	)
+
+	config, err := client.GetObjectLockConfiguration(context.Background(), "b")
+	if err != nil || config.Rule == nil || config.Rule.DefaultRetention != (DefaultRetention{Mode: "GOVERNANCE", Days: 3}) {
+		t.Errorf("GetObjectLockConfiguration() = %+v, %v", config, err)
+	}
+
+	// The gateway answers InvalidRequest for a bucket created without object lock
+	config, err = client.GetObjectLockConfiguration(context.Background(), "b")
+	if err != nil || config != nil {
+		t.Errorf("GetObjectLockConfiguration() without object lock = %+v, %v, want nil, nil", config, err)
+	}
+}
+
+func TestListObjects(t *testing.T) {
+	client, requests := newTestClient(t,
+		response{body: `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Contents><Key>ns/store/a.tar.gz</Key><LastModified>2022-06-01T10:00:00.000Z</LastModified><Size>10</Size></Contents>
+  <IsTruncated>true</IsTruncated><NextContinuationToken>next token</NextContinuationToken>
+</ListBucketResult>`},
+		response{body: `<ListBucketResult>
+  <Contents><Key>ns/store/b.tar.gz</Key><LastModified>2022-06-02T10:00:00.000Z</LastModified><Size>20</Size></Contents>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`},
+	)
+
+	objects, err := client.ListObjects(context.Background(), "b", "ns/store/")
+	if err != nil {
+		t.Fatalf("ListObjects() = %v", err)
+	}
+	want := []Object{
+		{Key: "ns/store/a.tar.gz", LastModified: time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC), Size: 10},
+		{Key: "ns/store/b.tar.gz", LastModified: time.Date(2022, 6, 2, 10, 0, 0, 0, time.UTC), Size: 20},
+	}
+	if !reflect.DeepEqual(objects, want) {
+		t.Errorf("ListObjects() = %+v, want %+v", objects, want)
+	}
+
+	if got := (*requests)[0].query; got != "list-type=2&prefix=ns%2Fstore%2F" {
+		t.Errorf("first query %q", got)
+	}
+	if got := (*requests)[1].query; got != "continuation-token=next+token&list-type=2&prefix=ns%2Fstore%2F" {
+		t.Errorf("second query %q", got)
+	}
+}
+
+func TestDeleteObject(t *testing.T) {
+	client, requests := newTestClient(t, response{status: http.StatusNoContent}, response{status: http.StatusNotFound})
+
+	err := client.DeleteObject(context.Background(), "b", "ns/a b/c?.tar.gz")
+	if err != nil {
+		t.Errorf("DeleteObject() = %v", err)
+	}
+	if got := (*requests)[0]; got.method != http.MethodDelete || got.rawPath != "/b/ns/a%20b/c%3F.tar.gz" {
+		t.Errorf("request %s %s, want DELETE /b/ns/a%%20b/c%%3F.tar.gz", got.method, got.rawPath)
+	}
+
+	err = client.DeleteObject(context.Background(), "b", "missing")
+	if err != nil {
+		t.Errorf("DeleteObject() of a missing object = %v, want nil", err)
+	}
+}
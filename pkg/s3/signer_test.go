@@ -0,0 +1,158 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The vectors come from the AWS Signature Version 4 test suite, they all sign at the same time with
+// the same credentials
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testAmzDate   = "20150830T123600Z"
+	emptyHash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var testTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestCanonicalRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		headers       map[string]string
+		service       string
+		wantCanonical string
+		wantSignature string
+	}{
+		{
+			name:    "get-vanilla",
+			url:     "https://example.amazonaws.com/",
+			service: "service",
+			wantCanonical: "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				emptyHash,
+			wantSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:    "get-vanilla-query-order-key-case",
+			url:     "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			service: "service",
+			wantCanonical: "GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				emptyHash,
+			wantSignature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:    "iam list users with content type",
+			url:     "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+			service: "iam",
+			wantCanonical: "GET\n/\nAction=ListUsers&Version=2010-05-08\ncontent-type:application/x-www-form-urlencoded; charset=utf-8\n" +
+				"host:iam.amazonaws.com\nx-amz-date:20150830T123600Z\n\ncontent-type;host;x-amz-date\n" + emptyHash,
+			wantSignature: "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+		{
+			name:    "get-vanilla-query-unreserved",
+			url:     "https://example.amazonaws.com/?-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+			service: "service",
+			wantCanonical: "GET\n/\n-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=-._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz\n" +
+				"host:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyHash,
+		},
+		{
+			name:    "get-space",
+			url:     "https://example.amazonaws.com/example%20space/",
+			service: "service",
+			wantCanonical: "GET\n/example%20space/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				emptyHash,
+		},
+		{
+			name:    "get-utf8",
+			url:     "https://example.amazonaws.com/ሴ",
+			service: "service",
+			wantCanonical: "GET\n/%E1%88%B4\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				emptyHash,
+		},
+		{
+			name:    "query values with reserved characters",
+			url:     "https://example.amazonaws.com/bucket?prefix=a%20b%2Bc%2Fd&list-type=2",
+			service: "s3",
+			wantCanonical: "GET\n/bucket\nlist-type=2&prefix=a%20b%2Bc%2Fd\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" +
+				emptyHash,
+		},
+		{
+			name:    "header values are trimmed and unsigned headers skipped",
+			url:     "https://example.amazonaws.com/",
+			headers: map[string]string{"X-Amz-Meta-Test": "  a   b  ", "User-Agent": "test"},
+			service: "s3",
+			wantCanonical: "GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\nx-amz-meta-test:a b\n\nhost;x-amz-date;x-amz-meta-test\n" +
+				emptyHash,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, test.url, nil)
+			if err != nil {
+				t.Fatalf("failed to build request: %v", err)
+			}
+			req.Header.Set("X-Amz-Date", testAmzDate)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			_, canonical := canonicalRequest(req, emptyHash)
+			if canonical != test.wantCanonical {
+				t.Errorf("canonicalRequest() =\n%s\nwant\n%s", canonical, test.wantCanonical)
+			}
+
+			if test.wantSignature == "" {
+				return
+			}
+			scope := strings.Join([]string{"20150830", testRegion, test.service, "aws4_request"}, "/")
+			got := signature(testSecretKey, testTime, testRegion, test.service, stringToSign(testAmzDate, scope, canonical))
+			if got != test.wantSignature {
+				t.Errorf("signature() = %s, want %s", got, test.wantSignature)
+			}
+		})
+	}
+}
+
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+
+	SignV4(req, nil, testAccessKey, testSecretKey, testRegion, "service", testTime.In(time.FixedZone("CEST", 2*3600)))
+
+	if got := req.Header.Get("X-Amz-Date"); got != testAmzDate {
+		t.Errorf("X-Amz-Date = %q, want %q", got, testAmzDate)
+	}
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != emptyHash {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, emptyHash)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=726c5c4879a6b4ccbbd3b24edbd6b8826d34f87450fbbf4e85546fc7ba9c1642"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package s3
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// VersioningConfiguration is the payload of the versioning subresource
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// CORSConfiguration is the payload of the cors subresource
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule is a single CORS rule
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty" json:"id,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin" json:"allowedOrigins,omitempty"`
+	AllowedMethods []string `xml:"AllowedMethod" json:"allowedMethods,omitempty"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty" json:"allowedHeaders,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty" json:"exposeHeaders,omitempty"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty" json:"maxAgeSeconds,omitempty"`
+}
+
+// LifecycleConfiguration is the payload of the lifecycle subresource
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+// LifecycleRule is a single lifecycle rule
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty" json:"id,omitempty"`
+	Filter                         *LifecycleFilter                `xml:"Filter,omitempty" json:"filter,omitempty"`
+	Status                         string                          `xml:"Status" json:"status,omitempty"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty" json:"expiration,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty" json:"noncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty" json:"abortIncompleteMultipartUpload,omitempty"`
+}
+
+// LifecycleFilter selects the objects a lifecycle rule applies to
+type LifecycleFilter struct {
+	Prefix string `xml:"Prefix" json:"prefix,omitempty"`
+}
+
+// LifecycleExpiration expires the current version of the objects
+type LifecycleExpiration struct {
+	Days int `xml:"Days,omitempty" json:"days,omitempty"`
+}
+
+// NoncurrentVersionExpiration expires the noncurrent versions of the objects
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int `xml:"NoncurrentDays,omitempty" json:"noncurrentDays,omitempty"`
+}
+
+// AbortIncompleteMultipartUpload aborts the multipart uploads that never completed
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation,omitempty" json:"daysAfterInitiation,omitempty"`
+}
+
+// ObjectLockConfiguration is the payload of the object-lock subresource
+type ObjectLockConfiguration struct {
+	XMLName           xml.Name        `xml:"ObjectLockConfiguration"`
+	ObjectLockEnabled string          `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule `xml:"Rule,omitempty"`
+}
+
+// ObjectLockRule holds the default retention of the bucket
+type ObjectLockRule struct {
+	DefaultRetention DefaultRetention `xml:"DefaultRetention"`
+}
+
+// DefaultRetention is the retention applied to the new objects
+type DefaultRetention struct {
+	Mode  string `xml:"Mode"`
+	Days  int    `xml:"Days,omitempty"`
+	Years int    `xml:"Years,omitempty"`
+}
+
+// ListBucketResult is the response of ListObjectsV2
+type ListBucketResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// Object is an object listed in a bucket
+type Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	Size         int64     `xml:"Size"`
+}